@@ -0,0 +1,526 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var accountTypes = map[string]bool{
+	"card": true,
+	"cash": true,
+	"bank": true,
+}
+
+var currencyPattern = regexp.MustCompile(`^[A-Z]{3}$`)
+
+type Account struct {
+	ID             int     `json:"id"`
+	Name           string  `json:"name"`
+	Type           string  `json:"type"`
+	Currency       string  `json:"currency"`
+	OpeningBalance float64 `json:"opening_balance"`
+	Balance        float64 `json:"balance"`
+	ExpenseCount   int     `json:"expense_count"`
+	IncomeCount    int     `json:"income_count"`
+	CreatedAt      string  `json:"created_at"`
+}
+
+type AccountRequest struct {
+	Name           *string  `json:"name"`
+	Type           *string  `json:"type"`
+	Currency       *string  `json:"currency"`
+	OpeningBalance *float64 `json:"opening_balance"`
+}
+
+type BalanceEntry struct {
+	Date      string  `json:"date"`
+	Kind      string  `json:"kind"`
+	ExpenseID int     `json:"expense_id,omitempty"`
+	IncomeID  int     `json:"income_id,omitempty"`
+	Amount    float64 `json:"amount"`
+	Note      *string `json:"note"`
+	Balance   float64 `json:"balance"`
+}
+
+type BalanceReport struct {
+	Account        Account        `json:"account"`
+	DateFrom       string         `json:"date_from,omitempty"`
+	DateTo         string         `json:"date_to,omitempty"`
+	OpeningBalance float64        `json:"opening_balance"`
+	ClosingBalance float64        `json:"closing_balance"`
+	Entries        []BalanceEntry `json:"entries"`
+}
+
+const accountSelect = `
+	SELECT a.id, a.name, a.type, a.currency, a.opening_balance,
+		a.opening_balance
+			+ COALESCE((SELECT SUM(i.amount) FROM incomes i WHERE i.account_id = a.id), 0)
+			- COALESCE((SELECT SUM(e.amount) FROM expenses e WHERE e.account_id = a.id), 0),
+		(SELECT COUNT(*) FROM expenses e WHERE e.account_id = a.id),
+		(SELECT COUNT(*) FROM incomes i WHERE i.account_id = a.id),
+		a.created_at
+	FROM accounts a
+`
+
+func scanAccount(scanner interface{ Scan(...interface{}) error }) (Account, error) {
+	var account Account
+	err := scanner.Scan(
+		&account.ID,
+		&account.Name,
+		&account.Type,
+		&account.Currency,
+		&account.OpeningBalance,
+		&account.Balance,
+		&account.ExpenseCount,
+		&account.IncomeCount,
+		&account.CreatedAt,
+	)
+	return account, err
+}
+
+func getAccount(householdID, accountID int) (*Account, error) {
+	row := db.QueryRow(accountSelect+" WHERE a.id = ? AND a.household_id = ?", accountID, householdID)
+	account, err := scanAccount(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query account: %v", err)
+	}
+	return &account, nil
+}
+
+func parseAccountID(path string) (int, error) {
+	idStr := strings.TrimPrefix(path, "/api/v1/accounts/")
+	idStr = strings.TrimSuffix(idStr, "/balance")
+	return strconv.Atoi(idStr)
+}
+
+// validateAccountRequest checks the fields that are present; create also
+// requires name, type and currency.
+func validateAccountRequest(req *AccountRequest, create bool) error {
+	if req.Name != nil {
+		name := strings.TrimSpace(*req.Name)
+		if name == "" || len(name) > 255 {
+			return fmt.Errorf("Name must be 1-255 characters")
+		}
+		req.Name = &name
+	} else if create {
+		return fmt.Errorf("Name is required")
+	}
+
+	if req.Type != nil {
+		accountType := strings.ToLower(strings.TrimSpace(*req.Type))
+		if !accountTypes[accountType] {
+			return fmt.Errorf("Invalid type. Must be 'card', 'cash', or 'bank'")
+		}
+		req.Type = &accountType
+	} else if create {
+		return fmt.Errorf("Type is required")
+	}
+
+	if req.Currency != nil {
+		currency := strings.ToUpper(strings.TrimSpace(*req.Currency))
+		if !currencyPattern.MatchString(currency) {
+			return fmt.Errorf("Currency must be a 3-letter ISO 4217 code")
+		}
+		req.Currency = &currency
+	} else if create {
+		return fmt.Errorf("Currency is required")
+	}
+
+	if req.OpeningBalance != nil && (math.IsNaN(*req.OpeningBalance) || math.IsInf(*req.OpeningBalance, 0)) {
+		return fmt.Errorf("Invalid opening_balance")
+	}
+
+	return nil
+}
+
+func accountNameTaken(householdID int, name string, accountID int) (bool, error) {
+	var existingID int
+	err := db.QueryRow("SELECT id FROM accounts WHERE name = ? AND id != ? AND household_id = ?", name, accountID, householdID).Scan(&existingID)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to check account uniqueness: %v", err)
+	}
+	return true, nil
+}
+
+func getAccountsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	rows, err := db.Query(accountSelect+" WHERE a.household_id = ? ORDER BY a.name", householdID(r))
+	if err != nil {
+		logger.Error(fmt.Sprintf("Failed to query accounts: %v", err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	accounts := []Account{}
+	for rows.Next() {
+		account, err := scanAccount(rows)
+		if err != nil {
+			logger.Error(fmt.Sprintf("Failed to scan account: %v", err))
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		accounts = append(accounts, account)
+	}
+
+	if err := rows.Err(); err != nil {
+		logger.Error(fmt.Sprintf("Error iterating accounts: %v", err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(accounts)
+}
+
+func createAccountHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req AccountRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON body", http.StatusBadRequest)
+		return
+	}
+
+	if err := validateAccountRequest(&req, true); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	taken, err := accountNameTaken(householdID(r), *req.Name, 0)
+	if err != nil {
+		logger.Error(err.Error())
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if taken {
+		http.Error(w, "Account name already exists", http.StatusConflict)
+		return
+	}
+
+	openingBalance := 0.0
+	if req.OpeningBalance != nil {
+		openingBalance = *req.OpeningBalance
+	}
+
+	result, err := db.Exec(
+		"INSERT INTO accounts (household_id, name, type, currency, opening_balance) VALUES (?, ?, ?, ?, ?)",
+		householdID(r), *req.Name, *req.Type, *req.Currency, openingBalance,
+	)
+	if err != nil {
+		logger.Error(fmt.Sprintf("Failed to create account: %v", err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	accountID, err := result.LastInsertId()
+	if err != nil {
+		logger.Error(fmt.Sprintf("Failed to get last insert ID: %v", err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	account, err := getAccount(householdID(r), int(accountID))
+	if err != nil || account == nil {
+		logger.Error(fmt.Sprintf("Failed to load created account: %v", err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(account)
+}
+
+func updateAccountHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut && r.Method != http.MethodPatch {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	accountID, err := parseAccountID(r.URL.Path)
+	if err != nil {
+		http.Error(w, "Invalid account ID", http.StatusBadRequest)
+		return
+	}
+
+	var req AccountRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON body", http.StatusBadRequest)
+		return
+	}
+
+	if err := validateAccountRequest(&req, false); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var sets []string
+	var args []interface{}
+
+	if req.Name != nil {
+		taken, err := accountNameTaken(householdID(r), *req.Name, accountID)
+		if err != nil {
+			logger.Error(err.Error())
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		if taken {
+			http.Error(w, "Account name already exists", http.StatusConflict)
+			return
+		}
+		sets = append(sets, "name = ?")
+		args = append(args, *req.Name)
+	}
+	if req.Type != nil {
+		sets = append(sets, "type = ?")
+		args = append(args, *req.Type)
+	}
+	if req.Currency != nil {
+		sets = append(sets, "currency = ?")
+		args = append(args, *req.Currency)
+	}
+	if req.OpeningBalance != nil {
+		sets = append(sets, "opening_balance = ?")
+		args = append(args, *req.OpeningBalance)
+	}
+
+	if len(sets) == 0 {
+		http.Error(w, "Nothing to update", http.StatusBadRequest)
+		return
+	}
+
+	existing, err := getAccount(householdID(r), accountID)
+	if err != nil {
+		logger.Error(err.Error())
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if existing == nil {
+		http.Error(w, "Account not found", http.StatusNotFound)
+		return
+	}
+
+	if _, err := db.Exec("UPDATE accounts SET "+strings.Join(sets, ", ")+" WHERE id = ?", append(args, accountID)...); err != nil {
+		logger.Error(fmt.Sprintf("Failed to update account: %v", err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	account, err := getAccount(householdID(r), accountID)
+	if err != nil || account == nil {
+		logger.Error(fmt.Sprintf("Failed to load updated account: %v", err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(account)
+}
+
+func deleteAccountHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	accountID, err := parseAccountID(r.URL.Path)
+	if err != nil {
+		http.Error(w, "Invalid account ID", http.StatusBadRequest)
+		return
+	}
+
+	account, err := getAccount(householdID(r), accountID)
+	if err != nil {
+		logger.Error(err.Error())
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if account == nil {
+		http.Error(w, "Account not found", http.StatusNotFound)
+		return
+	}
+
+	// Entries keep their account for reconciliation, so they have to be
+	// moved or deleted first.
+	if account.ExpenseCount > 0 || account.IncomeCount > 0 {
+		http.Error(w, "Cannot delete account: it has related expenses or incomes", http.StatusConflict)
+		return
+	}
+
+	if _, err := db.Exec("DELETE FROM accounts WHERE id = ?", accountID); err != nil {
+		logger.Error(fmt.Sprintf("Failed to delete account: %v", err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "Account deleted successfully",
+		"id":      accountID,
+	})
+}
+
+// buildBalanceReport walks the account's expenses and incomes in date order.
+// The opening balance of the report is the account's balance at the start of
+// date_from.
+func buildBalanceReport(householdID int, account Account, dateFrom, dateTo string) (BalanceReport, error) {
+	report := BalanceReport{
+		Account:        account,
+		DateFrom:       dateFrom,
+		DateTo:         dateTo,
+		OpeningBalance: account.OpeningBalance,
+		Entries:        []BalanceEntry{},
+	}
+
+	if dateFrom != "" {
+		var changeBefore float64
+		err := db.QueryRow(`
+			SELECT
+				COALESCE((SELECT SUM(amount) FROM incomes WHERE account_id = ? AND DATE(created_at) < ?), 0)
+				- COALESCE((SELECT SUM(amount) FROM expenses WHERE account_id = ? AND DATE(created_at) < ?), 0)
+		`, account.ID, dateFrom, account.ID, dateFrom).Scan(&changeBefore)
+		if err != nil {
+			return report, fmt.Errorf("failed to query balance before %s: %v", dateFrom, err)
+		}
+		report.OpeningBalance += changeBefore
+	}
+
+	filter := ExpenseFilter{HouseholdID: householdID, AccountIDs: []int{account.ID}, DateFrom: dateFrom, DateTo: dateTo}
+	expenseConditions, expenseArgs := filter.periodConditions("e")
+	incomeConditions, incomeArgs := filter.periodConditions("i")
+
+	query := fmt.Sprintf(`
+		SELECT 'expense', e.id, DATE_FORMAT(e.created_at, '%%Y-%%m-%%d'), e.amount, e.note, e.created_at
+		FROM expenses e WHERE %s
+		UNION ALL
+		SELECT 'income', i.id, DATE_FORMAT(i.created_at, '%%Y-%%m-%%d'), i.amount, i.note, i.created_at
+		FROM incomes i WHERE %s
+		ORDER BY 6, 1 DESC, 2
+	`, strings.Join(expenseConditions, " AND "), strings.Join(incomeConditions, " AND "))
+	args := append(expenseArgs, incomeArgs...)
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return report, fmt.Errorf("failed to query account ledger: %v", err)
+	}
+	defer rows.Close()
+
+	balance := report.OpeningBalance
+	for rows.Next() {
+		var entry BalanceEntry
+		var id int
+		var note sql.NullString
+		var createdAt time.Time
+		if err := rows.Scan(&entry.Kind, &id, &entry.Date, &entry.Amount, &note, &createdAt); err != nil {
+			return report, fmt.Errorf("failed to scan ledger row: %v", err)
+		}
+		if note.Valid {
+			entry.Note = &note.String
+		}
+		if entry.Kind == "income" {
+			entry.IncomeID = id
+			balance += entry.Amount
+		} else {
+			entry.ExpenseID = id
+			balance -= entry.Amount
+		}
+		entry.Balance = math.Round(balance*100) / 100
+		report.Entries = append(report.Entries, entry)
+	}
+
+	if err = rows.Err(); err != nil {
+		return report, fmt.Errorf("error iterating over ledger rows: %v", err)
+	}
+
+	report.ClosingBalance = math.Round(balance*100) / 100
+
+	return report, nil
+}
+
+func accountBalanceHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	accountID, err := parseAccountID(r.URL.Path)
+	if err != nil {
+		http.Error(w, "Invalid account ID", http.StatusBadRequest)
+		return
+	}
+
+	query := r.URL.Query()
+	dateFrom := query.Get("date_from")
+	dateTo := query.Get("date_to")
+
+	if dateFrom != "" {
+		if _, err := time.Parse("2006-01-02", dateFrom); err != nil {
+			http.Error(w, "Invalid date_from parameter. Must be in YYYY-MM-DD format", http.StatusBadRequest)
+			return
+		}
+	}
+	if dateTo != "" {
+		if _, err := time.Parse("2006-01-02", dateTo); err != nil {
+			http.Error(w, "Invalid date_to parameter. Must be in YYYY-MM-DD format", http.StatusBadRequest)
+			return
+		}
+	}
+
+	account, err := getAccount(householdID(r), accountID)
+	if err != nil {
+		logger.Error(err.Error())
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if account == nil {
+		http.Error(w, "Account not found", http.StatusNotFound)
+		return
+	}
+
+	report, err := buildBalanceReport(householdID(r), *account, dateFrom, dateTo)
+	if err != nil {
+		logger.Error(err.Error())
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
+
+func singleAccountHandler(w http.ResponseWriter, r *http.Request) {
+	if strings.HasSuffix(r.URL.Path, "/balance") {
+		accountBalanceHandler(w, r)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPut, http.MethodPatch:
+		updateAccountHandler(w, r)
+	case http.MethodDelete:
+		deleteAccountHandler(w, r)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,409 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const maxAttachmentSize = 10 << 20
+
+var allowedAttachmentTypes = map[string]bool{
+	"image/jpeg":      true,
+	"image/png":       true,
+	"image/gif":       true,
+	"image/webp":      true,
+	"application/pdf": true,
+}
+
+type Attachment struct {
+	ID          int    `json:"id"`
+	ExpenseID   int    `json:"expense_id"`
+	Filename    string `json:"filename"`
+	ContentType string `json:"content_type"`
+	Size        int64  `json:"size"`
+	StorageKey  string `json:"-"`
+	CreatedAt   string `json:"created_at"`
+}
+
+// parseAttachmentPath splits /api/v1/expenses/{id}/attachments[/{attachmentID}].
+func parseAttachmentPath(path string) (int, *int, error) {
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(path, "/api/v1/expenses/"), "/"), "/")
+	if len(parts) < 2 || len(parts) > 3 || parts[1] != "attachments" {
+		return 0, nil, fmt.Errorf("Invalid attachment path")
+	}
+
+	expenseID, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, nil, fmt.Errorf("Invalid expense ID")
+	}
+
+	if len(parts) == 2 {
+		return expenseID, nil, nil
+	}
+
+	attachmentID, err := strconv.Atoi(parts[2])
+	if err != nil {
+		return 0, nil, fmt.Errorf("Invalid attachment ID")
+	}
+
+	return expenseID, &attachmentID, nil
+}
+
+func isAttachmentPath(path string) bool {
+	parts := strings.Split(strings.TrimPrefix(path, "/api/v1/expenses/"), "/")
+	return len(parts) >= 2 && parts[1] == "attachments"
+}
+
+func newAttachmentKey(expenseID int) (string, error) {
+	return newBlobKey(fmt.Sprintf("expenses/%d", expenseID))
+}
+
+// sanitizeAttachmentFilename keeps the base name of the uploaded file and
+// drops characters that would break a Content-Disposition header.
+func sanitizeAttachmentFilename(name string) string {
+	name = filepath.Base(strings.ReplaceAll(name, "\\", "/"))
+	name = strings.Map(func(r rune) rune {
+		if r < 32 || r == '"' || r == 127 {
+			return -1
+		}
+		return r
+	}, name)
+	if name == "" || name == "." || name == "/" {
+		name = "receipt"
+	}
+	if len(name) > 255 {
+		name = name[len(name)-255:]
+	}
+	return name
+}
+
+func scanAttachment(scanner interface{ Scan(...interface{}) error }) (Attachment, error) {
+	var attachment Attachment
+	err := scanner.Scan(&attachment.ID, &attachment.ExpenseID, &attachment.Filename, &attachment.ContentType, &attachment.Size, &attachment.StorageKey, &attachment.CreatedAt)
+	return attachment, err
+}
+
+func getAttachment(expenseID, attachmentID int) (*Attachment, error) {
+	row := db.QueryRow(`
+		SELECT id, expense_id, filename, content_type, size, storage_key, created_at
+		FROM expense_attachments
+		WHERE id = ? AND expense_id = ?
+	`, attachmentID, expenseID)
+
+	attachment, err := scanAttachment(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query attachment: %v", err)
+	}
+
+	return &attachment, nil
+}
+
+func queryAttachmentKeys(expenseID int) ([]string, error) {
+	rows, err := db.Query("SELECT storage_key FROM expense_attachments WHERE expense_id = ?", expenseID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query attachment keys: %v", err)
+	}
+	defer rows.Close()
+
+	var keys []string
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return nil, fmt.Errorf("failed to scan attachment key: %v", err)
+		}
+		keys = append(keys, key)
+	}
+
+	return keys, rows.Err()
+}
+
+func attachmentsHandler(w http.ResponseWriter, r *http.Request) {
+	expenseID, attachmentID, err := parseAttachmentPath(r.URL.Path)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	exists, err := inHousehold(householdID(r), "expenses", expenseID)
+	if err != nil {
+		logger.Error(err.Error())
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if !exists {
+		http.Error(w, "Expense not found", http.StatusNotFound)
+		return
+	}
+
+	if attachmentID == nil {
+		if r.Method == http.MethodGet {
+			listAttachmentsHandler(w, r, expenseID)
+		} else if r.Method == http.MethodPost {
+			uploadAttachmentHandler(w, r, expenseID)
+		} else {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+		return
+	}
+
+	if r.Method == http.MethodGet {
+		downloadAttachmentHandler(w, r, expenseID, *attachmentID)
+	} else if r.Method == http.MethodDelete {
+		deleteAttachmentHandler(w, r, expenseID, *attachmentID)
+	} else {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func listAttachmentsHandler(w http.ResponseWriter, r *http.Request, expenseID int) {
+	rows, err := db.Query(`
+		SELECT id, expense_id, filename, content_type, size, storage_key, created_at
+		FROM expense_attachments
+		WHERE expense_id = ?
+		ORDER BY created_at, id
+	`, expenseID)
+	if err != nil {
+		logger.Error(fmt.Sprintf("Failed to query attachments: %v", err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	attachments := []Attachment{}
+	for rows.Next() {
+		attachment, err := scanAttachment(rows)
+		if err != nil {
+			logger.Error(fmt.Sprintf("Failed to scan attachment: %v", err))
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		attachments = append(attachments, attachment)
+	}
+
+	if err := rows.Err(); err != nil {
+		logger.Error(fmt.Sprintf("Error iterating attachments: %v", err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(attachments)
+}
+
+// readAttachmentUpload reads the "file" field of a multipart upload and
+// sniffs its type. It writes the error response itself and returns ok=false
+// when the upload is rejected.
+func readAttachmentUpload(w http.ResponseWriter, r *http.Request) ([]byte, string, string, bool) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxAttachmentSize+1<<20)
+	if err := r.ParseMultipartForm(maxAttachmentSize); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid multipart form or file larger than %d MB", maxAttachmentSize>>20), http.StatusBadRequest)
+		return nil, "", "", false
+	}
+
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		http.Error(w, "file is required", http.StatusBadRequest)
+		return nil, "", "", false
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, maxAttachmentSize+1))
+	if err != nil {
+		logger.Error(fmt.Sprintf("Failed to read attachment: %v", err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return nil, "", "", false
+	}
+	if len(data) > maxAttachmentSize {
+		http.Error(w, fmt.Sprintf("File larger than %d MB", maxAttachmentSize>>20), http.StatusRequestEntityTooLarge)
+		return nil, "", "", false
+	}
+	if len(data) == 0 {
+		http.Error(w, "File is empty", http.StatusBadRequest)
+		return nil, "", "", false
+	}
+
+	// The declared type is ignored; only what the bytes look like counts.
+	contentType, _, _ := mime.ParseMediaType(http.DetectContentType(data))
+	if !allowedAttachmentTypes[contentType] {
+		http.Error(w, "Unsupported file type. Allowed: JPEG, PNG, GIF, WebP, PDF", http.StatusUnsupportedMediaType)
+		return nil, "", "", false
+	}
+
+	return data, sanitizeAttachmentFilename(header.Filename), contentType, true
+}
+
+func uploadAttachmentHandler(w http.ResponseWriter, r *http.Request, expenseID int) {
+	data, filename, contentType, ok := readAttachmentUpload(w, r)
+	if !ok {
+		return
+	}
+
+	key, err := newAttachmentKey(expenseID)
+	if err != nil {
+		logger.Error(err.Error())
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	if err := blobStorage.Put(r.Context(), key, data, contentType); err != nil {
+		logger.Error(fmt.Sprintf("Failed to store attachment: %v", err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	// The file is stored before the expense is locked, so a slow upload does
+	// not hold the lock; it is removed again unless its row is committed.
+	committed := false
+	defer func() {
+		if committed {
+			return
+		}
+		if err := blobStorage.Delete(r.Context(), key); err != nil {
+			logger.Error(fmt.Sprintf("Failed to remove orphaned attachment %s: %v", key, err))
+		}
+	}()
+
+	tx, err := db.Begin()
+	if err != nil {
+		logger.Error(fmt.Sprintf("Failed to begin transaction: %v", err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	if !checkExpenseUnlocked(w, tx, expenseID) {
+		return
+	}
+
+	result, err := tx.Exec(`
+		INSERT INTO expense_attachments (expense_id, filename, content_type, size, storage_key)
+		VALUES (?, ?, ?, ?, ?)
+	`, expenseID, filename, contentType, len(data), key)
+	if err != nil {
+		logger.Error(fmt.Sprintf("Failed to insert attachment: %v", err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	attachmentID, err := result.LastInsertId()
+	if err != nil {
+		logger.Error(fmt.Sprintf("Failed to get attachment ID: %v", err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		logger.Error(fmt.Sprintf("Failed to commit attachment: %v", err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	committed = true
+
+	attachment, err := getAttachment(expenseID, int(attachmentID))
+	if err != nil || attachment == nil {
+		logger.Error(fmt.Sprintf("Failed to load created attachment: %v", err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(attachment)
+}
+
+func downloadAttachmentHandler(w http.ResponseWriter, r *http.Request, expenseID, attachmentID int) {
+	attachment, err := getAttachment(expenseID, attachmentID)
+	if err != nil {
+		logger.Error(err.Error())
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if attachment == nil {
+		http.Error(w, "Attachment not found", http.StatusNotFound)
+		return
+	}
+
+	body, err := blobStorage.Get(r.Context(), attachment.StorageKey)
+	if err != nil {
+		if err == errBlobNotFound {
+			logger.Error(fmt.Sprintf("Attachment %d is missing from storage", attachment.ID))
+			http.Error(w, "Attachment file not found", http.StatusNotFound)
+			return
+		}
+		logger.Error(fmt.Sprintf("Failed to read attachment: %v", err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	defer body.Close()
+
+	disposition := "inline"
+	if r.URL.Query().Get("download") == "true" {
+		disposition = "attachment"
+	}
+
+	w.Header().Set("Content-Type", attachment.ContentType)
+	w.Header().Set("Content-Length", strconv.FormatInt(attachment.Size, 10))
+	w.Header().Set("Content-Disposition", mime.FormatMediaType(disposition, map[string]string{"filename": attachment.Filename}))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+
+	if _, err := io.Copy(w, body); err != nil {
+		logger.Error(fmt.Sprintf("Failed to stream attachment: %v", err))
+	}
+}
+
+func deleteAttachmentHandler(w http.ResponseWriter, r *http.Request, expenseID, attachmentID int) {
+	attachment, err := getAttachment(expenseID, attachmentID)
+	if err != nil {
+		logger.Error(err.Error())
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if attachment == nil {
+		http.Error(w, "Attachment not found", http.StatusNotFound)
+		return
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		logger.Error(fmt.Sprintf("Failed to begin transaction: %v", err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	if !checkExpenseUnlocked(w, tx, expenseID) {
+		return
+	}
+
+	if _, err := tx.Exec("DELETE FROM expense_attachments WHERE id = ?", attachment.ID); err != nil {
+		logger.Error(fmt.Sprintf("Failed to delete attachment: %v", err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		logger.Error(fmt.Sprintf("Failed to commit attachment delete: %v", err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	if err := blobStorage.Delete(r.Context(), attachment.StorageKey); err != nil {
+		logger.Error(fmt.Sprintf("Failed to remove attachment %s from storage: %v", attachment.StorageKey, err))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "Attachment deleted successfully",
+		"id":      attachment.ID,
+	})
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func multipartUpload(t *testing.T, field, filename string, data []byte) *http.Request {
+	t.Helper()
+
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	part, err := writer.CreateFormFile(field, filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	part.Write(data)
+	writer.Close()
+
+	r := httptest.NewRequest(http.MethodPost, "/api/v1/expenses/1/attachments", &body)
+	r.Header.Set("Content-Type", writer.FormDataContentType())
+	return r
+}
+
+func TestReadAttachmentUpload(t *testing.T) {
+	png := append([]byte("\x89PNG\r\n\x1a\n"), make([]byte, 32)...)
+
+	tests := []struct {
+		name         string
+		field        string
+		filename     string
+		data         []byte
+		wantStatus   int
+		wantType     string
+		wantFilename string
+	}{
+		{
+			name:         "PNG sniffed from its bytes",
+			field:        "file",
+			filename:     "receipt.png",
+			data:         png,
+			wantType:     "image/png",
+			wantFilename: "receipt.png",
+		},
+		{
+			name:         "declared extension is ignored",
+			field:        "file",
+			filename:     "receipt.txt",
+			data:         []byte("%PDF-1.7\n%binary"),
+			wantType:     "application/pdf",
+			wantFilename: "receipt.txt",
+		},
+		{
+			name:       "HTML disguised as an image is rejected",
+			field:      "file",
+			filename:   "receipt.png",
+			data:       []byte("<html><script>alert(1)</script></html>"),
+			wantStatus: http.StatusUnsupportedMediaType,
+		},
+		{
+			name:       "empty file is rejected",
+			field:      "file",
+			filename:   "empty.pdf",
+			data:       []byte{},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "missing file field is rejected",
+			field:      "upload",
+			filename:   "receipt.png",
+			data:       png,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "file over the size limit is rejected",
+			field:      "file",
+			filename:   "large.png",
+			data:       append(png, make([]byte, maxAttachmentSize)...),
+			wantStatus: http.StatusRequestEntityTooLarge,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			data, filename, contentType, ok := readAttachmentUpload(w, multipartUpload(t, tt.field, tt.filename, tt.data))
+
+			if tt.wantStatus != 0 {
+				if ok {
+					t.Fatalf("upload accepted as %s, want status %d", contentType, tt.wantStatus)
+				}
+				if w.Code != tt.wantStatus {
+					t.Errorf("status = %d, want %d (%s)", w.Code, tt.wantStatus, w.Body.String())
+				}
+				return
+			}
+
+			if !ok {
+				t.Fatalf("upload rejected with %d: %s", w.Code, w.Body.String())
+			}
+			if contentType != tt.wantType {
+				t.Errorf("content type = %q, want %q", contentType, tt.wantType)
+			}
+			if filename != tt.wantFilename {
+				t.Errorf("filename = %q, want %q", filename, tt.wantFilename)
+			}
+			if !bytes.Equal(data, tt.data) {
+				t.Errorf("data changed: got %d bytes, want %d", len(data), len(tt.data))
+			}
+		})
+	}
+}
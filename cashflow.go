@@ -0,0 +1,151 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+)
+
+type CashFlowBucket struct {
+	Label       string   `json:"label"`
+	Start       string   `json:"start"`
+	End         string   `json:"end"`
+	Income      float64  `json:"income"`
+	Expenses    float64  `json:"expenses"`
+	Net         float64  `json:"net"`
+	SavingsRate *float64 `json:"savings_rate"`
+}
+
+type CashFlowReport struct {
+	Interval    string           `json:"interval"`
+	Timezone    string           `json:"timezone"`
+	DateFrom    string           `json:"date_from"`
+	DateTo      string           `json:"date_to"`
+	Income      float64          `json:"income"`
+	Expenses    float64          `json:"expenses"`
+	Net         float64          `json:"net"`
+	SavingsRate *float64         `json:"savings_rate"`
+	Buckets     []CashFlowBucket `json:"buckets"`
+}
+
+// savingsRate is the share of income left after expenses, in percent. It is
+// undefined without income.
+func savingsRate(income, expenses float64) *float64 {
+	if income <= 0 {
+		return nil
+	}
+	rate := math.Round((income-expenses)/income*10000) / 100
+	return &rate
+}
+
+// sumByBucket adds the amounts of one table into the buckets through add.
+func sumByBucket(table, alias string, filter ExpenseFilter, tr timeseriesRange, bucketIndex map[int64]int, add func(i int, amount float64)) error {
+	filter.DateFrom = ""
+	filter.DateTo = ""
+	conditions, args := filter.periodConditions(alias)
+	conditions = append([]string{alias + ".created_at >= ?", alias + ".created_at < ?"}, conditions...)
+	args = append([]interface{}{tr.From.UTC(), tr.end().UTC()}, args...)
+
+	query := fmt.Sprintf("SELECT %s.created_at, %s.amount FROM %s %s WHERE %s", alias, alias, table, alias, strings.Join(conditions, " AND "))
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to query %s for cash flow: %v", table, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var createdAt time.Time
+		var amount float64
+		if err := rows.Scan(&createdAt, &amount); err != nil {
+			return fmt.Errorf("failed to scan cash flow row: %v", err)
+		}
+		if i, ok := tr.bucketOf(createdAt, bucketIndex); ok {
+			add(i, amount)
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		return fmt.Errorf("error iterating over cash flow rows: %v", err)
+	}
+
+	return nil
+}
+
+func cashFlowReportHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	filter, err := parseExpenseFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if filter.expenseOnly() {
+		http.Error(w, "Cash flow only accepts user_id, account_id, date_from and date_to filters", http.StatusBadRequest)
+		return
+	}
+
+	tr, err := parseTimeseriesRange(r.URL.Query(), filter)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	timeBuckets, bucketIndex, err := tr.buckets()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	buckets := make([]CashFlowBucket, len(timeBuckets))
+	for i, bucket := range timeBuckets {
+		buckets[i] = CashFlowBucket{Label: bucket.Label, Start: bucket.Start, End: bucket.End}
+	}
+
+	err = sumByBucket("incomes", "i", filter, tr, bucketIndex, func(i int, amount float64) {
+		buckets[i].Income += amount
+	})
+	if err == nil {
+		err = sumByBucket("expenses", "e", filter, tr, bucketIndex, func(i int, amount float64) {
+			buckets[i].Expenses += amount
+		})
+	}
+	if err != nil {
+		logger.Error(err.Error())
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	report := CashFlowReport{
+		Interval: tr.Interval,
+		Timezone: tr.Location.String(),
+		DateFrom: tr.From.Format("2006-01-02"),
+		DateTo:   tr.To.Format("2006-01-02"),
+		Buckets:  buckets,
+	}
+
+	for i := range report.Buckets {
+		bucket := &report.Buckets[i]
+		bucket.Income = math.Round(bucket.Income*100) / 100
+		bucket.Expenses = math.Round(bucket.Expenses*100) / 100
+		bucket.Net = math.Round((bucket.Income-bucket.Expenses)*100) / 100
+		bucket.SavingsRate = savingsRate(bucket.Income, bucket.Expenses)
+
+		report.Income += bucket.Income
+		report.Expenses += bucket.Expenses
+	}
+
+	report.Income = math.Round(report.Income*100) / 100
+	report.Expenses = math.Round(report.Expenses*100) / 100
+	report.Net = math.Round((report.Income-report.Expenses)*100) / 100
+	report.SavingsRate = savingsRate(report.Income, report.Expenses)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
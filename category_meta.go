@@ -0,0 +1,203 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+)
+
+var (
+	colorPattern = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+	iconPattern  = regexp.MustCompile(`^[a-z0-9_-]{1,64}$`)
+)
+
+// categoryMetadata is the display metadata shared by categories and
+// subcategories. Fields left out of a request are unchanged; an empty color
+// or icon clears it.
+type categoryMetadata struct {
+	Color     *string `json:"color"`
+	Icon      *string `json:"icon"`
+	SortOrder *int    `json:"sort_order"`
+	Archived  *bool   `json:"archived"`
+}
+
+func (m *categoryMetadata) validate() error {
+	if m.Color != nil && *m.Color != "" && !colorPattern.MatchString(*m.Color) {
+		return fmt.Errorf("Invalid color. Must be a hex color like #1A2B3C")
+	}
+	if m.Icon != nil && *m.Icon != "" && !iconPattern.MatchString(*m.Icon) {
+		return fmt.Errorf("Invalid icon. Must be 1-64 lowercase letters, digits, '-' or '_'")
+	}
+	return nil
+}
+
+// nullIfEmpty stores a missing or empty string as NULL.
+func nullIfEmpty(value *string) interface{} {
+	if value == nil || *value == "" {
+		return nil
+	}
+	return *value
+}
+
+func (m categoryMetadata) sets() ([]string, []interface{}) {
+	var sets []string
+	var args []interface{}
+
+	if m.Color != nil {
+		sets = append(sets, "color = ?")
+		args = append(args, nullIfEmpty(m.Color))
+	}
+	if m.Icon != nil {
+		sets = append(sets, "icon = ?")
+		args = append(args, nullIfEmpty(m.Icon))
+	}
+	if m.SortOrder != nil {
+		sets = append(sets, "sort_order = ?")
+		args = append(args, *m.SortOrder)
+	}
+	if m.Archived != nil {
+		sets = append(sets, "archived = ?")
+		args = append(args, *m.Archived)
+	}
+	return sets, args
+}
+
+// categoryRowColumns selects a category joined to its subcategories, as read
+// by scanCategoryRow. The query must alias categories as c and subcategories
+// as s.
+const categoryRowColumns = `
+	c.id, c.name, c.parent_id, c.color, c.icon, c.sort_order, c.archived,
+	s.id, s.name, s.color, s.icon, s.sort_order, s.archived
+`
+
+// scanCategoryRow reads one category/subcategory row; the subcategory is nil
+// when the category has none.
+func scanCategoryRow(rows *sql.Rows) (Category, *Subcategory, error) {
+	var category Category
+	var parentID sql.NullInt64
+	var subcategoryID sql.NullInt64
+	var subcategoryName sql.NullString
+	var subcategoryColor *string
+	var subcategoryIcon *string
+	var subcategorySortOrder sql.NullInt64
+	var subcategoryArchived sql.NullBool
+
+	err := rows.Scan(
+		&category.ID,
+		&category.Name,
+		&parentID,
+		&category.Color,
+		&category.Icon,
+		&category.SortOrder,
+		&category.Archived,
+		&subcategoryID,
+		&subcategoryName,
+		&subcategoryColor,
+		&subcategoryIcon,
+		&subcategorySortOrder,
+		&subcategoryArchived,
+	)
+	if err != nil {
+		return category, nil, err
+	}
+	category.ParentID = nullableInt(parentID)
+	category.Subcategories = []Subcategory{}
+
+	if !subcategoryID.Valid {
+		return category, nil, nil
+	}
+
+	subcategory := Subcategory{
+		ID:        int(subcategoryID.Int64),
+		Name:      subcategoryName.String,
+		Color:     subcategoryColor,
+		Icon:      subcategoryIcon,
+		SortOrder: int(subcategorySortOrder.Int64),
+		Archived:  subcategoryArchived.Bool,
+	}
+	return category, &subcategory, nil
+}
+
+// nextSortOrder places a new row after its siblings.
+func nextSortOrder(query string, args ...interface{}) (int, error) {
+	var next int
+	if err := db.QueryRow(query, args...).Scan(&next); err != nil {
+		return 0, fmt.Errorf("failed to compute sort order: %v", err)
+	}
+	return next, nil
+}
+
+type reorderRequest struct {
+	IDs []int `json:"ids"`
+}
+
+// reorderHandler sets sort_order to each ID's position in the list. Every ID
+// must be one of the household's rows in table; rows left out keep their
+// order.
+func reorderHandler(w http.ResponseWriter, r *http.Request, table, label string) {
+	if r.Method != http.MethodPut && r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req reorderRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON body", http.StatusBadRequest)
+		return
+	}
+
+	if len(req.IDs) == 0 {
+		http.Error(w, "ids is required", http.StatusBadRequest)
+		return
+	}
+
+	seen := make(map[int]bool, len(req.IDs))
+	for _, id := range req.IDs {
+		if seen[id] {
+			http.Error(w, fmt.Sprintf("Duplicate %s ID: %d", label, id), http.StatusBadRequest)
+			return
+		}
+		seen[id] = true
+
+		ok, err := inHousehold(householdID(r), table, id)
+		if err != nil {
+			logger.Error(err.Error())
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		if !ok {
+			http.Error(w, fmt.Sprintf("Unknown %s ID: %d", label, id), http.StatusBadRequest)
+			return
+		}
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		logger.Error(fmt.Sprintf("Failed to begin reorder transaction: %v", err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	for position, id := range req.IDs {
+		if _, err := tx.Exec("UPDATE "+table+" SET sort_order = ? WHERE id = ?", position, id); err != nil {
+			logger.Error(fmt.Sprintf("Failed to reorder %s %d: %v", label, id, err))
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		logger.Error(fmt.Sprintf("Failed to commit reorder: %v", err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "Order updated successfully",
+		"ids":     req.IDs,
+	})
+}
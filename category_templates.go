@@ -0,0 +1,344 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+)
+
+type TemplateCategory struct {
+	Name          string   `json:"name"`
+	Color         string   `json:"color,omitempty"`
+	Icon          string   `json:"icon,omitempty"`
+	Subcategories []string `json:"subcategories"`
+}
+
+// CategoryTemplate is a starting taxonomy. Bump Version whenever Categories
+// change so households that applied an older version can pick up the
+// additions; entries are never removed from a household by a template.
+type CategoryTemplate struct {
+	Key        string             `json:"key"`
+	Name       string             `json:"name"`
+	Version    int                `json:"version"`
+	Categories []TemplateCategory `json:"categories"`
+}
+
+var categoryTemplates = []CategoryTemplate{
+	{
+		Key:     "household-basic",
+		Name:    "Household basic",
+		Version: 1,
+		Categories: []TemplateCategory{
+			{Name: "Housing", Color: "#4E79A7", Icon: "home", Subcategories: []string{"Rent", "Mortgage", "Utilities", "Internet", "Maintenance"}},
+			{Name: "Food", Color: "#F28E2B", Icon: "utensils", Subcategories: []string{"Groceries", "Restaurants", "Coffee"}},
+			{Name: "Transport", Color: "#E15759", Icon: "car", Subcategories: []string{"Fuel", "Public transport", "Parking", "Car maintenance"}},
+			{Name: "Health", Color: "#76B7B2", Icon: "heart", Subcategories: []string{"Pharmacy", "Doctor", "Insurance"}},
+			{Name: "Personal", Color: "#59A14F", Icon: "user", Subcategories: []string{"Clothing", "Haircut", "Gifts"}},
+			{Name: "Leisure", Color: "#EDC948", Icon: "ticket", Subcategories: []string{"Subscriptions", "Travel", "Hobbies"}},
+		},
+	},
+	{
+		Key:     "freelancer",
+		Name:    "Freelancer",
+		Version: 1,
+		Categories: []TemplateCategory{
+			{Name: "Business", Color: "#4E79A7", Icon: "briefcase", Subcategories: []string{"Software", "Hardware", "Coworking", "Accounting", "Bank fees"}},
+			{Name: "Marketing", Color: "#F28E2B", Icon: "megaphone", Subcategories: []string{"Advertising", "Website", "Conferences"}},
+			{Name: "Taxes", Color: "#E15759", Icon: "receipt", Subcategories: []string{"Income tax", "Social security", "VAT"}},
+			{Name: "Education", Color: "#76B7B2", Icon: "book", Subcategories: []string{"Courses", "Books"}},
+			{Name: "Business travel", Color: "#59A14F", Icon: "plane", Subcategories: []string{"Flights", "Hotels", "Meals"}},
+		},
+	},
+}
+
+type TemplateApplySummary struct {
+	Template             string `json:"template"`
+	Version              int    `json:"version"`
+	PreviousVersion      *int   `json:"previous_version"`
+	UpToDate             bool   `json:"up_to_date"`
+	CategoriesCreated    int    `json:"categories_created"`
+	CategoriesMatched    int    `json:"categories_matched"`
+	SubcategoriesCreated int    `json:"subcategories_created"`
+	SubcategoriesMatched int    `json:"subcategories_matched"`
+}
+
+func findCategoryTemplate(key string) *CategoryTemplate {
+	for i := range categoryTemplates {
+		if categoryTemplates[i].Key == key {
+			return &categoryTemplates[i]
+		}
+	}
+	return nil
+}
+
+// applyCategoryTemplate adds the template's categories and subcategories
+// that the household does not have yet, matching by name, so applying it
+// again never duplicates anything. A household already at this version is
+// left alone unless force is set, so entries deleted on purpose stay deleted.
+func applyCategoryTemplate(tx *sql.Tx, householdID int, template CategoryTemplate, force bool) (TemplateApplySummary, error) {
+	summary := TemplateApplySummary{Template: template.Key, Version: template.Version}
+
+	// Locking the household serializes applies to it. A first apply has no
+	// category_template_applications row to lock, so two of them would
+	// otherwise both create the same categories and both insert the row; the
+	// second now waits and finds the template up to date.
+	var lockedID int
+	if err := tx.QueryRow("SELECT id FROM households WHERE id = ? FOR UPDATE", householdID).Scan(&lockedID); err != nil {
+		return summary, fmt.Errorf("failed to lock household %d: %v", householdID, err)
+	}
+
+	var previous int
+	err := tx.QueryRow(
+		"SELECT version FROM category_template_applications WHERE household_id = ? AND template_key = ? FOR UPDATE",
+		householdID, template.Key,
+	).Scan(&previous)
+	if err != nil && err != sql.ErrNoRows {
+		return summary, fmt.Errorf("failed to look up template %s: %v", template.Key, err)
+	}
+	if err == nil {
+		summary.PreviousVersion = &previous
+		if previous >= template.Version && !force {
+			summary.UpToDate = true
+			return summary, nil
+		}
+	}
+
+	var sortOrder int
+	if err := tx.QueryRow("SELECT COALESCE(MAX(sort_order) + 1, 0) FROM categories WHERE household_id = ?", householdID).Scan(&sortOrder); err != nil {
+		return summary, fmt.Errorf("failed to compute category sort order: %v", err)
+	}
+
+	for _, category := range template.Categories {
+		var categoryID int64
+		err := tx.QueryRow("SELECT id FROM categories WHERE household_id = ? AND name = ?", householdID, category.Name).Scan(&categoryID)
+		if err != nil && err != sql.ErrNoRows {
+			return summary, fmt.Errorf("failed to look up category %s: %v", category.Name, err)
+		}
+
+		if err == nil {
+			summary.CategoriesMatched++
+		} else {
+			result, err := tx.Exec(
+				"INSERT INTO categories (household_id, name, color, icon, sort_order) VALUES (?, ?, ?, ?, ?)",
+				householdID, category.Name, nullIfEmpty(&category.Color), nullIfEmpty(&category.Icon), sortOrder,
+			)
+			if err != nil {
+				return summary, fmt.Errorf("failed to create category %s: %v", category.Name, err)
+			}
+			if categoryID, err = result.LastInsertId(); err != nil {
+				return summary, fmt.Errorf("failed to get last insert ID: %v", err)
+			}
+			sortOrder++
+			summary.CategoriesCreated++
+		}
+
+		var subcategoryOrder int
+		if err := tx.QueryRow("SELECT COALESCE(MAX(sort_order) + 1, 0) FROM subcategories WHERE category_id = ?", categoryID).Scan(&subcategoryOrder); err != nil {
+			return summary, fmt.Errorf("failed to compute subcategory sort order: %v", err)
+		}
+
+		for _, name := range category.Subcategories {
+			var existingID int
+			err := tx.QueryRow("SELECT id FROM subcategories WHERE category_id = ? AND name = ?", categoryID, name).Scan(&existingID)
+			if err == nil {
+				summary.SubcategoriesMatched++
+				continue
+			} else if err != sql.ErrNoRows {
+				return summary, fmt.Errorf("failed to look up subcategory %s: %v", name, err)
+			}
+
+			if _, err := tx.Exec("INSERT INTO subcategories (name, category_id, sort_order) VALUES (?, ?, ?)", name, categoryID, subcategoryOrder); err != nil {
+				return summary, fmt.Errorf("failed to create subcategory %s: %v", name, err)
+			}
+			subcategoryOrder++
+			summary.SubcategoriesCreated++
+		}
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO category_template_applications (household_id, template_key, version)
+		VALUES (?, ?, ?)
+		ON DUPLICATE KEY UPDATE version = VALUES(version), applied_at = CURRENT_TIMESTAMP
+	`, householdID, template.Key, template.Version)
+	if err != nil {
+		return summary, fmt.Errorf("failed to record template %s: %v", template.Key, err)
+	}
+
+	return summary, nil
+}
+
+// defaultCategoryTemplate is the template named by SEED_CATEGORY_TEMPLATE,
+// or nil when none is configured.
+func defaultCategoryTemplate() (*CategoryTemplate, error) {
+	key := strings.TrimSpace(os.Getenv("SEED_CATEGORY_TEMPLATE"))
+	if key == "" {
+		return nil, nil
+	}
+	template := findCategoryTemplate(key)
+	if template == nil {
+		return nil, fmt.Errorf("unknown SEED_CATEGORY_TEMPLATE %q", key)
+	}
+	return template, nil
+}
+
+// seedEmptyHouseholds applies the configured template to every household
+// that has no categories yet, which covers a fresh installation.
+func seedEmptyHouseholds() error {
+	template, err := defaultCategoryTemplate()
+	if err != nil || template == nil {
+		return err
+	}
+
+	rows, err := db.Query("SELECT h.id FROM households h WHERE NOT EXISTS (SELECT 1 FROM categories c WHERE c.household_id = h.id)")
+	if err != nil {
+		return fmt.Errorf("failed to query empty households: %v", err)
+	}
+	var householdIDs []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan household: %v", err)
+		}
+		householdIDs = append(householdIDs, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating empty households: %v", err)
+	}
+
+	for _, id := range householdIDs {
+		tx, err := db.Begin()
+		if err != nil {
+			return fmt.Errorf("failed to begin seed transaction: %v", err)
+		}
+		summary, err := applyCategoryTemplate(tx, id, *template, false)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("failed to commit seed for household %d: %v", id, err)
+		}
+		logger.Info(fmt.Sprintf("Seeded household %d with %s v%d: %d categories, %d subcategories", id, template.Key, template.Version, summary.CategoriesCreated, summary.SubcategoriesCreated))
+	}
+
+	return nil
+}
+
+func categoryTemplatesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	applied := make(map[string]int)
+	rows, err := db.Query("SELECT template_key, version FROM category_template_applications WHERE household_id = ?", householdID(r))
+	if err != nil {
+		logger.Error(fmt.Sprintf("Failed to query applied templates: %v", err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var key string
+		var version int
+		if err := rows.Scan(&key, &version); err != nil {
+			logger.Error(fmt.Sprintf("Failed to scan applied template: %v", err))
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		applied[key] = version
+	}
+	if err := rows.Err(); err != nil {
+		logger.Error(fmt.Sprintf("Error iterating applied templates: %v", err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	templates := []map[string]interface{}{}
+	for _, template := range categoryTemplates {
+		var appliedVersion *int
+		if version, ok := applied[template.Key]; ok {
+			appliedVersion = &version
+		}
+		templates = append(templates, map[string]interface{}{
+			"key":             template.Key,
+			"name":            template.Name,
+			"version":         template.Version,
+			"applied_version": appliedVersion,
+			"categories":      template.Categories,
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(templates)
+}
+
+// applyCategoryTemplateHandler applies a template to the caller's household.
+// ADMIN only; households created through POST /api/v1/households are seeded
+// there instead, so no one writes into a household they do not belong to.
+func applyCategoryTemplateHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if currentUser(r).Role != "ADMIN" {
+		http.Error(w, "Only admins can apply category templates", http.StatusForbidden)
+		return
+	}
+
+	key := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/api/v1/category-templates/"), "/apply")
+	template := findCategoryTemplate(key)
+	if template == nil || !strings.HasSuffix(r.URL.Path, "/apply") {
+		http.Error(w, "Template not found", http.StatusNotFound)
+		return
+	}
+
+	var requestBody struct {
+		Force bool `json:"force"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
+			http.Error(w, "Invalid JSON body", http.StatusBadRequest)
+			return
+		}
+	}
+
+	target := householdID(r)
+
+	tx, err := db.Begin()
+	if err != nil {
+		logger.Error(fmt.Sprintf("Failed to begin template transaction: %v", err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	summary, err := applyCategoryTemplate(tx, target, *template, requestBody.Force)
+	if err != nil {
+		logger.Error(err.Error())
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		logger.Error(fmt.Sprintf("Failed to commit template: %v", err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	logger.Info(fmt.Sprintf("Applied template %s v%d to household %d: %d categories, %d subcategories created", template.Key, template.Version, target, summary.CategoriesCreated, summary.SubcategoriesCreated))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message":      "Template applied successfully",
+		"household_id": target,
+		"summary":      summary,
+	})
+}
@@ -0,0 +1,385 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// CategoryNode is a category in the household's tree. Total and Count are the
+// category's own expenses (through its subcategories); the rollup fields add
+// every descendant category.
+type CategoryNode struct {
+	ID            int                `json:"id"`
+	Name          string             `json:"name"`
+	ParentID      *int               `json:"parent_id"`
+	Color         *string            `json:"color"`
+	Icon          *string            `json:"icon"`
+	Archived      bool               `json:"archived"`
+	Depth         int                `json:"depth"`
+	Total         float64            `json:"total"`
+	Count         int                `json:"count"`
+	RollupTotal   float64            `json:"rollup_total"`
+	RollupCount   int                `json:"rollup_count"`
+	Subcategories []SubcategoryGroup `json:"subcategories"`
+	Children      []*CategoryNode    `json:"children"`
+}
+
+type SubcategoryGroup struct {
+	ID    int     `json:"id"`
+	Name  string  `json:"name"`
+	Total float64 `json:"total"`
+	Count int     `json:"count"`
+}
+
+// loadCategoryTree returns the household's root categories with their
+// children, plus an index of every node by ID. Siblings follow sort_order,
+// then name. Archived categories are included, since reports still cover them.
+func loadCategoryTree(householdID int) ([]*CategoryNode, map[int]*CategoryNode, error) {
+	rows, err := db.Query("SELECT id, name, parent_id, color, icon, archived FROM categories WHERE household_id = ? ORDER BY sort_order, name, id", householdID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to query category tree: %v", err)
+	}
+	defer rows.Close()
+
+	var nodes []*CategoryNode
+	for rows.Next() {
+		var node CategoryNode
+		var parentID sql.NullInt64
+		if err := rows.Scan(&node.ID, &node.Name, &parentID, &node.Color, &node.Icon, &node.Archived); err != nil {
+			return nil, nil, fmt.Errorf("failed to scan category tree row: %v", err)
+		}
+		node.ParentID = nullableInt(parentID)
+		node.Subcategories = []SubcategoryGroup{}
+		node.Children = []*CategoryNode{}
+		nodes = append(nodes, &node)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, fmt.Errorf("error iterating category tree rows: %v", err)
+	}
+
+	roots, index := buildCategoryTree(nodes)
+	return roots, index, nil
+}
+
+func buildCategoryTree(nodes []*CategoryNode) ([]*CategoryNode, map[int]*CategoryNode) {
+	index := make(map[int]*CategoryNode, len(nodes))
+	for _, node := range nodes {
+		index[node.ID] = node
+	}
+
+	roots := []*CategoryNode{}
+	for _, node := range nodes {
+		if node.ParentID != nil {
+			if parent, ok := index[*node.ParentID]; ok {
+				parent.Children = append(parent.Children, node)
+				continue
+			}
+		}
+		roots = append(roots, node)
+	}
+
+	var setDepth func(nodes []*CategoryNode, depth int)
+	setDepth = func(nodes []*CategoryNode, depth int) {
+		for _, node := range nodes {
+			node.Depth = depth
+			setDepth(node.Children, depth+1)
+		}
+	}
+	setDepth(roots, 0)
+
+	return roots, index
+}
+
+// rollUpCategoryTree adds each node's descendants into its rollup totals.
+func rollUpCategoryTree(nodes []*CategoryNode) {
+	for _, node := range nodes {
+		rollUpCategoryTree(node.Children)
+		node.RollupTotal = node.Total
+		node.RollupCount = node.Count
+		for _, child := range node.Children {
+			node.RollupTotal += child.RollupTotal
+			node.RollupCount += child.RollupCount
+		}
+		node.RollupTotal = math.Round(node.RollupTotal*100) / 100
+	}
+}
+
+// archivedSubtrees returns the IDs of archived categories and of every
+// category below one, which the picker lists leave out together so no listed
+// category points at a hidden parent.
+func archivedSubtrees(roots []*CategoryNode) map[int]bool {
+	hidden := make(map[int]bool)
+	var walk func(nodes []*CategoryNode, parentHidden bool)
+	walk = func(nodes []*CategoryNode, parentHidden bool) {
+		for _, node := range nodes {
+			isHidden := parentHidden || node.Archived
+			if isHidden {
+				hidden[node.ID] = true
+			}
+			walk(node.Children, isHidden)
+		}
+	}
+	walk(roots, false)
+	return hidden
+}
+
+// isCategoryDescendant reports whether candidate is categoryID itself or sits
+// anywhere below it.
+func isCategoryDescendant(index map[int]*CategoryNode, categoryID, candidate int) bool {
+	seen := make(map[int]bool)
+	for id := candidate; !seen[id]; {
+		if id == categoryID {
+			return true
+		}
+		seen[id] = true
+		node, ok := index[id]
+		if !ok || node.ParentID == nil {
+			return false
+		}
+		id = *node.ParentID
+	}
+	return false
+}
+
+// writeCategoryGroups adds tree roll-ups to the category grouping: each
+// group gets the totals of every category below it, and categories with no
+// expenses of their own but some below them are appended with a zero total,
+// so every node of the tree that has spending is reported.
+func writeCategoryGroups(w http.ResponseWriter, householdID int, groups []ExpenseGroup) {
+	roots, index, err := loadCategoryTree(householdID)
+	if err != nil {
+		logger.Error(err.Error())
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	listed := make(map[int]bool, len(groups))
+	for _, group := range groups {
+		if node, ok := index[group.ID]; ok {
+			node.Total = group.Total
+			node.Count = group.Count
+		}
+		listed[group.ID] = true
+	}
+	rollUpCategoryTree(roots)
+
+	rows := []map[string]interface{}{}
+	row := func(id int, name string, total float64, count int) map[string]interface{} {
+		entry := map[string]interface{}{
+			"id":           id,
+			"group_name":   name,
+			"total":        total,
+			"count":        count,
+			"parent_id":    nil,
+			"rollup_total": total,
+			"rollup_count": count,
+		}
+		if node, ok := index[id]; ok {
+			entry["parent_id"] = node.ParentID
+			entry["rollup_total"] = node.RollupTotal
+			entry["rollup_count"] = node.RollupCount
+		}
+		return entry
+	}
+
+	for _, group := range groups {
+		rows = append(rows, row(group.ID, group.Name, group.Total, group.Count))
+	}
+
+	var appendAncestors func(nodes []*CategoryNode)
+	appendAncestors = func(nodes []*CategoryNode) {
+		for _, node := range nodes {
+			if !listed[node.ID] && node.RollupCount > 0 {
+				rows = append(rows, row(node.ID, node.Name, 0, 0))
+			}
+			appendAncestors(node.Children)
+		}
+	}
+	appendAncestors(roots)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(rows)
+}
+
+// categoryTreeHandler reports expense totals for every category in the tree,
+// with roll-ups, and per subcategory. It accepts the expense list filters.
+func categoryTreeHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	filter, err := parseExpenseFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	roots, index, err := loadCategoryTree(householdID(r))
+	if err != nil {
+		logger.Error(err.Error())
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	query := `
+		SELECT s.category_id, s.id, s.name, COALESCE(SUM(e.amount), 0), COUNT(e.id)
+		FROM subcategories s
+		JOIN expenses e ON e.subcategory_id = s.id
+	`
+	conditions, args := filter.conditions()
+	query += " WHERE " + strings.Join(conditions, " AND ") + " GROUP BY s.category_id, s.id, s.name"
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		logger.Error(fmt.Sprintf("Failed to query category tree totals: %v", err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var categoryID int
+		var group SubcategoryGroup
+		if err := rows.Scan(&categoryID, &group.ID, &group.Name, &group.Total, &group.Count); err != nil {
+			logger.Error(fmt.Sprintf("Failed to scan category tree totals: %v", err))
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		node, ok := index[categoryID]
+		if !ok {
+			continue
+		}
+		node.Subcategories = append(node.Subcategories, group)
+		node.Total += group.Total
+		node.Count += group.Count
+	}
+	if err := rows.Err(); err != nil {
+		logger.Error(fmt.Sprintf("Error iterating category tree totals: %v", err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	for _, node := range index {
+		node.Total = math.Round(node.Total*100) / 100
+		sort.Slice(node.Subcategories, func(i, j int) bool {
+			return node.Subcategories[i].Total > node.Subcategories[j].Total
+		})
+	}
+	rollUpCategoryTree(roots)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(roots)
+}
+
+// lockCategoryParents reads the household's category tree inside tx,
+// locking every category row until the transaction ends.
+func lockCategoryParents(tx *sql.Tx, householdID int) (map[int]*CategoryNode, error) {
+	rows, err := tx.Query("SELECT id, name, parent_id FROM categories WHERE household_id = ? ORDER BY id FOR UPDATE", householdID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock categories: %v", err)
+	}
+	defer rows.Close()
+
+	var nodes []*CategoryNode
+	for rows.Next() {
+		var node CategoryNode
+		var parentID sql.NullInt64
+		if err := rows.Scan(&node.ID, &node.Name, &parentID); err != nil {
+			return nil, fmt.Errorf("failed to scan category: %v", err)
+		}
+		node.ParentID = nullableInt(parentID)
+		node.Children = []*CategoryNode{}
+		nodes = append(nodes, &node)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating categories: %v", err)
+	}
+
+	_, index := buildCategoryTree(nodes)
+	return index, nil
+}
+
+// moveCategoryHandler reparents a category; a null parent_id makes it a root.
+// Moving a category under itself or one of its descendants is rejected.
+func moveCategoryHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	categoryID, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/api/v1/categories/"), "/move"))
+	if err != nil {
+		http.Error(w, "Invalid category ID", http.StatusBadRequest)
+		return
+	}
+
+	var requestBody struct {
+		ParentID *int `json:"parent_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
+		http.Error(w, "Invalid JSON body", http.StatusBadRequest)
+		return
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		logger.Error(fmt.Sprintf("Failed to begin move transaction: %v", err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	// Locking every category of the household serializes moves, so two
+	// concurrent moves cannot each pass the cycle check and together form a
+	// cycle.
+	index, err := lockCategoryParents(tx, householdID(r))
+	if err != nil {
+		logger.Error(err.Error())
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	if _, ok := index[categoryID]; !ok {
+		http.Error(w, "Category not found", http.StatusNotFound)
+		return
+	}
+
+	if requestBody.ParentID != nil {
+		if _, ok := index[*requestBody.ParentID]; !ok {
+			http.Error(w, fmt.Sprintf("Unknown parent_id: %d", *requestBody.ParentID), http.StatusBadRequest)
+			return
+		}
+		if isCategoryDescendant(index, categoryID, *requestBody.ParentID) {
+			http.Error(w, "Cannot move a category under itself or one of its descendants", http.StatusBadRequest)
+			return
+		}
+	}
+
+	if _, err := tx.Exec("UPDATE categories SET parent_id = ? WHERE id = ?", requestBody.ParentID, categoryID); err != nil {
+		logger.Error(fmt.Sprintf("Failed to move category: %v", err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		logger.Error(fmt.Sprintf("Failed to commit category move: %v", err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	invalidateExpenseCaches()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message":   "Category moved successfully",
+		"id":        categoryID,
+		"parent_id": requestBody.ParentID,
+	})
+}
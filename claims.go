@@ -0,0 +1,955 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Reimbursement claim statuses. A claim moves draft → submitted → approved →
+// paid; an admin can send a submitted claim back to draft.
+const (
+	claimDraft     = "draft"
+	claimSubmitted = "submitted"
+	claimApproved  = "approved"
+	claimPaid      = "paid"
+)
+
+type Claim struct {
+	ID           int       `json:"id"`
+	UserID       int       `json:"user_id"`
+	UserName     string    `json:"user_name"`
+	Title        string    `json:"title"`
+	Status       string    `json:"status"`
+	ExpenseCount int       `json:"expense_count"`
+	Total        float64   `json:"total"`
+	SubmittedAt  *string   `json:"submitted_at"`
+	ApprovedAt   *string   `json:"approved_at"`
+	ApprovedBy   *int      `json:"approved_by"`
+	PaidAt       *string   `json:"paid_at"`
+	CreatedAt    string    `json:"created_at"`
+	Expenses     []Expense `json:"expenses,omitempty"`
+}
+
+type ClaimRequest struct {
+	UserID     *int    `json:"user_id"`
+	Title      *string `json:"title"`
+	ExpenseIDs *[]int  `json:"expense_ids"`
+}
+
+// claimTransition is one step of the workflow: the status a claim must be
+// in, the status it moves to, and the timestamp column it stamps.
+type claimTransition struct {
+	From      string
+	To        string
+	Stamp     string
+	AdminOnly bool
+}
+
+var claimTransitions = map[string]claimTransition{
+	"submit":  {From: claimDraft, To: claimSubmitted, Stamp: "submitted_at"},
+	"approve": {From: claimSubmitted, To: claimApproved, Stamp: "approved_at", AdminOnly: true},
+	"reject":  {From: claimSubmitted, To: claimDraft, AdminOnly: true},
+	"pay":     {From: claimApproved, To: claimPaid, Stamp: "paid_at", AdminOnly: true},
+}
+
+const claimSelect = `
+	SELECT c.id, c.user_id, c.title, c.status,
+		(SELECT COUNT(*) FROM expenses e WHERE e.claim_id = c.id),
+		(SELECT COALESCE(SUM(e.amount), 0) FROM expenses e WHERE e.claim_id = c.id),
+		c.submitted_at, c.approved_at, c.approved_by, c.paid_at, c.created_at
+	FROM reimbursement_claims c
+`
+
+func scanClaim(scanner interface{ Scan(...interface{}) error }) (Claim, error) {
+	var claim Claim
+	err := scanner.Scan(
+		&claim.ID,
+		&claim.UserID,
+		&claim.Title,
+		&claim.Status,
+		&claim.ExpenseCount,
+		&claim.Total,
+		&claim.SubmittedAt,
+		&claim.ApprovedAt,
+		&claim.ApprovedBy,
+		&claim.PaidAt,
+		&claim.CreatedAt,
+	)
+	return claim, err
+}
+
+func getClaim(householdID, claimID int) (*Claim, error) {
+	row := db.QueryRow(claimSelect+" WHERE c.id = ? AND c.household_id = ?", claimID, householdID)
+	claim, err := scanClaim(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query claim: %v", err)
+	}
+
+	names, err := loadUserNames(householdID)
+	if err != nil {
+		return nil, err
+	}
+	claim.UserName = names[claim.UserID]
+
+	return &claim, nil
+}
+
+// queryClaims lists the household's claims, optionally narrowed to a status
+// and a claimant, newest first.
+func queryClaims(householdID int, status string, userID *int) ([]Claim, error) {
+	query := claimSelect + " WHERE c.household_id = ?"
+	args := []interface{}{householdID}
+
+	if status != "" {
+		query += " AND c.status = ?"
+		args = append(args, status)
+	}
+	if userID != nil {
+		query += " AND c.user_id = ?"
+		args = append(args, *userID)
+	}
+	query += " ORDER BY c.created_at DESC, c.id DESC"
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query claims: %v", err)
+	}
+	defer rows.Close()
+
+	names, err := loadUserNames(householdID)
+	if err != nil {
+		return nil, err
+	}
+
+	claims := []Claim{}
+	for rows.Next() {
+		claim, err := scanClaim(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan claim row: %v", err)
+		}
+		claim.UserName = names[claim.UserID]
+		claims = append(claims, claim)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over claim rows: %v", err)
+	}
+
+	return claims, nil
+}
+
+// parseClaimListParams reads the status and user_id filters shared by the
+// claim list and its export.
+func parseClaimListParams(r *http.Request) (string, *int, error) {
+	status := r.URL.Query().Get("status")
+	switch status {
+	case "", claimDraft, claimSubmitted, claimApproved, claimPaid:
+	default:
+		return "", nil, fmt.Errorf("Invalid status parameter. Must be 'draft', 'submitted', 'approved' or 'paid'")
+	}
+
+	var userID *int
+	if userIDStr := r.URL.Query().Get("user_id"); userIDStr != "" {
+		value, err := strconv.Atoi(userIDStr)
+		if err != nil {
+			return "", nil, fmt.Errorf("Invalid user_id parameter")
+		}
+		userID = &value
+	}
+
+	return status, userID, nil
+}
+
+// expenseClaimStatus returns the status of the claim the expense is on, or ""
+// when it is not on one. It locks the expense and its claim until q's transaction
+// ends, so the claim cannot be submitted while the expense is being changed.
+func expenseClaimStatus(q sqlQueryer, expenseID int) (string, error) {
+	var status sql.NullString
+	err := q.QueryRow(`
+		SELECT c.status
+		FROM expenses e
+		LEFT JOIN reimbursement_claims c ON c.id = e.claim_id
+		WHERE e.id = ?
+		FOR UPDATE
+	`, expenseID).Scan(&status)
+	if err != nil && err != sql.ErrNoRows {
+		return "", fmt.Errorf("failed to query expense claim: %v", err)
+	}
+	return status.String, nil
+}
+
+// expenseLocked reports whether the expense is on a claim that has been
+// submitted, and locks it like expenseClaimStatus. Changes to many expenses
+// at once use it to skip locked ones instead of failing.
+func expenseLocked(tx *sql.Tx, expenseID int) (bool, error) {
+	status, err := expenseClaimStatus(tx, expenseID)
+	if err != nil {
+		return false, err
+	}
+	return status != "" && status != claimDraft, nil
+}
+
+// checkExpenseUnlocked answers 409 and returns false when the expense is on a
+// claim that has been submitted, since its amount is then part of the claim.
+// It runs inside the transaction that changes the expense.
+func checkExpenseUnlocked(w http.ResponseWriter, tx *sql.Tx, expenseID int) bool {
+	status, err := expenseClaimStatus(tx, expenseID)
+	if err != nil {
+		logger.Error(err.Error())
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return false
+	}
+	if status != "" && status != claimDraft {
+		http.Error(w, fmt.Sprintf("Expense is on a %s reimbursement claim and cannot be changed", status), http.StatusConflict)
+		return false
+	}
+	return true
+}
+
+// detachFromClaim takes an expense off its draft claim once it is no longer a
+// reimbursable expense of the claimant.
+func detachFromClaim(exec sqlExecer, expenseID int) error {
+	_, err := exec.Exec(`
+		UPDATE expenses e
+		JOIN reimbursement_claims c ON c.id = e.claim_id
+		SET e.claim_id = NULL
+		WHERE e.id = ? AND (e.reimbursable = FALSE OR NOT (e.user_id <=> c.user_id))
+	`, expenseID)
+	if err != nil {
+		return fmt.Errorf("failed to detach expense from claim: %v", err)
+	}
+	return nil
+}
+
+// setClaimExpenses replaces the expenses on a draft claim. Each one has to be a
+// reimbursable expense of the claimant that is not on another claim.
+func setClaimExpenses(tx *sql.Tx, householdID, claimID, claimantID int, expenseIDs []int) error {
+	if _, err := tx.Exec("UPDATE expenses SET claim_id = NULL WHERE claim_id = ?", claimID); err != nil {
+		return fmt.Errorf("failed to clear claim expenses: %v", err)
+	}
+
+	if len(expenseIDs) == 0 {
+		return nil
+	}
+
+	args := []interface{}{householdID}
+	for _, id := range expenseIDs {
+		args = append(args, id)
+	}
+
+	rows, err := tx.Query(fmt.Sprintf(`
+		SELECT id, user_id, reimbursable, claim_id
+		FROM expenses
+		WHERE household_id = ? AND id IN (%s)
+		FOR UPDATE
+	`, placeholders(len(expenseIDs))), args...)
+	if err != nil {
+		return fmt.Errorf("failed to query claim expenses: %v", err)
+	}
+
+	found := make(map[int]bool)
+	var claimError error
+	for rows.Next() {
+		var id int
+		var userID, otherClaimID sql.NullInt64
+		var reimbursable bool
+		if err := rows.Scan(&id, &userID, &reimbursable, &otherClaimID); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan claim expense row: %v", err)
+		}
+		found[id] = true
+
+		if claimError != nil {
+			continue
+		}
+		if !reimbursable {
+			claimError = claimRequestError{fmt.Sprintf("Expense %d is not marked as reimbursable", id)}
+		} else if !userID.Valid || int(userID.Int64) != claimantID {
+			claimError = claimRequestError{fmt.Sprintf("Expense %d does not belong to user %d", id, claimantID)}
+		} else if otherClaimID.Valid {
+			claimError = claimRequestError{fmt.Sprintf("Expense %d is already on claim %d", id, otherClaimID.Int64)}
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating over claim expense rows: %v", err)
+	}
+	if claimError != nil {
+		return claimError
+	}
+
+	for _, id := range expenseIDs {
+		if !found[id] {
+			return unknownRefError{field: "expense_id", id: id}
+		}
+	}
+
+	_, err = tx.Exec(
+		fmt.Sprintf("UPDATE expenses SET claim_id = ? WHERE id IN (%s)", placeholders(len(expenseIDs))),
+		append([]interface{}{claimID}, args[1:]...)...,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to add claim expenses: %v", err)
+	}
+
+	return nil
+}
+
+// claimRequestError is an expense that cannot go on the claim.
+type claimRequestError struct {
+	message string
+}
+
+func (e claimRequestError) Error() string {
+	return e.message
+}
+
+// writeClaimError answers for a failed setClaimExpenses.
+func writeClaimError(w http.ResponseWriter, err error) {
+	if _, ok := err.(claimRequestError); ok {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	writeRefError(w, err)
+}
+
+func normalizeClaimTitle(title string) (string, error) {
+	title = strings.TrimSpace(title)
+	if title == "" || len(title) > 255 {
+		return "", fmt.Errorf("Title must be 1-255 characters")
+	}
+	return title, nil
+}
+
+func getClaimsHandler(w http.ResponseWriter, r *http.Request) {
+	status, userID, err := parseClaimListParams(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	claims, err := queryClaims(householdID(r), status, userID)
+	if err != nil {
+		logger.Error(err.Error())
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(claims)
+}
+
+func createClaimHandler(w http.ResponseWriter, r *http.Request) {
+	var req ClaimRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON body", http.StatusBadRequest)
+		return
+	}
+
+	if req.UserID == nil || *req.UserID <= 0 {
+		http.Error(w, "user_id is required", http.StatusBadRequest)
+		return
+	}
+	if req.Title == nil {
+		http.Error(w, "Title is required", http.StatusBadRequest)
+		return
+	}
+	title, err := normalizeClaimTitle(*req.Title)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if writeRefError(w, checkHouseholdRefs(householdID(r), householdRef{"user_id", "users", req.UserID})) {
+		return
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		logger.Error(fmt.Sprintf("Failed to begin transaction: %v", err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(
+		"INSERT INTO reimbursement_claims (household_id, user_id, title, status) VALUES (?, ?, ?, ?)",
+		householdID(r), *req.UserID, title, claimDraft,
+	)
+	if err != nil {
+		logger.Error(fmt.Sprintf("Failed to create claim: %v", err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	claimID, err := result.LastInsertId()
+	if err != nil {
+		logger.Error(fmt.Sprintf("Failed to get last insert ID: %v", err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	if req.ExpenseIDs != nil {
+		if err := setClaimExpenses(tx, householdID(r), int(claimID), *req.UserID, *req.ExpenseIDs); err != nil {
+			writeClaimError(w, err)
+			return
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		logger.Error(fmt.Sprintf("Failed to commit claim: %v", err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	claim, err := getClaim(householdID(r), int(claimID))
+	if err != nil || claim == nil {
+		logger.Error(fmt.Sprintf("Failed to load created claim: %v", err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(claim)
+}
+
+func getClaimHandler(w http.ResponseWriter, r *http.Request, claim *Claim) {
+	expenses, err := queryExpenses(ExpenseFilter{HouseholdID: householdID(r), ClaimID: &claim.ID}, "e.created_at", "ASC")
+	if err != nil {
+		logger.Error(err.Error())
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if expenses == nil {
+		expenses = []Expense{}
+	}
+	claim.Expenses = expenses
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(claim)
+}
+
+func updateClaimHandler(w http.ResponseWriter, r *http.Request, claim *Claim) {
+	var req ClaimRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON body", http.StatusBadRequest)
+		return
+	}
+
+	if req.UserID != nil && *req.UserID != claim.UserID {
+		http.Error(w, "A claim's user_id cannot be changed", http.StatusBadRequest)
+		return
+	}
+	if req.Title == nil && req.ExpenseIDs == nil {
+		http.Error(w, "Nothing to update", http.StatusBadRequest)
+		return
+	}
+
+	if claim.Status != claimDraft {
+		http.Error(w, fmt.Sprintf("Claim is %s; only draft claims can be changed", claim.Status), http.StatusConflict)
+		return
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		logger.Error(fmt.Sprintf("Failed to begin transaction: %v", err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	// Locking the claim row keeps a concurrent submit from slipping in
+	// between the status check and the expense changes.
+	var status string
+	if err := tx.QueryRow("SELECT status FROM reimbursement_claims WHERE id = ? FOR UPDATE", claim.ID).Scan(&status); err != nil {
+		logger.Error(fmt.Sprintf("Failed to lock claim: %v", err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if status != claimDraft {
+		http.Error(w, fmt.Sprintf("Claim is %s; only draft claims can be changed", status), http.StatusConflict)
+		return
+	}
+
+	if req.Title != nil {
+		title, err := normalizeClaimTitle(*req.Title)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if _, err := tx.Exec("UPDATE reimbursement_claims SET title = ? WHERE id = ?", title, claim.ID); err != nil {
+			logger.Error(fmt.Sprintf("Failed to update claim: %v", err))
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if req.ExpenseIDs != nil {
+		if err := setClaimExpenses(tx, householdID(r), claim.ID, claim.UserID, *req.ExpenseIDs); err != nil {
+			writeClaimError(w, err)
+			return
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		logger.Error(fmt.Sprintf("Failed to commit claim update: %v", err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	updated, err := getClaim(householdID(r), claim.ID)
+	if err != nil || updated == nil {
+		logger.Error(fmt.Sprintf("Failed to load updated claim: %v", err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(updated)
+}
+
+func deleteClaimHandler(w http.ResponseWriter, r *http.Request, claim *Claim) {
+	// Expenses on the claim stay reimbursable and are simply unclaimed again.
+	result, err := db.Exec("DELETE FROM reimbursement_claims WHERE id = ? AND status = ?", claim.ID, claimDraft)
+	if err != nil {
+		logger.Error(fmt.Sprintf("Failed to delete claim: %v", err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if deleted, _ := result.RowsAffected(); deleted == 0 {
+		http.Error(w, "Only draft claims can be deleted", http.StatusConflict)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "Claim deleted successfully",
+		"id":      claim.ID,
+	})
+}
+
+func claimTransitionHandler(w http.ResponseWriter, r *http.Request, claim *Claim, action string) {
+	transition := claimTransitions[action]
+
+	caller := currentUser(r)
+	if transition.AdminOnly && caller.Role != "ADMIN" {
+		http.Error(w, fmt.Sprintf("Only admins can %s claims", action), http.StatusForbidden)
+		return
+	}
+
+	// Only the claimant, or an admin on their behalf, submits a claim; whoever
+	// approves or pays it has to be someone other than the claimant, even
+	// when the claimant is an admin.
+	if action == "submit" && caller.ID != claim.UserID && caller.Role != "ADMIN" {
+		http.Error(w, "Only the claimant or an admin can submit a claim", http.StatusForbidden)
+		return
+	}
+	if (action == "approve" || action == "pay") && caller.ID == claim.UserID {
+		http.Error(w, fmt.Sprintf("You cannot %s your own claim", action), http.StatusForbidden)
+		return
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		logger.Error(fmt.Sprintf("Failed to begin transaction: %v", err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	// Locking the claim and then its expenses keeps a concurrent detach from
+	// emptying the claim between the count and the submit.
+	var status string
+	if err := tx.QueryRow("SELECT status FROM reimbursement_claims WHERE id = ? FOR UPDATE", claim.ID).Scan(&status); err != nil {
+		logger.Error(fmt.Sprintf("Failed to lock claim: %v", err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if status != transition.From {
+		http.Error(w, fmt.Sprintf("Only %s claims can be moved to %s", transition.From, transition.To), http.StatusConflict)
+		return
+	}
+
+	if action == "submit" {
+		var expenseCount int
+		if err := tx.QueryRow("SELECT COUNT(*) FROM expenses WHERE claim_id = ? FOR UPDATE", claim.ID).Scan(&expenseCount); err != nil {
+			logger.Error(fmt.Sprintf("Failed to count claim expenses: %v", err))
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		if expenseCount == 0 {
+			http.Error(w, "Cannot submit a claim without expenses", http.StatusBadRequest)
+			return
+		}
+	}
+
+	sets := []string{"status = ?"}
+	args := []interface{}{transition.To}
+	switch action {
+	case "approve":
+		sets = append(sets, "approved_by = ?")
+		args = append(args, caller.ID)
+	case "reject":
+		sets = append(sets, "submitted_at = NULL")
+	}
+	if transition.Stamp != "" {
+		sets = append(sets, transition.Stamp+" = UTC_TIMESTAMP()")
+	}
+
+	if _, err := tx.Exec("UPDATE reimbursement_claims SET "+strings.Join(sets, ", ")+" WHERE id = ?", append(args, claim.ID)...); err != nil {
+		logger.Error(fmt.Sprintf("Failed to %s claim: %v", action, err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		logger.Error(fmt.Sprintf("Failed to commit claim %s: %v", action, err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	logger.Info(fmt.Sprintf("Claim %d moved from %s to %s by user %d", claim.ID, transition.From, transition.To, caller.ID))
+
+	updated, err := getClaim(householdID(r), claim.ID)
+	if err != nil || updated == nil {
+		logger.Error(fmt.Sprintf("Failed to load claim after %s: %v", action, err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(updated)
+}
+
+// parseClaimPath splits /api/v1/claims/{id}[/{action}].
+func parseClaimPath(path string) (int, string, error) {
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(path, "/api/v1/claims/"), "/"), "/")
+	if len(parts) > 2 {
+		return 0, "", fmt.Errorf("Not found")
+	}
+
+	claimID, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, "", fmt.Errorf("Invalid claim ID")
+	}
+
+	action := ""
+	if len(parts) == 2 {
+		action = parts[1]
+		if _, ok := claimTransitions[action]; !ok {
+			return 0, "", fmt.Errorf("Unknown claim action %q", action)
+		}
+	}
+
+	return claimID, action, nil
+}
+
+func claimsHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		getClaimsHandler(w, r)
+	case http.MethodPost:
+		createClaimHandler(w, r)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func singleClaimHandler(w http.ResponseWriter, r *http.Request) {
+	claimID, action, err := parseClaimPath(r.URL.Path)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	claim, err := getClaim(householdID(r), claimID)
+	if err != nil {
+		logger.Error(err.Error())
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if claim == nil {
+		http.Error(w, "Claim not found", http.StatusNotFound)
+		return
+	}
+
+	if action != "" {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		claimTransitionHandler(w, r, claim, action)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		getClaimHandler(w, r, claim)
+	case http.MethodPut, http.MethodPatch:
+		updateClaimHandler(w, r, claim)
+	case http.MethodDelete:
+		deleteClaimHandler(w, r, claim)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+var claimCSVHeader = []string{
+	"id",
+	"user_id",
+	"user_name",
+	"title",
+	"status",
+	"expense_count",
+	"total",
+	"submitted_at",
+	"approved_at",
+	"paid_at",
+}
+
+func claimCSVRecord(claim Claim) []string {
+	return []string{
+		strconv.Itoa(claim.ID),
+		strconv.Itoa(claim.UserID),
+		claim.UserName,
+		claim.Title,
+		claim.Status,
+		strconv.Itoa(claim.ExpenseCount),
+		formatAmount(claim.Total),
+		optionalString(claim.SubmittedAt),
+		optionalString(claim.ApprovedAt),
+		optionalString(claim.PaidAt),
+	}
+}
+
+// claimXLSXSheets puts the claim totals on a summary sheet, with a grand
+// total per status, followed by one sheet of line items per claim.
+func claimXLSXSheets(claims []Claim, expenses map[int][]Expense) []xlsxSheet {
+	summary := xlsxSheet{
+		Name:   "Claims",
+		Widths: []float64{8, 24, 32, 12, 10, 14},
+		Rows: [][]xlsxCell{{
+			{Text: "Claim", Bold: true},
+			{Text: "User", Bold: true},
+			{Text: "Title", Bold: true},
+			{Text: "Status", Bold: true},
+			{Text: "Expenses", Bold: true},
+			{Text: "Total", Bold: true},
+		}},
+	}
+
+	totals := make(map[string]float64)
+	for _, claim := range claims {
+		count := float64(claim.ExpenseCount)
+		summary.Rows = append(summary.Rows, []xlsxCell{
+			xlsxText(strconv.Itoa(claim.ID)),
+			xlsxText(claim.UserName),
+			xlsxText(claim.Title),
+			xlsxText(claim.Status),
+			{Number: &count},
+			xlsxMoney(claim.Total),
+		})
+		totals[claim.Status] += claim.Total
+	}
+
+	summary.Rows = append(summary.Rows, []xlsxCell{})
+	for _, status := range []string{claimDraft, claimSubmitted, claimApproved, claimPaid} {
+		if total, ok := totals[status]; ok {
+			summary.Rows = append(summary.Rows, []xlsxCell{
+				{Text: "Total " + status, Bold: true}, {}, {}, {}, {},
+				{Number: &total, Bold: true, Money: true},
+			})
+		}
+	}
+
+	sheets := []xlsxSheet{summary}
+	for _, claim := range claims {
+		sheets = append(sheets, statementExpenseSheet(fmt.Sprintf("Claim %d", claim.ID), expenses[claim.ID]))
+	}
+
+	return sheets
+}
+
+func exportClaimsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format != "csv" && format != "xlsx" {
+		http.Error(w, "Invalid format parameter. Must be 'csv' or 'xlsx'", http.StatusBadRequest)
+		return
+	}
+
+	status, userID, err := parseClaimListParams(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	claims, err := queryClaims(householdID(r), status, userID)
+	if err != nil {
+		logger.Error(err.Error())
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	var body bytes.Buffer
+	contentType := "text/csv; charset=utf-8"
+	if format == "csv" {
+		writer := csv.NewWriter(&body)
+		writer.Write(claimCSVHeader)
+		for _, claim := range claims {
+			writer.Write(claimCSVRecord(claim))
+		}
+		writer.Flush()
+		err = writer.Error()
+	} else {
+		contentType = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+		expenses := make(map[int][]Expense)
+		for _, claim := range claims {
+			claimID := claim.ID
+			expenses[claimID], err = queryExpenses(ExpenseFilter{HouseholdID: householdID(r), ClaimID: &claimID}, "e.created_at", "ASC")
+			if err != nil {
+				logger.Error(err.Error())
+				http.Error(w, "Internal server error", http.StatusInternalServerError)
+				return
+			}
+		}
+		err = writeXLSX(&body, claimXLSXSheets(claims, expenses))
+	}
+	if err != nil {
+		logger.Error(fmt.Sprintf("Failed to render %s claim export: %v", format, err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	filename := fmt.Sprintf("claims-%s.%s", time.Now().UTC().Format("2006-01-02"), format)
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", filename))
+	w.Header().Set("Content-Length", strconv.Itoa(body.Len()))
+	w.Write(body.Bytes())
+}
+
+type OutstandingReimbursement struct {
+	UserID      int     `json:"user_id"`
+	UserName    string  `json:"user_name"`
+	Unclaimed   float64 `json:"unclaimed"`
+	Draft       float64 `json:"draft"`
+	Submitted   float64 `json:"submitted"`
+	Approved    float64 `json:"approved"`
+	Outstanding float64 `json:"outstanding"`
+	Count       int     `json:"count"`
+}
+
+// reimbursementsReportHandler sums, per user, the reimbursable expenses that
+// have not been paid back yet, split by how far along their claim is.
+func reimbursementsReportHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	filter, err := parseExpenseFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	reimbursable := true
+	filter.Reimbursable = &reimbursable
+
+	conditions, args := filter.conditions()
+	conditions = append(conditions, "e.user_id IS NOT NULL", "(c.id IS NULL OR c.status <> ?)")
+	args = append(args, claimPaid)
+
+	rows, err := db.Query(`
+		SELECT e.user_id, COALESCE(c.status, ''), COUNT(*), COALESCE(SUM(e.amount), 0)
+		FROM expenses e
+		LEFT JOIN subcategories s ON e.subcategory_id = s.id
+		LEFT JOIN reimbursement_claims c ON c.id = e.claim_id
+		WHERE `+strings.Join(conditions, " AND ")+`
+		GROUP BY e.user_id, c.status
+	`, args...)
+	if err != nil {
+		logger.Error(fmt.Sprintf("Failed to query outstanding reimbursements: %v", err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	byUser := make(map[int]*OutstandingReimbursement)
+	var order []int
+	for rows.Next() {
+		var userID, count int
+		var status string
+		var total float64
+		if err := rows.Scan(&userID, &status, &count, &total); err != nil {
+			logger.Error(fmt.Sprintf("Failed to scan outstanding reimbursement row: %v", err))
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		entry, ok := byUser[userID]
+		if !ok {
+			entry = &OutstandingReimbursement{UserID: userID}
+			byUser[userID] = entry
+			order = append(order, userID)
+		}
+		switch status {
+		case "":
+			entry.Unclaimed += total
+		case claimDraft:
+			entry.Draft += total
+		case claimSubmitted:
+			entry.Submitted += total
+		case claimApproved:
+			entry.Approved += total
+		}
+		entry.Outstanding += total
+		entry.Count += count
+	}
+
+	if err := rows.Err(); err != nil {
+		logger.Error(fmt.Sprintf("Error iterating outstanding reimbursements: %v", err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	names, err := loadUserNames(householdID(r))
+	if err != nil {
+		logger.Error(err.Error())
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	report := []OutstandingReimbursement{}
+	total := 0.0
+	for _, userID := range order {
+		entry := byUser[userID]
+		entry.UserName = names[userID]
+		report = append(report, *entry)
+		total += entry.Outstanding
+	}
+	sort.Slice(report, func(i, j int) bool {
+		return report[i].Outstanding > report[j].Outstanding
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"users": report,
+		"total": total,
+	})
+}
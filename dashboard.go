@@ -0,0 +1,237 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// dashboardWorkers caps how many dashboard queries run at once across
+	// all requests, so a burst of dashboard loads cannot take over the
+	// connection pool.
+	dashboardWorkers       = 4
+	dashboardTimeout       = 5 * time.Second
+	dashboardTopCategories = 5
+	dashboardRecentLimit   = 10
+)
+
+// Dashboard has no budget status: there are no budgets to report on yet, so
+// goal progress is the only target tracking it shows.
+type Dashboard struct {
+	Month                 string         `json:"month"`
+	SpendingTotal         float64        `json:"spending_total"`
+	PreviousSpendingTotal float64        `json:"previous_spending_total"`
+	SpendingChange        float64        `json:"spending_change"`
+	IncomeTotal           float64        `json:"income_total"`
+	Net                   float64        `json:"net"`
+	TopCategories         []ExpenseGroup `json:"top_categories"`
+	RecentExpenses        []Expense      `json:"recent_expenses"`
+	Members               []ExpenseGroup `json:"members"`
+	Goals                 []Goal         `json:"goals"`
+}
+
+// dashboardSlots is shared by every dashboard request, so dashboardWorkers
+// bounds the queries in flight server-wide, not per request.
+var dashboardSlots = make(chan struct{}, dashboardWorkers)
+
+// runConcurrently runs each task on its own goroutine once it takes a slot,
+// all sharing ctx. The first failure cancels the context for the tasks still
+// running and is the error returned.
+func runConcurrently(ctx context.Context, slots chan struct{}, tasks ...func(context.Context) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	var once sync.Once
+	var firstErr error
+
+	for _, task := range tasks {
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+
+		wg.Add(1)
+		go func(task func(context.Context) error) {
+			defer wg.Done()
+			defer func() { <-slots }()
+			if err := task(ctx); err != nil {
+				once.Do(func() {
+					firstErr = err
+					cancel()
+				})
+			}
+		}(task)
+	}
+
+	wg.Wait()
+	if firstErr != nil {
+		return firstErr
+	}
+	return ctx.Err()
+}
+
+// queryMemberTotals lists every household member with their spending in the
+// filter's date range, including members who spent nothing.
+func queryMemberTotals(ctx context.Context, filter ExpenseFilter) ([]ExpenseGroup, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT u.id, COALESCE(u.display_name, u.email), COALESCE(SUM(e.amount), 0), COUNT(e.id)
+		FROM users u
+		LEFT JOIN expenses e ON e.user_id = u.id AND e.household_id = u.household_id
+			AND DATE(e.created_at) >= ? AND DATE(e.created_at) <= ?
+		WHERE u.household_id = ?
+		GROUP BY u.id, u.display_name, u.email
+		ORDER BY 3 DESC, 2
+	`, filter.DateFrom, filter.DateTo, filter.HouseholdID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query member totals: %v", err)
+	}
+	defer rows.Close()
+
+	members := []ExpenseGroup{}
+	for rows.Next() {
+		var member ExpenseGroup
+		if err := rows.Scan(&member.ID, &member.Name, &member.Total, &member.Count); err != nil {
+			return nil, fmt.Errorf("failed to scan member total: %v", err)
+		}
+		members = append(members, member)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating member totals: %v", err)
+	}
+	return members, nil
+}
+
+// buildDashboard gathers the month's spending next to the previous month and
+// the household's goal progress, running the queries concurrently. Goals and
+// member totals are household-wide, so the user filter only narrows the
+// spending, income, categories and recent expenses.
+func buildDashboard(ctx context.Context, householdID int, month time.Time, userID *int) (Dashboard, error) {
+	dashboard := Dashboard{Month: month.Format("2006-01")}
+
+	filter := ExpenseFilter{
+		HouseholdID: householdID,
+		UserID:      userID,
+		DateFrom:    month.Format("2006-01-02"),
+		DateTo:      month.AddDate(0, 1, -1).Format("2006-01-02"),
+	}
+
+	previous := filter
+	previous.DateFrom = month.AddDate(0, -1, 0).Format("2006-01-02")
+	previous.DateTo = month.AddDate(0, 0, -1).Format("2006-01-02")
+
+	members := filter
+	members.UserID = nil
+
+	// Each task writes only its own field, so no locking is needed.
+	err := runConcurrently(ctx, dashboardSlots,
+		func(ctx context.Context) (err error) {
+			dashboard.SpendingTotal, err = queryExpenseTotalContext(ctx, filter)
+			return err
+		},
+		func(ctx context.Context) (err error) {
+			dashboard.PreviousSpendingTotal, err = queryExpenseTotalContext(ctx, previous)
+			return err
+		},
+		func(ctx context.Context) (err error) {
+			dashboard.IncomeTotal, err = queryIncomeTotalContext(ctx, IncomeFilter{ExpenseFilter: filter})
+			return err
+		},
+		func(ctx context.Context) (err error) {
+			dashboard.TopCategories, err = queryExpenseGroupsContext(ctx, filter, "category", "DESC")
+			return err
+		},
+		func(ctx context.Context) (err error) {
+			dashboard.RecentExpenses, err = queryExpensesContext(ctx, filter, "e.created_at", "e.id", "DESC", dashboardRecentLimit)
+			return err
+		},
+		func(ctx context.Context) (err error) {
+			dashboard.Members, err = queryMemberTotals(ctx, members)
+			return err
+		},
+		func(ctx context.Context) (err error) {
+			dashboard.Goals, err = loadGoals(ctx, householdID, time.Now().UTC())
+			return err
+		},
+	)
+	if err != nil {
+		return dashboard, err
+	}
+
+	if len(dashboard.TopCategories) > dashboardTopCategories {
+		dashboard.TopCategories = dashboard.TopCategories[:dashboardTopCategories]
+	}
+	if dashboard.TopCategories == nil {
+		dashboard.TopCategories = []ExpenseGroup{}
+	}
+	if dashboard.RecentExpenses == nil {
+		dashboard.RecentExpenses = []Expense{}
+	}
+
+	dashboard.SpendingChange = math.Round((dashboard.SpendingTotal-dashboard.PreviousSpendingTotal)*100) / 100
+	dashboard.Net = math.Round((dashboard.IncomeTotal-dashboard.SpendingTotal)*100) / 100
+	return dashboard, nil
+}
+
+func dashboardHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	monthStr := r.URL.Query().Get("month")
+	userIDStr := r.URL.Query().Get("user_id")
+
+	now := time.Now().UTC()
+	month := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	if monthStr != "" {
+		parsed, err := time.Parse("2006-01", monthStr)
+		if err != nil {
+			http.Error(w, "Invalid month parameter. Must be in YYYY-MM format", http.StatusBadRequest)
+			return
+		}
+		month = parsed
+	}
+
+	var userID *int
+	if userIDStr != "" {
+		value, err := strconv.Atoi(userIDStr)
+		if err != nil {
+			http.Error(w, "Invalid user_id parameter", http.StatusBadRequest)
+			return
+		}
+		userID = &value
+	}
+
+	if writeRefError(w, checkHouseholdRefs(householdID(r), householdRef{"user_id", "users", userID})) {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), dashboardTimeout)
+	defer cancel()
+
+	dashboard, err := buildDashboard(ctx, householdID(r), month, userID)
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		logger.Error(fmt.Sprintf("Dashboard timed out after %s: %v", dashboardTimeout, err))
+		http.Error(w, "Dashboard timed out", http.StatusGatewayTimeout)
+		return
+	}
+	if err != nil {
+		logger.Error(err.Error())
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(dashboard)
+}
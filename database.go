@@ -12,27 +12,43 @@ import (
 )
 
 type Subcategory struct {
-	ID   int    `json:"id"`
-	Name string `json:"name"`
+	ID        int     `json:"id"`
+	Name      string  `json:"name"`
+	Color     *string `json:"color"`
+	Icon      *string `json:"icon"`
+	SortOrder int     `json:"sort_order"`
+	Archived  bool    `json:"archived"`
 }
 
 type Category struct {
 	ID            int           `json:"id"`
 	Name          string        `json:"name"`
+	ParentID      *int          `json:"parent_id"`
+	Color         *string       `json:"color"`
+	Icon          *string       `json:"icon"`
+	SortOrder     int           `json:"sort_order"`
+	Archived      bool          `json:"archived"`
 	Subcategories []Subcategory `json:"subcategories"`
 }
 
 type Expense struct {
-	ID              int     `json:"id"`
-	Amount          float64 `json:"amount"`
-	SubcategoryID   int     `json:"subcategory_id"`
-	UserID          *int    `json:"user_id"`
-	Note            *string `json:"note"`
-	CreatedAt       string  `json:"created_at"`
-	UserEmail       *string `json:"user_email"`
-	SubcategoryName *string `json:"subcategory_name"`
-	CategoryID      *int    `json:"category_id"`
-	CategoryName    *string `json:"category_name"`
+	ID              int      `json:"id"`
+	Amount          float64  `json:"amount"`
+	SubcategoryID   int      `json:"subcategory_id"`
+	UserID          *int     `json:"user_id"`
+	Note            *string  `json:"note"`
+	CreatedAt       string   `json:"created_at"`
+	UserEmail       *string  `json:"user_email"`
+	SubcategoryName *string  `json:"subcategory_name"`
+	CategoryID      *int     `json:"category_id"`
+	CategoryName    *string  `json:"category_name"`
+	PayeeID         *int     `json:"payee_id"`
+	PayeeName       *string  `json:"payee_name"`
+	AccountID       *int     `json:"account_id"`
+	AccountName     *string  `json:"account_name"`
+	Tags            []string `json:"tags"`
+	Reimbursable    bool     `json:"reimbursable"`
+	ClaimID         *int     `json:"claim_id"`
 }
 
 type GroupedExpense struct {
@@ -42,6 +58,13 @@ type GroupedExpense struct {
 	Expenses  []Expense `json:"expenses"`
 }
 
+type ExpenseGroup struct {
+	ID    int     `json:"id"`
+	Name  string  `json:"name"`
+	Total float64 `json:"total"`
+	Count int     `json:"count"`
+}
+
 type User struct {
 	ID          int     `json:"id"`
 	UID         *string `json:"uid"`
@@ -50,6 +73,7 @@ type User struct {
 	CreatedAt   string  `json:"created_at"`
 	Password    *string `json:"-"` // Exclude from JSON responses
 	Role        string  `json:"role"`
+	HouseholdID int     `json:"household_id"`
 }
 
 type LoginRequest struct {
@@ -93,8 +117,8 @@ func getUserByEmail(email string) (*User, error) {
 	var uid sql.NullString
 	var displayName sql.NullString
 
-	query := `SELECT id, uid, email, display_name, created_at, password, role FROM users WHERE email = ?`
-	err := db.QueryRow(query, email).Scan(&user.ID, &uid, &user.Email, &displayName, &user.CreatedAt, &password, &user.Role)
+	query := `SELECT id, uid, email, display_name, created_at, password, role, household_id FROM users WHERE email = ?`
+	err := db.QueryRow(query, email).Scan(&user.ID, &uid, &user.Email, &displayName, &user.CreatedAt, &password, &user.Role, &user.HouseholdID)
 
 	if err != nil {
 		if err == sql.ErrNoRows {
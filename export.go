@@ -0,0 +1,133 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const csvFlushInterval = 500
+
+var expenseCSVHeader = []string{
+	"id",
+	"amount",
+	"subcategory_id",
+	"subcategory_name",
+	"category_id",
+	"category_name",
+	"user_id",
+	"user_email",
+	"note",
+	"created_at",
+	"tags",
+	"payee_id",
+	"payee_name",
+	"account_id",
+	"account_name",
+	"reimbursable",
+	"claim_id",
+}
+
+func optionalInt(value *int) string {
+	if value == nil {
+		return ""
+	}
+	return strconv.Itoa(*value)
+}
+
+func optionalString(value *string) string {
+	if value == nil {
+		return ""
+	}
+	return *value
+}
+
+func expenseCSVRecord(expense Expense) []string {
+	subcategoryID := ""
+	if expense.SubcategoryID != 0 {
+		subcategoryID = strconv.Itoa(expense.SubcategoryID)
+	}
+
+	return []string{
+		strconv.Itoa(expense.ID),
+		strconv.FormatFloat(expense.Amount, 'f', 2, 64),
+		subcategoryID,
+		optionalString(expense.SubcategoryName),
+		optionalInt(expense.CategoryID),
+		optionalString(expense.CategoryName),
+		optionalInt(expense.UserID),
+		optionalString(expense.UserEmail),
+		optionalString(expense.Note),
+		expense.CreatedAt,
+		strings.Join(expense.Tags, ","),
+		optionalInt(expense.PayeeID),
+		optionalString(expense.PayeeName),
+		optionalInt(expense.AccountID),
+		optionalString(expense.AccountName),
+		strconv.FormatBool(expense.Reimbursable),
+		optionalInt(expense.ClaimID),
+	}
+}
+
+// streamExpensesCSV writes one CSV record per row straight from the cursor so
+// large exports never hold the full result set in memory. Once the header has
+// been sent, errors can only be logged and the response is cut short.
+func streamExpensesCSV(w http.ResponseWriter, filter ExpenseFilter, orderBy, orderDir string) {
+	rows, err := openExpenseRows(filter, orderBy, orderDir)
+	if err != nil {
+		logger.Error(err.Error())
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	filename := fmt.Sprintf("expenses-%s.csv", time.Now().UTC().Format("2006-01-02"))
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", filename))
+
+	writer := csv.NewWriter(w)
+	flusher, _ := w.(http.Flusher)
+
+	if err := writer.Write(expenseCSVHeader); err != nil {
+		logger.Error(fmt.Sprintf("Failed to write expense CSV header: %v", err))
+		return
+	}
+
+	written := 0
+	for rows.Next() {
+		expense, err := scanExpense(rows)
+		if err != nil {
+			logger.Error(err.Error())
+			return
+		}
+
+		if err := writer.Write(expenseCSVRecord(expense)); err != nil {
+			logger.Error(fmt.Sprintf("Failed to write expense CSV record: %v", err))
+			return
+		}
+
+		written++
+		if written%csvFlushInterval == 0 {
+			writer.Flush()
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		logger.Error(fmt.Sprintf("Error iterating over expense rows during CSV export: %v", err))
+		return
+	}
+
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		logger.Error(fmt.Sprintf("Failed to flush expense CSV: %v", err))
+		return
+	}
+
+	logger.Info(fmt.Sprintf("Exported %d expenses as CSV", written))
+}
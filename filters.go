@@ -0,0 +1,242 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type ExpenseFilter struct {
+	HouseholdID    int
+	UserID         *int
+	CategoryIDs    []int
+	SubcategoryIDs []int
+	PayeeIDs       []int
+	AccountIDs     []int
+	DateFrom       string
+	DateTo         string
+	Tags           []string
+	TagMatch       string
+	Reimbursable   *bool
+	ClaimID        *int
+}
+
+// parseExpenseFilter reads the filters from the query string and scopes them
+// to the caller's household.
+func parseExpenseFilter(r *http.Request) (ExpenseFilter, error) {
+	filter := ExpenseFilter{HouseholdID: householdID(r)}
+	query := r.URL.Query()
+
+	userIDStr := query.Get("user_id")
+	categoryIDStr := query.Get("category_id")
+	subcategoryIDStr := query.Get("subcategory_id")
+	dateFromStr := query.Get("date_from")
+	dateToStr := query.Get("date_to")
+	payeeIDStr := query.Get("payee_id")
+	accountIDStr := query.Get("account_id")
+	reimbursableStr := query.Get("reimbursable")
+	claimIDStr := query.Get("claim_id")
+
+	if categoryIDStr != "" && subcategoryIDStr != "" {
+		return filter, fmt.Errorf("Cannot use both category_id and subcategory_id in the same query")
+	}
+
+	if userIDStr != "" {
+		userID, err := strconv.Atoi(userIDStr)
+		if err != nil {
+			return filter, fmt.Errorf("Invalid user_id parameter")
+		}
+		filter.UserID = &userID
+	}
+
+	if categoryIDStr != "" {
+		categoryIDs, err := parseCommaSeparatedInts(categoryIDStr)
+		if err != nil {
+			return filter, fmt.Errorf("Invalid category_id parameter")
+		}
+		filter.CategoryIDs = categoryIDs
+	}
+
+	if subcategoryIDStr != "" {
+		subcategoryIDs, err := parseCommaSeparatedInts(subcategoryIDStr)
+		if err != nil {
+			return filter, fmt.Errorf("Invalid subcategory_id parameter")
+		}
+		filter.SubcategoryIDs = subcategoryIDs
+	}
+
+	if payeeIDStr != "" {
+		payeeIDs, err := parseCommaSeparatedInts(payeeIDStr)
+		if err != nil {
+			return filter, fmt.Errorf("Invalid payee_id parameter")
+		}
+		filter.PayeeIDs = payeeIDs
+	}
+
+	if accountIDStr != "" {
+		accountIDs, err := parseCommaSeparatedInts(accountIDStr)
+		if err != nil {
+			return filter, fmt.Errorf("Invalid account_id parameter")
+		}
+		filter.AccountIDs = accountIDs
+	}
+
+	if dateFromStr != "" {
+		if _, err := time.Parse("2006-01-02", dateFromStr); err != nil {
+			return filter, fmt.Errorf("Invalid date_from parameter. Must be in YYYY-MM-DD format")
+		}
+		filter.DateFrom = dateFromStr
+	}
+
+	if dateToStr != "" {
+		if _, err := time.Parse("2006-01-02", dateToStr); err != nil {
+			return filter, fmt.Errorf("Invalid date_to parameter. Must be in YYYY-MM-DD format")
+		}
+		filter.DateTo = dateToStr
+	}
+
+	if reimbursableStr != "" {
+		reimbursable, err := strconv.ParseBool(reimbursableStr)
+		if err != nil {
+			return filter, fmt.Errorf("Invalid reimbursable parameter. Must be 'true' or 'false'")
+		}
+		filter.Reimbursable = &reimbursable
+	}
+
+	if claimIDStr != "" {
+		claimID, err := strconv.Atoi(claimIDStr)
+		if err != nil {
+			return filter, fmt.Errorf("Invalid claim_id parameter")
+		}
+		filter.ClaimID = &claimID
+	}
+
+	tags, tagMatch, err := parseTagFilter(query["tag"], query.Get("tag_match"))
+	if err != nil {
+		return filter, err
+	}
+	filter.Tags = tags
+	filter.TagMatch = tagMatch
+
+	return filter, nil
+}
+
+// expenseOnly reports whether the filter narrows by something only expenses
+// have, such as a category, a tag or a reimbursement claim.
+func (f ExpenseFilter) expenseOnly() bool {
+	return len(f.CategoryIDs) > 0 || len(f.SubcategoryIDs) > 0 || len(f.PayeeIDs) > 0 || len(f.Tags) > 0 ||
+		f.Reimbursable != nil || f.ClaimID != nil
+}
+
+// periodConditions covers the filters shared by every dated entry
+// (household, user, account and date range) on the table aliased as alias.
+// The household condition is always present, so a filter without one
+// matches nothing.
+func (f ExpenseFilter) periodConditions(alias string) ([]string, []interface{}) {
+	conditions := []string{alias + ".household_id = ?"}
+	args := []interface{}{f.HouseholdID}
+
+	if f.UserID != nil {
+		if *f.UserID == 0 {
+			conditions = append(conditions, alias+".user_id IS NULL")
+		} else {
+			conditions = append(conditions, alias+".user_id = ?")
+			args = append(args, *f.UserID)
+		}
+	}
+
+	if len(f.AccountIDs) > 0 {
+		conditions = append(conditions, fmt.Sprintf("%s.account_id IN (%s)", alias, placeholders(len(f.AccountIDs))))
+		for _, id := range f.AccountIDs {
+			args = append(args, id)
+		}
+	}
+
+	if f.DateFrom != "" {
+		conditions = append(conditions, "DATE("+alias+".created_at) >= ?")
+		args = append(args, f.DateFrom)
+	}
+
+	if f.DateTo != "" {
+		conditions = append(conditions, "DATE("+alias+".created_at) <= ?")
+		args = append(args, f.DateTo)
+	}
+
+	return conditions, args
+}
+
+// conditions expects the query to alias expenses as e and subcategories as s.
+func (f ExpenseFilter) conditions() ([]string, []interface{}) {
+	conditions, args := f.periodConditions("e")
+
+	// A category matches the expenses of every category below it as well.
+	if len(f.CategoryIDs) > 0 {
+		conditions = append(conditions, fmt.Sprintf(`s.category_id IN (
+			WITH RECURSIVE category_tree AS (
+				SELECT id FROM categories WHERE id IN (%s)
+				UNION
+				SELECT child.id FROM categories child JOIN category_tree ON child.parent_id = category_tree.id
+			)
+			SELECT id FROM category_tree
+		)`, placeholders(len(f.CategoryIDs))))
+		for _, id := range f.CategoryIDs {
+			args = append(args, id)
+		}
+	}
+
+	if len(f.SubcategoryIDs) > 0 {
+		conditions = append(conditions, fmt.Sprintf("e.subcategory_id IN (%s)", placeholders(len(f.SubcategoryIDs))))
+		for _, id := range f.SubcategoryIDs {
+			args = append(args, id)
+		}
+	}
+
+	if len(f.PayeeIDs) > 0 {
+		conditions = append(conditions, fmt.Sprintf("e.payee_id IN (%s)", placeholders(len(f.PayeeIDs))))
+		for _, id := range f.PayeeIDs {
+			args = append(args, id)
+		}
+	}
+
+	if f.Reimbursable != nil {
+		conditions = append(conditions, "e.reimbursable = ?")
+		args = append(args, *f.Reimbursable)
+	}
+
+	if f.ClaimID != nil {
+		if *f.ClaimID == 0 {
+			conditions = append(conditions, "e.claim_id IS NULL")
+		} else {
+			conditions = append(conditions, "e.claim_id = ?")
+			args = append(args, *f.ClaimID)
+		}
+	}
+
+	if len(f.Tags) > 0 {
+		tagged := fmt.Sprintf(`
+			SELECT COUNT(*) FROM expense_tags et
+			JOIN tags t ON t.id = et.tag_id
+			WHERE et.expense_id = e.id AND t.name IN (%s)
+		`, placeholders(len(f.Tags)))
+		if f.TagMatch == "all" {
+			conditions = append(conditions, fmt.Sprintf("(%s) = %d", tagged, len(f.Tags)))
+		} else {
+			conditions = append(conditions, fmt.Sprintf("(%s) > 0", tagged))
+		}
+		for _, tag := range f.Tags {
+			args = append(args, tag)
+		}
+	}
+
+	return conditions, args
+}
+
+func placeholders(n int) string {
+	parts := make([]string, n)
+	for i := range parts {
+		parts[i] = "?"
+	}
+	return strings.Join(parts, ",")
+}
@@ -0,0 +1,372 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultForecastLookbackDays = 90
+	maxForecastLookbackDays     = 365
+	defaultForecastConfidence   = 90
+	recurringHistoryMonths      = 3
+)
+
+var forecastZScores = map[int]float64{
+	80: 1.2816,
+	90: 1.6449,
+	95: 1.9600,
+}
+
+type ForecastExpense struct {
+	Date          time.Time
+	Amount        float64
+	CategoryID    int
+	CategoryName  string
+	SubcategoryID int
+}
+
+type ForecastInput struct {
+	Today        time.Time
+	LookbackDays int
+	Confidence   int
+	Expenses     []ForecastExpense
+}
+
+type RecurringItem struct {
+	CategoryID    int     `json:"category_id"`
+	CategoryName  string  `json:"category_name"`
+	SubcategoryID int     `json:"subcategory_id"`
+	Amount        float64 `json:"amount"`
+	ExpectedDay   int     `json:"expected_day"`
+	Posted        bool    `json:"posted"`
+}
+
+type CategoryForecast struct {
+	CategoryID       int     `json:"category_id"`
+	CategoryName     string  `json:"category_name"`
+	Actual           float64 `json:"actual"`
+	PendingRecurring float64 `json:"pending_recurring"`
+	DailyRate        float64 `json:"daily_rate"`
+	Projected        float64 `json:"projected"`
+	Low              float64 `json:"low"`
+	High             float64 `json:"high"`
+}
+
+type MonthForecast struct {
+	Month          string             `json:"month"`
+	Today          string             `json:"today"`
+	DaysElapsed    int                `json:"days_elapsed"`
+	DaysRemaining  int                `json:"days_remaining"`
+	LookbackDays   int                `json:"lookback_days"`
+	Confidence     int                `json:"confidence"`
+	Actual         float64            `json:"actual"`
+	Projected      float64            `json:"projected"`
+	Low            float64            `json:"low"`
+	High           float64            `json:"high"`
+	Categories     []CategoryForecast `json:"categories"`
+	RecurringItems []RecurringItem    `json:"recurring_items"`
+}
+
+type recurringKey struct {
+	SubcategoryID int
+	Cents         int64
+}
+
+func toCents(amount float64) int64 {
+	return int64(math.Round(amount * 100))
+}
+
+// detectRecurring finds subcategory/amount pairs that were paid exactly once in
+// each of the months preceding monthStart. Uncategorized expenses are never
+// recurring.
+func detectRecurring(expenses []ForecastExpense, monthStart time.Time) map[recurringKey]RecurringItem {
+	historyStart := monthStart.AddDate(0, -recurringHistoryMonths, 0)
+
+	months := make(map[recurringKey]map[string]bool)
+	days := make(map[recurringKey][]float64)
+	categories := make(map[recurringKey]ForecastExpense)
+
+	for _, expense := range expenses {
+		if expense.SubcategoryID == 0 || expense.Date.Before(historyStart) || !expense.Date.Before(monthStart) {
+			continue
+		}
+		key := recurringKey{SubcategoryID: expense.SubcategoryID, Cents: toCents(expense.Amount)}
+		if months[key] == nil {
+			months[key] = make(map[string]bool)
+		}
+		months[key][expense.Date.Format("2006-01")] = true
+		days[key] = append(days[key], float64(expense.Date.Day()))
+		categories[key] = expense
+	}
+
+	recurring := make(map[recurringKey]RecurringItem)
+	for key, seen := range months {
+		if len(seen) < recurringHistoryMonths || len(days[key]) != len(seen) {
+			continue
+		}
+		sort.Float64s(days[key])
+		recurring[key] = RecurringItem{
+			CategoryID:    categories[key].CategoryID,
+			CategoryName:  categories[key].CategoryName,
+			SubcategoryID: key.SubcategoryID,
+			Amount:        float64(key.Cents) / 100,
+			ExpectedDay:   int(percentile(days[key], 50)),
+		}
+	}
+
+	return recurring
+}
+
+func meanAndStdDev(values []float64) (float64, float64) {
+	if len(values) == 0 {
+		return 0, 0
+	}
+
+	var sum float64
+	for _, value := range values {
+		sum += value
+	}
+	mean := sum / float64(len(values))
+
+	if len(values) < 2 {
+		return mean, 0
+	}
+
+	var squares float64
+	for _, value := range values {
+		squares += (value - mean) * (value - mean)
+	}
+
+	return mean, math.Sqrt(squares / float64(len(values)-1))
+}
+
+// forecastMonth projects end-of-month totals per category. The projection is
+// month-to-date spending, plus recurring items not yet posted this month, plus
+// the trailing non-recurring daily average for each remaining day. Today counts
+// as elapsed, but it is not over: whatever part of a typical day has not been
+// spent yet is projected too. The daily average comes from the lookback days
+// before today. The band widens with the daily standard deviation over the
+// remaining days and today.
+func forecastMonth(input ForecastInput) MonthForecast {
+	today := time.Date(input.Today.Year(), input.Today.Month(), input.Today.Day(), 0, 0, 0, 0, time.UTC)
+	monthStart := time.Date(today.Year(), today.Month(), 1, 0, 0, 0, 0, time.UTC)
+	monthEnd := monthStart.AddDate(0, 1, 0)
+	tomorrow := today.AddDate(0, 0, 1)
+	lookbackStart := today.AddDate(0, 0, -input.LookbackDays)
+
+	z, ok := forecastZScores[input.Confidence]
+	if !ok {
+		input.Confidence = defaultForecastConfidence
+		z = forecastZScores[defaultForecastConfidence]
+	}
+
+	forecast := MonthForecast{
+		Month:          monthStart.Format("2006-01"),
+		Today:          today.Format("2006-01-02"),
+		DaysElapsed:    today.Day(),
+		DaysRemaining:  int(monthEnd.Sub(tomorrow).Hours() / 24),
+		LookbackDays:   input.LookbackDays,
+		Confidence:     input.Confidence,
+		Categories:     []CategoryForecast{},
+		RecurringItems: []RecurringItem{},
+	}
+
+	recurring := detectRecurring(input.Expenses, monthStart)
+
+	categoryIndex := make(map[int]int)
+	category := func(id int, name string) *CategoryForecast {
+		i, ok := categoryIndex[id]
+		if !ok {
+			i = len(forecast.Categories)
+			categoryIndex[id] = i
+			forecast.Categories = append(forecast.Categories, CategoryForecast{CategoryID: id, CategoryName: name})
+		}
+		return &forecast.Categories[i]
+	}
+
+	dailyTotals := make(map[int][]float64)
+	spentToday := make(map[int]float64)
+	lookbackDays := input.LookbackDays
+
+	for _, expense := range input.Expenses {
+		date := time.Date(expense.Date.Year(), expense.Date.Month(), expense.Date.Day(), 0, 0, 0, 0, time.UTC)
+		if !date.Before(tomorrow) {
+			continue
+		}
+
+		key := recurringKey{SubcategoryID: expense.SubcategoryID, Cents: toCents(expense.Amount)}
+		item, isRecurring := recurring[key]
+
+		if !date.Before(monthStart) {
+			category(expense.CategoryID, expense.CategoryName).Actual += expense.Amount
+			if isRecurring {
+				item.Posted = true
+				recurring[key] = item
+			}
+		}
+
+		if isRecurring || date.Before(lookbackStart) {
+			continue
+		}
+		if date.Equal(today) {
+			spentToday[expense.CategoryID] += expense.Amount
+			continue
+		}
+
+		category(expense.CategoryID, expense.CategoryName)
+		if dailyTotals[expense.CategoryID] == nil {
+			dailyTotals[expense.CategoryID] = make([]float64, lookbackDays)
+		}
+		dailyTotals[expense.CategoryID][int(date.Sub(lookbackStart).Hours()/24)] += expense.Amount
+	}
+
+	for _, item := range recurring {
+		forecast.RecurringItems = append(forecast.RecurringItems, item)
+	}
+	sort.Slice(forecast.RecurringItems, func(a, b int) bool {
+		x, y := forecast.RecurringItems[a], forecast.RecurringItems[b]
+		if x.ExpectedDay != y.ExpectedDay {
+			return x.ExpectedDay < y.ExpectedDay
+		}
+		if x.SubcategoryID != y.SubcategoryID {
+			return x.SubcategoryID < y.SubcategoryID
+		}
+		return x.Amount < y.Amount
+	})
+
+	for _, item := range forecast.RecurringItems {
+		if !item.Posted {
+			category(item.CategoryID, item.CategoryName).PendingRecurring += item.Amount
+		}
+	}
+
+	remaining := float64(forecast.DaysRemaining)
+	open := remaining + 1
+	var variance float64
+
+	for i := range forecast.Categories {
+		c := &forecast.Categories[i]
+
+		mean, stdDev := 0.0, 0.0
+		if totals, ok := dailyTotals[c.CategoryID]; ok {
+			mean, stdDev = meanAndStdDev(totals)
+		}
+
+		c.DailyRate = mean
+		restOfToday := math.Max(0, mean-spentToday[c.CategoryID])
+		c.Projected = c.Actual + c.PendingRecurring + restOfToday + mean*remaining
+		margin := z * stdDev * math.Sqrt(open)
+		c.Low = math.Max(c.Actual+c.PendingRecurring, c.Projected-margin)
+		c.High = c.Projected + margin
+
+		forecast.Actual += c.Actual
+		forecast.Projected += c.Projected
+		variance += stdDev * stdDev * open
+	}
+
+	floor := forecast.Actual
+	for _, c := range forecast.Categories {
+		floor += c.PendingRecurring
+	}
+	margin := z * math.Sqrt(variance)
+	forecast.Low = math.Max(floor, forecast.Projected-margin)
+	forecast.High = forecast.Projected + margin
+
+	sort.SliceStable(forecast.Categories, func(a, b int) bool {
+		return forecast.Categories[a].Projected > forecast.Categories[b].Projected
+	})
+
+	return forecast
+}
+
+func forecastReportHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	lookbackStr := r.URL.Query().Get("lookback_days")
+	confidenceStr := r.URL.Query().Get("confidence")
+
+	filter, err := parseExpenseFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	lookbackDays := defaultForecastLookbackDays
+	if lookbackStr != "" {
+		days, err := strconv.Atoi(lookbackStr)
+		if err != nil || days < 7 || days > maxForecastLookbackDays {
+			http.Error(w, fmt.Sprintf("Invalid lookback_days parameter. Must be 7-%d", maxForecastLookbackDays), http.StatusBadRequest)
+			return
+		}
+		lookbackDays = days
+	}
+
+	confidence := defaultForecastConfidence
+	if confidenceStr != "" {
+		value, err := strconv.Atoi(confidenceStr)
+		if _, ok := forecastZScores[value]; err != nil || !ok {
+			http.Error(w, "Invalid confidence parameter. Must be 80, 90, or 95", http.StatusBadRequest)
+			return
+		}
+		confidence = value
+	}
+
+	today := time.Now().UTC()
+	monthStart := time.Date(today.Year(), today.Month(), 1, 0, 0, 0, 0, time.UTC)
+	from := today.AddDate(0, 0, -lookbackDays)
+	if recurringStart := monthStart.AddDate(0, -recurringHistoryMonths, 0); recurringStart.Before(from) {
+		from = recurringStart
+	}
+
+	filter.DateFrom = from.Format("2006-01-02")
+	filter.DateTo = today.Format("2006-01-02")
+
+	expenses, err := queryExpenses(filter, "e.created_at", "ASC")
+	if err != nil {
+		logger.Error(err.Error())
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	input := ForecastInput{
+		Today:        today,
+		LookbackDays: lookbackDays,
+		Confidence:   confidence,
+	}
+
+	for _, expense := range expenses {
+		createdAt, err := time.Parse(time.RFC3339Nano, expense.CreatedAt)
+		if err != nil {
+			logger.Error(fmt.Sprintf("Failed to parse expense date %q: %v", expense.CreatedAt, err))
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		forecastExpense := ForecastExpense{
+			Date:          createdAt.UTC(),
+			Amount:        expense.Amount,
+			CategoryName:  "Uncategorized",
+			SubcategoryID: expense.SubcategoryID,
+		}
+		if expense.CategoryID != nil {
+			forecastExpense.CategoryID = *expense.CategoryID
+		}
+		if expense.CategoryName != nil {
+			forecastExpense.CategoryName = *expense.CategoryName
+		}
+
+		input.Expenses = append(input.Expenses, forecastExpense)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(forecastMonth(input))
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func forecastDate(month time.Month, day int) time.Time {
+	return time.Date(2024, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+// dailySpending is one expense per day from the first date through the last.
+func dailySpending(from, to time.Time, categoryID, subcategoryID int, amounts ...float64) []ForecastExpense {
+	var expenses []ForecastExpense
+	for i, day := 0, from; !day.After(to); i, day = i+1, day.AddDate(0, 0, 1) {
+		expenses = append(expenses, ForecastExpense{
+			Date:          day,
+			Amount:        amounts[i%len(amounts)],
+			CategoryID:    categoryID,
+			CategoryName:  "Food",
+			SubcategoryID: subcategoryID,
+		})
+	}
+	return expenses
+}
+
+func rent(dates ...time.Time) []ForecastExpense {
+	var expenses []ForecastExpense
+	for _, date := range dates {
+		expenses = append(expenses, ForecastExpense{Date: date, Amount: 1000, CategoryID: 2, CategoryName: "Housing", SubcategoryID: 20})
+	}
+	return expenses
+}
+
+func approxEqual(a, b float64) bool {
+	return math.Abs(a-b) < 1e-6
+}
+
+func TestForecastMonth(t *testing.T) {
+	// March 2024 has 31 days: with today on the 10th, 10 days have elapsed
+	// and 21 follow today. A 7-day lookback covers March 3-9.
+	today := forecastDate(time.March, 10)
+	rentHistory := rent(time.Date(2023, time.December, 1, 0, 0, 0, 0, time.UTC), forecastDate(time.January, 1), forecastDate(time.February, 1))
+
+	tests := []struct {
+		name          string
+		expenses      []ForecastExpense
+		confidence    int
+		wantActual    float64
+		wantPending   float64
+		wantProjected float64
+		wantRecurring int
+		wantPosted    bool
+		wantMargin    float64
+	}{
+		{
+			name:          "no history projects only what was spent",
+			expenses:      []ForecastExpense{{Date: forecastDate(time.March, 2), Amount: 25, CategoryID: 1, CategoryName: "Food", SubcategoryID: 10}},
+			wantActual:    25,
+			wantProjected: 25,
+		},
+		{
+			name:          "steady spending has no band and projects the rest of today",
+			expenses:      dailySpending(forecastDate(time.March, 3), forecastDate(time.March, 9), 1, 10, 10),
+			wantActual:    70,
+			wantProjected: 70 + 10 + 10*21,
+		},
+		{
+			name: "spending already made today reduces the rest of today",
+			expenses: append(dailySpending(forecastDate(time.March, 3), forecastDate(time.March, 9), 1, 10, 10),
+				ForecastExpense{Date: today, Amount: 4, CategoryID: 1, CategoryName: "Food", SubcategoryID: 10}),
+			wantActual:    74,
+			wantProjected: 74 + 6 + 10*21,
+		},
+		{
+			name: "spending today above the daily rate projects nothing more for today",
+			expenses: append(dailySpending(forecastDate(time.March, 3), forecastDate(time.March, 9), 1, 10, 10),
+				ForecastExpense{Date: today, Amount: 15, CategoryID: 1, CategoryName: "Food", SubcategoryID: 10}),
+			wantActual:    85,
+			wantProjected: 85 + 10*21,
+		},
+		{
+			name:          "recurring payment not yet posted is pending",
+			expenses:      rentHistory,
+			wantPending:   1000,
+			wantProjected: 1000,
+			wantRecurring: 1,
+		},
+		{
+			name:          "recurring payment posted this month is not pending again",
+			expenses:      append(append([]ForecastExpense{}, rentHistory...), rent(forecastDate(time.March, 1))...),
+			wantActual:    1000,
+			wantProjected: 1000,
+			wantRecurring: 1,
+			wantPosted:    true,
+		},
+		{
+			name:          "payment seen in only two of the previous months is not recurring",
+			expenses:      rentHistory[1:],
+			wantRecurring: 0,
+		},
+		{
+			name:          "confidence band follows the daily standard deviation",
+			expenses:      dailySpending(forecastDate(time.March, 3), forecastDate(time.March, 9), 1, 10, 0, 20),
+			confidence:    95,
+			wantActual:    60,
+			wantProjected: 60 + 60.0/7 + 60.0/7*21,
+			wantMargin:    1.96 * stdDevOf(0, 20, 0, 20, 0, 20, 0) * math.Sqrt(22),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			confidence := tt.confidence
+			if confidence == 0 {
+				confidence = 90
+			}
+			forecast := forecastMonth(ForecastInput{Today: today, LookbackDays: 7, Confidence: confidence, Expenses: tt.expenses})
+
+			if forecast.DaysElapsed != 10 || forecast.DaysRemaining != 21 {
+				t.Fatalf("days elapsed/remaining = %d/%d, want 10/21", forecast.DaysElapsed, forecast.DaysRemaining)
+			}
+			if !approxEqual(forecast.Actual, tt.wantActual) {
+				t.Errorf("actual = %v, want %v", forecast.Actual, tt.wantActual)
+			}
+			if !approxEqual(forecast.Projected, tt.wantProjected) {
+				t.Errorf("projected = %v, want %v", forecast.Projected, tt.wantProjected)
+			}
+
+			var pending float64
+			for _, c := range forecast.Categories {
+				pending += c.PendingRecurring
+			}
+			if !approxEqual(pending, tt.wantPending) {
+				t.Errorf("pending recurring = %v, want %v", pending, tt.wantPending)
+			}
+
+			if len(forecast.RecurringItems) != tt.wantRecurring {
+				t.Fatalf("recurring items = %d, want %d", len(forecast.RecurringItems), tt.wantRecurring)
+			}
+			if tt.wantRecurring > 0 {
+				item := forecast.RecurringItems[0]
+				if item.SubcategoryID != 20 || item.Amount != 1000 || item.ExpectedDay != 1 || item.Posted != tt.wantPosted {
+					t.Errorf("recurring item = %+v, want subcategory 20, 1000 on day 1, posted %v", item, tt.wantPosted)
+				}
+			}
+
+			if !approxEqual(forecast.High-forecast.Projected, tt.wantMargin) {
+				t.Errorf("high - projected = %v, want %v", forecast.High-forecast.Projected, tt.wantMargin)
+			}
+			if forecast.Low > forecast.Projected || forecast.Low < forecast.Actual+pending-1e-9 {
+				t.Errorf("low = %v, want between %v and %v", forecast.Low, forecast.Actual+pending, forecast.Projected)
+			}
+		})
+	}
+}
+
+func stdDevOf(values ...float64) float64 {
+	_, stdDev := meanAndStdDev(values)
+	return stdDev
+}
+
+func TestForecastMonthUnknownConfidence(t *testing.T) {
+	forecast := forecastMonth(ForecastInput{Today: forecastDate(time.March, 10), LookbackDays: 7, Confidence: 42})
+	if forecast.Confidence != defaultForecastConfidence {
+		t.Errorf("confidence = %d, want %d", forecast.Confidence, defaultForecastConfidence)
+	}
+}
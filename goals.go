@@ -0,0 +1,603 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// goalRateWindowDays is how far back contributions count towards the
+	// saving rate used for projections.
+	goalRateWindowDays = 90
+	// goalMinRateDays keeps a single early contribution from being
+	// extrapolated as if it were saved every day.
+	goalMinRateDays = 30
+	// goalMaxProjectionDays caps the projection; at a rate that would take
+	// longer the goal is reported without a projected date.
+	goalMaxProjectionDays = 100 * 365
+	daysPerMonth          = 365.25 / 12
+)
+
+const (
+	goalAchieved = "achieved"
+	goalOnTrack  = "on_track"
+	goalBehind   = "behind"
+	goalOverdue  = "overdue"
+)
+
+type Goal struct {
+	ID                int      `json:"id"`
+	Name              string   `json:"name"`
+	TargetAmount      float64  `json:"target_amount"`
+	TargetDate        string   `json:"target_date"`
+	UserID            *int     `json:"user_id"`
+	Saved             float64  `json:"saved"`
+	Remaining         float64  `json:"remaining"`
+	Percent           float64  `json:"percent"`
+	ContributionCount int      `json:"contribution_count"`
+	MonthlyRate       float64  `json:"monthly_rate"`
+	RequiredMonthly   *float64 `json:"required_monthly"`
+	ProjectedDate     *string  `json:"projected_date"`
+	Status            string   `json:"status"`
+	CreatedAt         string   `json:"created_at"`
+}
+
+type GoalContribution struct {
+	ID        int     `json:"id"`
+	GoalID    int     `json:"goal_id"`
+	Amount    float64 `json:"amount"`
+	UserID    *int    `json:"user_id"`
+	Note      *string `json:"note"`
+	CreatedAt string  `json:"created_at"`
+}
+
+type GoalRequest struct {
+	Name         *string  `json:"name"`
+	TargetAmount *float64 `json:"target_amount"`
+	TargetDate   *string  `json:"target_date"`
+	UserID       *int     `json:"user_id"`
+}
+
+type ContributionRequest struct {
+	Amount float64 `json:"amount"`
+	UserID *int    `json:"user_id"`
+	Note   *string `json:"note"`
+	Date   *string `json:"date"`
+}
+
+// goalSelect sums every contribution for the saved amount and, separately,
+// the contributions since the rate window start (the first argument).
+const goalSelect = `
+	SELECT g.id, g.name, g.target_amount, DATE_FORMAT(g.target_date, '%Y-%m-%d'), g.user_id,
+		COALESCE(SUM(c.amount), 0), COUNT(c.id),
+		COALESCE(SUM(CASE WHEN c.created_at >= ? THEN c.amount END), 0),
+		DATE_FORMAT(MIN(c.created_at), '%Y-%m-%d'),
+		g.created_at
+	FROM savings_goals g
+	LEFT JOIN goal_contributions c ON c.goal_id = g.id
+`
+
+const goalGroupBy = " GROUP BY g.id, g.name, g.target_amount, g.target_date, g.user_id, g.created_at"
+
+func scanGoal(scanner interface{ Scan(...interface{}) error }, today time.Time) (Goal, error) {
+	var goal Goal
+	var recent float64
+	var first sql.NullString
+	err := scanner.Scan(
+		&goal.ID,
+		&goal.Name,
+		&goal.TargetAmount,
+		&goal.TargetDate,
+		&goal.UserID,
+		&goal.Saved,
+		&goal.ContributionCount,
+		&recent,
+		&first,
+		&goal.CreatedAt,
+	)
+	if err != nil {
+		return goal, err
+	}
+	applyGoalProgress(&goal, recent, first.String, today)
+	return goal, nil
+}
+
+// applyGoalProgress fills in the derived fields. The monthly rate is the
+// average over the rate window, or since the first contribution when that is
+// more recent, and the projection extends that rate over what is left.
+func applyGoalProgress(goal *Goal, recent float64, firstContribution string, today time.Time) {
+	today = time.Date(today.Year(), today.Month(), today.Day(), 0, 0, 0, 0, time.UTC)
+
+	goal.Remaining = math.Max(0, math.Round((goal.TargetAmount-goal.Saved)*100)/100)
+	if goal.TargetAmount > 0 {
+		goal.Percent = math.Round(goal.Saved/goal.TargetAmount*10000) / 100
+	}
+
+	windowStart := today.AddDate(0, 0, -(goalRateWindowDays - 1))
+	if first, err := time.Parse("2006-01-02", firstContribution); err == nil && first.After(windowStart) {
+		windowStart = first
+	}
+	days := int(today.Sub(windowStart).Hours()/24) + 1
+	if days < goalMinRateDays {
+		days = goalMinRateDays
+	}
+	dailyRate := recent / float64(days)
+	goal.MonthlyRate = math.Round(dailyRate*daysPerMonth*100) / 100
+
+	targetDate, _ := time.Parse("2006-01-02", goal.TargetDate)
+
+	if goal.Remaining == 0 {
+		goal.Status = goalAchieved
+		return
+	}
+
+	var projected *time.Time
+	if dailyRate > 0 {
+		if daysNeeded := math.Ceil(goal.Remaining / dailyRate); daysNeeded <= goalMaxProjectionDays {
+			date := today.AddDate(0, 0, int(daysNeeded))
+			projected = &date
+			formatted := date.Format("2006-01-02")
+			goal.ProjectedDate = &formatted
+		}
+	}
+
+	if today.After(targetDate) {
+		goal.Status = goalOverdue
+		return
+	}
+
+	monthsLeft := (targetDate.Sub(today).Hours()/24 + 1) / daysPerMonth
+	required := math.Round(goal.Remaining/monthsLeft*100) / 100
+	goal.RequiredMonthly = &required
+
+	if projected != nil && !projected.After(targetDate) {
+		goal.Status = goalOnTrack
+	} else {
+		goal.Status = goalBehind
+	}
+}
+
+func rateWindowStart(today time.Time) string {
+	return today.AddDate(0, 0, -(goalRateWindowDays - 1)).Format("2006-01-02")
+}
+
+func loadGoals(ctx context.Context, householdID int, today time.Time) ([]Goal, error) {
+	rows, err := db.QueryContext(ctx,
+		goalSelect+" WHERE g.household_id = ?"+goalGroupBy+" ORDER BY g.target_date, g.name",
+		rateWindowStart(today), householdID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query goals: %v", err)
+	}
+	defer rows.Close()
+
+	goals := []Goal{}
+	for rows.Next() {
+		goal, err := scanGoal(rows, today)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan goal: %v", err)
+		}
+		goals = append(goals, goal)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating goals: %v", err)
+	}
+	return goals, nil
+}
+
+func getGoal(householdID, goalID int) (*Goal, error) {
+	today := time.Now().UTC()
+	row := db.QueryRow(
+		goalSelect+" WHERE g.id = ? AND g.household_id = ?"+goalGroupBy,
+		rateWindowStart(today), goalID, householdID,
+	)
+	goal, err := scanGoal(row, today)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query goal: %v", err)
+	}
+	return &goal, nil
+}
+
+func loadGoalContributions(goalID int) ([]GoalContribution, error) {
+	rows, err := db.Query(`
+		SELECT id, goal_id, amount, user_id, note, created_at
+		FROM goal_contributions
+		WHERE goal_id = ?
+		ORDER BY created_at DESC, id DESC
+	`, goalID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query goal contributions: %v", err)
+	}
+	defer rows.Close()
+
+	contributions := []GoalContribution{}
+	for rows.Next() {
+		var c GoalContribution
+		if err := rows.Scan(&c.ID, &c.GoalID, &c.Amount, &c.UserID, &c.Note, &c.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan goal contribution: %v", err)
+		}
+		contributions = append(contributions, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating goal contributions: %v", err)
+	}
+	return contributions, nil
+}
+
+// validateGoalRequest checks the fields that are present; create also
+// requires name, target_amount and target_date.
+func validateGoalRequest(req *GoalRequest, create bool) error {
+	if req.Name != nil {
+		name := strings.TrimSpace(*req.Name)
+		if name == "" || len(name) > 255 {
+			return fmt.Errorf("Name must be 1-255 characters")
+		}
+		req.Name = &name
+	} else if create {
+		return fmt.Errorf("Name is required")
+	}
+
+	if req.TargetAmount != nil {
+		if *req.TargetAmount <= 0 || math.IsInf(*req.TargetAmount, 0) {
+			return fmt.Errorf("target_amount must be greater than 0")
+		}
+	} else if create {
+		return fmt.Errorf("target_amount is required")
+	}
+
+	if req.TargetDate != nil {
+		if _, err := time.Parse("2006-01-02", *req.TargetDate); err != nil {
+			return fmt.Errorf("target_date must be in YYYY-MM-DD format")
+		}
+	} else if create {
+		return fmt.Errorf("target_date is required")
+	}
+
+	return nil
+}
+
+func goalNameTaken(householdID int, name string, goalID int) (bool, error) {
+	var existingID int
+	err := db.QueryRow("SELECT id FROM savings_goals WHERE name = ? AND id != ? AND household_id = ?", name, goalID, householdID).Scan(&existingID)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to check goal uniqueness: %v", err)
+	}
+	return true, nil
+}
+
+// parseGoalPath splits /api/v1/goals/{id}[/contributions[/{contribution_id}]].
+// contributionID is 0 when the path does not name one.
+func parseGoalPath(path string) (goalID int, contributions bool, contributionID int, err error) {
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(path, "/api/v1/goals/"), "/"), "/")
+	if len(parts) > 3 || (len(parts) > 1 && parts[1] != "contributions") {
+		return 0, false, 0, fmt.Errorf("unknown goal path")
+	}
+
+	goalID, err = strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, false, 0, fmt.Errorf("Invalid goal ID")
+	}
+	if len(parts) == 3 {
+		contributionID, err = strconv.Atoi(parts[2])
+		if err != nil {
+			return 0, false, 0, fmt.Errorf("Invalid contribution ID")
+		}
+	}
+	return goalID, len(parts) > 1, contributionID, nil
+}
+
+func goalsHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		goals, err := loadGoals(r.Context(), householdID(r), time.Now().UTC())
+		if err != nil {
+			logger.Error(err.Error())
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(goals)
+	case http.MethodPost:
+		createGoalHandler(w, r)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func singleGoalHandler(w http.ResponseWriter, r *http.Request) {
+	goalID, contributions, contributionID, err := parseGoalPath(r.URL.Path)
+	if err != nil {
+		if err.Error() == "unknown goal path" {
+			http.NotFound(w, r)
+		} else {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		}
+		return
+	}
+
+	goal, err := getGoal(householdID(r), goalID)
+	if err != nil {
+		logger.Error(err.Error())
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if goal == nil {
+		http.Error(w, "Goal not found", http.StatusNotFound)
+		return
+	}
+
+	if contributionID != 0 {
+		if r.Method != http.MethodDelete {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		deleteContributionHandler(w, goal, contributionID)
+		return
+	}
+
+	if contributions {
+		switch r.Method {
+		case http.MethodGet:
+			list, err := loadGoalContributions(goal.ID)
+			if err != nil {
+				logger.Error(err.Error())
+				http.Error(w, "Internal server error", http.StatusInternalServerError)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(list)
+		case http.MethodPost:
+			createContributionHandler(w, r, goal)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		list, err := loadGoalContributions(goal.ID)
+		if err != nil {
+			logger.Error(err.Error())
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"goal":          goal,
+			"contributions": list,
+		})
+	case http.MethodPut, http.MethodPatch:
+		updateGoalHandler(w, r, goal)
+	case http.MethodDelete:
+		if _, err := db.Exec("DELETE FROM savings_goals WHERE id = ?", goal.ID); err != nil {
+			logger.Error(fmt.Sprintf("Failed to delete goal: %v", err))
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"message": "Goal deleted successfully",
+			"id":      goal.ID,
+		})
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func createGoalHandler(w http.ResponseWriter, r *http.Request) {
+	var req GoalRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON body", http.StatusBadRequest)
+		return
+	}
+
+	if err := validateGoalRequest(&req, true); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if writeRefError(w, checkHouseholdRefs(householdID(r), householdRef{"user_id", "users", req.UserID})) {
+		return
+	}
+
+	taken, err := goalNameTaken(householdID(r), *req.Name, 0)
+	if err != nil {
+		logger.Error(err.Error())
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if taken {
+		http.Error(w, "Goal name already exists", http.StatusConflict)
+		return
+	}
+
+	result, err := db.Exec(
+		"INSERT INTO savings_goals (household_id, name, target_amount, target_date, user_id) VALUES (?, ?, ?, ?, ?)",
+		householdID(r), *req.Name, *req.TargetAmount, *req.TargetDate, req.UserID,
+	)
+	if err != nil {
+		logger.Error(fmt.Sprintf("Failed to create goal: %v", err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	goalID, err := result.LastInsertId()
+	if err != nil {
+		logger.Error(fmt.Sprintf("Failed to get last insert ID: %v", err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	goal, err := getGoal(householdID(r), int(goalID))
+	if err != nil || goal == nil {
+		logger.Error(fmt.Sprintf("Failed to load created goal: %v", err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(goal)
+}
+
+func updateGoalHandler(w http.ResponseWriter, r *http.Request, goal *Goal) {
+	var req GoalRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON body", http.StatusBadRequest)
+		return
+	}
+
+	if err := validateGoalRequest(&req, false); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if writeRefError(w, checkHouseholdRefs(householdID(r), householdRef{"user_id", "users", req.UserID})) {
+		return
+	}
+
+	var sets []string
+	var args []interface{}
+
+	if req.Name != nil {
+		taken, err := goalNameTaken(householdID(r), *req.Name, goal.ID)
+		if err != nil {
+			logger.Error(err.Error())
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		if taken {
+			http.Error(w, "Goal name already exists", http.StatusConflict)
+			return
+		}
+		sets = append(sets, "name = ?")
+		args = append(args, *req.Name)
+	}
+	if req.TargetAmount != nil {
+		sets = append(sets, "target_amount = ?")
+		args = append(args, *req.TargetAmount)
+	}
+	if req.TargetDate != nil {
+		sets = append(sets, "target_date = ?")
+		args = append(args, *req.TargetDate)
+	}
+	if req.UserID != nil {
+		sets = append(sets, "user_id = ?")
+		args = append(args, *req.UserID)
+	}
+
+	if len(sets) == 0 {
+		http.Error(w, "Nothing to update", http.StatusBadRequest)
+		return
+	}
+
+	if _, err := db.Exec("UPDATE savings_goals SET "+strings.Join(sets, ", ")+" WHERE id = ?", append(args, goal.ID)...); err != nil {
+		logger.Error(fmt.Sprintf("Failed to update goal: %v", err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	updated, err := getGoal(householdID(r), goal.ID)
+	if err != nil || updated == nil {
+		logger.Error(fmt.Sprintf("Failed to load updated goal: %v", err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(updated)
+}
+
+// createContributionHandler records money put towards a goal. Negative
+// amounts are withdrawals.
+func createContributionHandler(w http.ResponseWriter, r *http.Request, goal *Goal) {
+	var req ContributionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON body", http.StatusBadRequest)
+		return
+	}
+
+	if req.Amount == 0 || math.IsNaN(req.Amount) || math.IsInf(req.Amount, 0) {
+		http.Error(w, "Amount must be non-zero", http.StatusBadRequest)
+		return
+	}
+
+	if writeRefError(w, checkHouseholdRefs(householdID(r), householdRef{"user_id", "users", req.UserID})) {
+		return
+	}
+
+	var created sql.NullTime
+	if req.Date != nil {
+		date, err := time.Parse("2006-01-02", *req.Date)
+		if err != nil {
+			http.Error(w, "date must be in YYYY-MM-DD format", http.StatusBadRequest)
+			return
+		}
+		created = sql.NullTime{Time: date, Valid: true}
+	}
+
+	result, err := db.Exec(`
+		INSERT INTO goal_contributions (goal_id, amount, user_id, note, created_at)
+		VALUES (?, ?, ?, ?, COALESCE(?, NOW()))
+	`, goal.ID, req.Amount, req.UserID, req.Note, created)
+	if err != nil {
+		logger.Error(fmt.Sprintf("Failed to create goal contribution: %v", err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	contributionID, err := result.LastInsertId()
+	if err != nil {
+		logger.Error(fmt.Sprintf("Failed to get last insert ID: %v", err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	updated, err := getGoal(householdID(r), goal.ID)
+	if err != nil || updated == nil {
+		logger.Error(fmt.Sprintf("Failed to load goal after contribution: %v", err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"id":   contributionID,
+		"goal": updated,
+	})
+}
+
+func deleteContributionHandler(w http.ResponseWriter, goal *Goal, contributionID int) {
+	result, err := db.Exec("DELETE FROM goal_contributions WHERE id = ? AND goal_id = ?", contributionID, goal.ID)
+	if err != nil {
+		logger.Error(fmt.Sprintf("Failed to delete goal contribution: %v", err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		http.Error(w, "Contribution not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "Contribution deleted successfully",
+		"id":      contributionID,
+	})
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"fmt"
@@ -18,12 +19,29 @@ func getCategoriesHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Archived categories, everything below them, and archived subcategories
+	// are left out of the pickers unless include_archived=true.
+	hidden := map[int]bool{}
+	subcategoryArchivedCondition := " AND s.archived = 0"
+	if r.URL.Query().Get("include_archived") == "true" {
+		subcategoryArchivedCondition = ""
+	} else {
+		roots, _, err := loadCategoryTree(householdID(r))
+		if err != nil {
+			logger.Error(err.Error())
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		hidden = archivedSubtrees(roots)
+	}
+
 	rows, err := db.Query(`
-		SELECT c.id, c.name, s.id, s.name 
+		SELECT `+categoryRowColumns+`
 		FROM categories c 
-		LEFT JOIN subcategories s ON c.id = s.category_id 
-		ORDER BY c.id, s.id
-	`)
+		LEFT JOIN subcategories s ON c.id = s.category_id`+subcategoryArchivedCondition+`
+		WHERE c.household_id = ?
+		ORDER BY c.sort_order, c.name, c.id, s.sort_order, s.name, s.id
+	`, householdID(r))
 	if err != nil {
 		logger.Error(fmt.Sprintf("Failed to query categories: %v", err))
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
@@ -35,34 +53,25 @@ func getCategoriesHandler(w http.ResponseWriter, r *http.Request) {
 	var currentCategory *Category
 
 	for rows.Next() {
-		var categoryID int
-		var categoryName string
-		var subcategoryID sql.NullInt64
-		var subcategoryName sql.NullString
-
-		if err := rows.Scan(&categoryID, &categoryName, &subcategoryID, &subcategoryName); err != nil {
+		category, subcategory, err := scanCategoryRow(rows)
+		if err != nil {
 			logger.Error(fmt.Sprintf("Failed to scan category row: %v", err))
 			http.Error(w, "Internal server error", http.StatusInternalServerError)
 			return
 		}
+		if hidden[category.ID] {
+			continue
+		}
 
-		if currentCategory == nil || currentCategory.ID != categoryID {
+		if currentCategory == nil || currentCategory.ID != category.ID {
 			if currentCategory != nil {
 				categories = append(categories, *currentCategory)
 			}
-			currentCategory = &Category{
-				ID:            categoryID,
-				Name:          categoryName,
-				Subcategories: []Subcategory{},
-			}
+			currentCategory = &category
 		}
 
-		if subcategoryID.Valid && subcategoryName.Valid {
-			subcategory := Subcategory{
-				ID:   int(subcategoryID.Int64),
-				Name: subcategoryName.String,
-			}
-			currentCategory.Subcategories = append(currentCategory.Subcategories, subcategory)
+		if subcategory != nil {
+			currentCategory.Subcategories = append(currentCategory.Subcategories, *subcategory)
 		}
 	}
 
@@ -103,12 +112,12 @@ func getSingleCategoryHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	rows, err := db.Query(`
-		SELECT c.id, c.name, s.id, s.name 
+		SELECT `+categoryRowColumns+`
 		FROM categories c 
 		LEFT JOIN subcategories s ON c.id = s.category_id 
-		WHERE c.id = ?
-		ORDER BY s.id
-	`, categoryID)
+		WHERE c.id = ? AND c.household_id = ?
+		ORDER BY s.sort_order, s.name, s.id
+	`, categoryID, householdID(r))
 	if err != nil {
 		logger.Error(fmt.Sprintf("Failed to query category: %v", err))
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
@@ -119,31 +128,19 @@ func getSingleCategoryHandler(w http.ResponseWriter, r *http.Request) {
 	var category *Category
 
 	for rows.Next() {
-		var catID int
-		var catName string
-		var subcategoryID sql.NullInt64
-		var subcategoryName sql.NullString
-
-		if err := rows.Scan(&catID, &catName, &subcategoryID, &subcategoryName); err != nil {
+		row, subcategory, err := scanCategoryRow(rows)
+		if err != nil {
 			logger.Error(fmt.Sprintf("Failed to scan category row: %v", err))
 			http.Error(w, "Internal server error", http.StatusInternalServerError)
 			return
 		}
 
 		if category == nil {
-			category = &Category{
-				ID:            catID,
-				Name:          catName,
-				Subcategories: []Subcategory{},
-			}
+			category = &row
 		}
 
-		if subcategoryID.Valid && subcategoryName.Valid {
-			subcategory := Subcategory{
-				ID:   int(subcategoryID.Int64),
-				Name: subcategoryName.String,
-			}
-			category.Subcategories = append(category.Subcategories, subcategory)
+		if subcategory != nil {
+			category.Subcategories = append(category.Subcategories, *subcategory)
 		}
 	}
 
@@ -219,8 +216,9 @@ func getExpensesHandler(w http.ResponseWriter, r *http.Request) {
 
 	logger.Info(fmt.Sprintf("Received user_id: '%s', category_id: '%s', subcategory_id: '%s', date_from: '%s', date_to: '%s', group_by: '%s', order_by: '%s', order_dir: '%s', aggregates_only: '%s'", userIDStr, categoryIDStr, subcategoryIDStr, dateFromStr, dateToStr, groupByStr, orderByStr, orderDirStr, aggregatesOnlyStr))
 
-	if categoryIDStr != "" && subcategoryIDStr != "" {
-		http.Error(w, "Cannot use both category_id and subcategory_id in the same query", http.StatusBadRequest)
+	filter, err := parseExpenseFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
@@ -250,26 +248,12 @@ func getExpensesHandler(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	if dateFromStr != "" {
-		if _, err := time.Parse("2006-01-02", dateFromStr); err != nil {
-			http.Error(w, "Invalid date_from parameter. Must be in YYYY-MM-DD format", http.StatusBadRequest)
-			return
-		}
-	}
-
-	if dateToStr != "" {
-		if _, err := time.Parse("2006-01-02", dateToStr); err != nil {
-			http.Error(w, "Invalid date_to parameter. Must be in YYYY-MM-DD format", http.StatusBadRequest)
-			return
-		}
-	}
-
 	if groupByStr != "" {
 		switch groupByStr {
-		case "category", "subcategory", "user":
+		case "category", "subcategory", "user", "tag", "payee", "account":
 			// Valid group_by values
 		default:
-			http.Error(w, "Invalid group_by parameter. Must be 'category', 'subcategory', or 'user'", http.StatusBadRequest)
+			http.Error(w, "Invalid group_by parameter. Must be 'category', 'subcategory', 'user', 'tag', 'payee', or 'account'", http.StatusBadRequest)
 			return
 		}
 	}
@@ -280,101 +264,20 @@ func getExpensesHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if groupByStr != "" {
-		handleGroupedExpenses(w, userIDStr, categoryIDStr, subcategoryIDStr, dateFromStr, dateToStr, groupByStr, orderBy, orderDir)
+		handleGroupedExpenses(w, filter, groupByStr, orderDir)
 		return
 	}
 
 	if aggregatesOnly {
-		query := "SELECT SUM(e.amount) as total_amount FROM expenses e"
-		var args []interface{}
-		var conditions []string
-
-		if userIDStr != "" {
-			userID, err := strconv.Atoi(userIDStr)
-			if err != nil {
-				http.Error(w, "Invalid user_id parameter", http.StatusBadRequest)
-				return
-			}
-
-			if userID == 0 {
-				conditions = append(conditions, "e.user_id IS NULL")
-			} else {
-				conditions = append(conditions, "e.user_id = ?")
-				args = append(args, userID)
-			}
-		}
-
-		if categoryIDStr != "" {
-			logger.Info(fmt.Sprintf("Processing category_id: '%s'", categoryIDStr))
-			categoryIDs, err := parseCommaSeparatedInts(categoryIDStr)
-			if err != nil {
-				logger.Error(fmt.Sprintf("Failed to parse category_id: %v", err))
-				http.Error(w, "Invalid category_id parameter", http.StatusBadRequest)
-				return
-			}
-
-			logger.Info(fmt.Sprintf("Parsed category IDs: %v", categoryIDs))
-
-			if len(categoryIDs) > 0 {
-				query += " JOIN subcategories sc ON e.subcategory_id = sc.id"
-				placeholders := make([]string, len(categoryIDs))
-				for i := range categoryIDs {
-					placeholders[i] = "?"
-					args = append(args, categoryIDs[i])
-				}
-				condition := fmt.Sprintf("sc.category_id IN (%s)", strings.Join(placeholders, ","))
-				conditions = append(conditions, condition)
-				logger.Info(fmt.Sprintf("Added category condition: %s", condition))
-			}
-		}
-
-		if subcategoryIDStr != "" {
-			logger.Info(fmt.Sprintf("Processing subcategory_id: '%s'", subcategoryIDStr))
-			subcategoryIDs, err := parseCommaSeparatedInts(subcategoryIDStr)
-			if err != nil {
-				logger.Error(fmt.Sprintf("Failed to parse subcategory_id: %v", err))
-				http.Error(w, "Invalid subcategory_id parameter", http.StatusBadRequest)
-				return
-			}
-
-			logger.Info(fmt.Sprintf("Parsed subcategory IDs: %v", subcategoryIDs))
-
-			if len(subcategoryIDs) > 0 {
-				placeholders := make([]string, len(subcategoryIDs))
-				for i := range subcategoryIDs {
-					placeholders[i] = "?"
-					args = append(args, subcategoryIDs[i])
-				}
-				condition := fmt.Sprintf("e.subcategory_id IN (%s)", strings.Join(placeholders, ","))
-				conditions = append(conditions, condition)
-				logger.Info(fmt.Sprintf("Added subcategory condition: %s", condition))
-			}
-		}
-
-		if dateFromStr != "" {
-			conditions = append(conditions, "DATE(e.created_at) >= ?")
-			args = append(args, dateFromStr)
-		}
-
-		if dateToStr != "" {
-			conditions = append(conditions, "DATE(e.created_at) <= ?")
-			args = append(args, dateToStr)
-		}
-
-		if len(conditions) > 0 {
-			query += " WHERE " + strings.Join(conditions, " AND ")
-		}
-
-		var totalAmount sql.NullFloat64
-		err := db.QueryRow(query, args...).Scan(&totalAmount)
+		totalAmount, err := queryExpenseTotal(filter)
 		if err != nil {
-			logger.Error(fmt.Sprintf("Failed to query expense total: %v", err))
+			logger.Error(err.Error())
 			http.Error(w, "Internal server error", http.StatusInternalServerError)
 			return
 		}
 
 		response := map[string]interface{}{
-			"total_amount": totalAmount.Float64,
+			"total_amount": totalAmount,
 		}
 
 		w.Header().Set("Content-Type", "application/json")
@@ -382,6 +285,33 @@ func getExpensesHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if wantsCSV(r) {
+		streamExpensesCSV(w, filter, orderBy, orderDir)
+		return
+	}
+
+	expenses, err := queryExpenses(filter, orderBy, orderDir)
+	if err != nil {
+		logger.Error(err.Error())
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if expenses == nil {
+		expenses = []Expense{}
+	}
+	json.NewEncoder(w).Encode(expenses)
+}
+
+func openExpenseRows(filter ExpenseFilter, orderBy, orderDir string) (*sql.Rows, error) {
+	return openExpenseRowsContext(context.Background(), filter, orderBy, "", orderDir, 0)
+}
+
+// openExpenseRowsContext is openExpenseRows bound to ctx. A non-empty thenBy
+// breaks ties in orderBy, sorted in the same direction; a limit above 0 caps
+// the number of rows.
+func openExpenseRowsContext(ctx context.Context, filter ExpenseFilter, orderBy, thenBy, orderDir string, limit int) (*sql.Rows, error) {
 	query := `
 		SELECT 
 			e.id, 
@@ -393,169 +323,174 @@ func getExpensesHandler(w http.ResponseWriter, r *http.Request) {
 			u.email as user_email,
 			s.name as subcategory_name,
 			c.id as category_id,
-			c.name as category_name
+			c.name as category_name,
+			e.payee_id,
+			p.name as payee_name,
+			e.account_id,
+			acc.name as account_name,
+			(
+				SELECT GROUP_CONCAT(t.name ORDER BY t.name SEPARATOR ',')
+				FROM expense_tags et
+				JOIN tags t ON t.id = et.tag_id
+				WHERE et.expense_id = e.id
+			) as tags,
+			e.reimbursable,
+			e.claim_id
 		FROM expenses e
 		LEFT JOIN users u ON e.user_id = u.id
 		LEFT JOIN subcategories s ON e.subcategory_id = s.id
 		LEFT JOIN categories c ON s.category_id = c.id
+		LEFT JOIN payees p ON e.payee_id = p.id
+		LEFT JOIN accounts acc ON e.account_id = acc.id
 	`
-	var args []interface{}
-	var conditions []string
 
-	if userIDStr != "" {
-		userID, err := strconv.Atoi(userIDStr)
-		if err != nil {
-			http.Error(w, "Invalid user_id parameter", http.StatusBadRequest)
-			return
-		}
+	conditions, args := filter.conditions()
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
 
-		if userID == 0 {
-			conditions = append(conditions, "e.user_id IS NULL")
-		} else {
-			conditions = append(conditions, "e.user_id = ?")
-			args = append(args, userID)
-		}
+	query += fmt.Sprintf(" ORDER BY %s %s", orderBy, orderDir)
+	if thenBy != "" {
+		query += fmt.Sprintf(", %s %s", thenBy, orderDir)
+	}
+	if limit > 0 {
+		query += fmt.Sprintf(" LIMIT %d", limit)
 	}
 
-	if categoryIDStr != "" {
-		categoryIDs, err := parseCommaSeparatedInts(categoryIDStr)
-		if err != nil {
-			http.Error(w, "Invalid category_id parameter", http.StatusBadRequest)
-			return
-		}
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query expenses: %v", err)
+	}
 
-		if len(categoryIDs) > 0 {
-			query += " JOIN subcategories sc ON e.subcategory_id = sc.id"
-			placeholders := make([]string, len(categoryIDs))
-			for i := range categoryIDs {
-				placeholders[i] = "?"
-				args = append(args, categoryIDs[i])
-			}
-			conditions = append(conditions, fmt.Sprintf("sc.category_id IN (%s)", strings.Join(placeholders, ",")))
-		}
+	return rows, nil
+}
+
+func scanExpense(rows *sql.Rows) (Expense, error) {
+	var expense Expense
+	var subcategoryID sql.NullInt64
+	var userID sql.NullInt64
+	var note sql.NullString
+	var userEmail sql.NullString
+	var subcategoryName sql.NullString
+	var categoryID sql.NullInt64
+	var categoryName sql.NullString
+	var payeeID sql.NullInt64
+	var payeeName sql.NullString
+	var accountID sql.NullInt64
+	var accountName sql.NullString
+	var tags sql.NullString
+	var claimID sql.NullInt64
+
+	if err := rows.Scan(
+		&expense.ID,
+		&expense.Amount,
+		&subcategoryID,
+		&userID,
+		&note,
+		&expense.CreatedAt,
+		&userEmail,
+		&subcategoryName,
+		&categoryID,
+		&categoryName,
+		&payeeID,
+		&payeeName,
+		&accountID,
+		&accountName,
+		&tags,
+		&expense.Reimbursable,
+		&claimID,
+	); err != nil {
+		return expense, fmt.Errorf("failed to scan expense row: %v", err)
 	}
 
-	if subcategoryIDStr != "" {
-		subcategoryIDs, err := parseCommaSeparatedInts(subcategoryIDStr)
-		if err != nil {
-			http.Error(w, "Invalid subcategory_id parameter", http.StatusBadRequest)
-			return
-		}
+	expense.SubcategoryID = int(subcategoryID.Int64)
 
-		if len(subcategoryIDs) > 0 {
-			placeholders := make([]string, len(subcategoryIDs))
-			for i := range subcategoryIDs {
-				placeholders[i] = "?"
-				args = append(args, subcategoryIDs[i])
-			}
-			conditions = append(conditions, fmt.Sprintf("e.subcategory_id IN (%s)", strings.Join(placeholders, ",")))
-		}
+	if userID.Valid {
+		userIDValue := int(userID.Int64)
+		expense.UserID = &userIDValue
 	}
 
-	if dateFromStr != "" {
-		conditions = append(conditions, "DATE(e.created_at) >= ?")
-		args = append(args, dateFromStr)
+	if note.Valid {
+		expense.Note = &note.String
 	}
 
-	if dateToStr != "" {
-		conditions = append(conditions, "DATE(e.created_at) <= ?")
-		args = append(args, dateToStr)
+	if userEmail.Valid {
+		expense.UserEmail = &userEmail.String
 	}
 
-	if len(conditions) > 0 {
-		query += " WHERE " + strings.Join(conditions, " AND ")
+	if subcategoryName.Valid {
+		expense.SubcategoryName = &subcategoryName.String
 	}
 
-	query += fmt.Sprintf(" ORDER BY %s %s", orderBy, orderDir)
+	if categoryID.Valid {
+		categoryIDValue := int(categoryID.Int64)
+		expense.CategoryID = &categoryIDValue
+	}
 
-	rows, err := db.Query(query, args...)
-	if err != nil {
-		logger.Error(fmt.Sprintf("Failed to query expenses: %v", err))
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
-		return
+	if categoryName.Valid {
+		expense.CategoryName = &categoryName.String
 	}
-	defer rows.Close()
 
-	var expenses []Expense
+	if payeeID.Valid {
+		payeeIDValue := int(payeeID.Int64)
+		expense.PayeeID = &payeeIDValue
+	}
 
-	for rows.Next() {
-		var expense Expense
-		var userID sql.NullInt64
-		var note sql.NullString
-		var userEmail sql.NullString
-		var subcategoryName sql.NullString
-		var categoryID sql.NullInt64
-		var categoryName sql.NullString
+	if payeeName.Valid {
+		expense.PayeeName = &payeeName.String
+	}
 
-		if err := rows.Scan(
-			&expense.ID,
-			&expense.Amount,
-			&expense.SubcategoryID,
-			&userID,
-			&note,
-			&expense.CreatedAt,
-			&userEmail,
-			&subcategoryName,
-			&categoryID,
-			&categoryName,
-		); err != nil {
-			logger.Error(fmt.Sprintf("Failed to scan expense row: %v", err))
-			http.Error(w, "Internal server error", http.StatusInternalServerError)
-			return
-		}
+	if accountID.Valid {
+		accountIDValue := int(accountID.Int64)
+		expense.AccountID = &accountIDValue
+	}
 
-		if userID.Valid {
-			userIDValue := int(userID.Int64)
-			expense.UserID = &userIDValue
-		} else {
-			expense.UserID = nil
-		}
+	if accountName.Valid {
+		expense.AccountName = &accountName.String
+	}
 
-		if note.Valid {
-			expense.Note = &note.String
-		} else {
-			expense.Note = nil
-		}
+	expense.Tags = splitTags(tags)
+	expense.ClaimID = nullableInt(claimID)
 
-		if userEmail.Valid {
-			expense.UserEmail = &userEmail.String
-		}
+	return expense, nil
+}
 
-		if subcategoryName.Valid {
-			expense.SubcategoryName = &subcategoryName.String
-		}
+func queryExpenses(filter ExpenseFilter, orderBy, orderDir string) ([]Expense, error) {
+	return queryExpensesContext(context.Background(), filter, orderBy, "", orderDir, 0)
+}
 
-		if categoryID.Valid {
-			categoryIDValue := int(categoryID.Int64)
-			expense.CategoryID = &categoryIDValue
-		}
+func queryExpensesContext(ctx context.Context, filter ExpenseFilter, orderBy, thenBy, orderDir string, limit int) ([]Expense, error) {
+	rows, err := openExpenseRowsContext(ctx, filter, orderBy, thenBy, orderDir, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
 
-		if categoryName.Valid {
-			expense.CategoryName = &categoryName.String
-		}
+	var expenses []Expense
 
+	for rows.Next() {
+		expense, err := scanExpense(rows)
+		if err != nil {
+			return nil, err
+		}
 		expenses = append(expenses, expense)
 	}
 
 	if err = rows.Err(); err != nil {
-		logger.Error(fmt.Sprintf("Error iterating over rows: %v", err))
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
-		return
+		return nil, fmt.Errorf("error iterating over rows: %v", err)
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	if expenses == nil {
-		expenses = []Expense{}
-	}
-	json.NewEncoder(w).Encode(expenses)
+	return expenses, nil
+}
+
+func queryExpenseGroups(filter ExpenseFilter, groupBy, orderDir string) ([]ExpenseGroup, error) {
+	return queryExpenseGroupsContext(context.Background(), filter, groupBy, orderDir)
 }
 
-func handleGroupedExpenses(w http.ResponseWriter, userIDStr, categoryIDStr, subcategoryIDStr, dateFromStr, dateToStr, groupByStr, orderBy, orderDir string) {
+func queryExpenseGroupsContext(ctx context.Context, filter ExpenseFilter, groupBy, orderDir string) ([]ExpenseGroup, error) {
 	var query string
-	var args []interface{}
-	var conditions []string
 
-	switch groupByStr {
+	switch groupBy {
 	case "category":
 		query = `
 			SELECT 
@@ -586,125 +521,141 @@ func handleGroupedExpenses(w http.ResponseWriter, userIDStr, categoryIDStr, subc
 				COUNT(*) as expense_count
 			FROM expenses e
 			LEFT JOIN users u ON e.user_id = u.id
+			LEFT JOIN subcategories s ON e.subcategory_id = s.id
 		`
+	case "tag":
+		query = `
+			SELECT 
+				t.id as tag_id,
+				t.name as tag_name,
+				SUM(e.amount) as total_amount,
+				COUNT(*) as expense_count
+			FROM expenses e
+			JOIN expense_tags et ON et.expense_id = e.id
+			JOIN tags t ON t.id = et.tag_id
+			LEFT JOIN subcategories s ON e.subcategory_id = s.id
+		`
+	case "payee":
+		query = `
+			SELECT 
+				p.id as payee_id,
+				p.name as payee_name,
+				SUM(e.amount) as total_amount,
+				COUNT(*) as expense_count
+			FROM expenses e
+			JOIN payees p ON e.payee_id = p.id
+			LEFT JOIN subcategories s ON e.subcategory_id = s.id
+		`
+	case "account":
+		query = `
+			SELECT 
+				acc.id as account_id,
+				acc.name as account_name,
+				SUM(e.amount) as total_amount,
+				COUNT(*) as expense_count
+			FROM expenses e
+			JOIN accounts acc ON e.account_id = acc.id
+			LEFT JOIN subcategories s ON e.subcategory_id = s.id
+		`
+	default:
+		return nil, fmt.Errorf("unsupported group_by: %s", groupBy)
 	}
 
-	if userIDStr != "" {
-		userID, err := strconv.Atoi(userIDStr)
-		if err != nil {
-			http.Error(w, "Invalid user_id parameter", http.StatusBadRequest)
-			return
-		}
-
-		if userID == 0 {
-			conditions = append(conditions, "e.user_id IS NULL")
-		} else {
-			conditions = append(conditions, "e.user_id = ?")
-			args = append(args, userID)
-		}
-	}
-
-	if categoryIDStr != "" {
-		categoryIDs, err := parseCommaSeparatedInts(categoryIDStr)
-		if err != nil {
-			http.Error(w, "Invalid category_id parameter", http.StatusBadRequest)
-			return
-		}
-
-		if len(categoryIDs) > 0 {
-			placeholders := make([]string, len(categoryIDs))
-			for i := range categoryIDs {
-				placeholders[i] = "?"
-				args = append(args, categoryIDs[i])
-			}
-			conditions = append(conditions, fmt.Sprintf("s.category_id IN (%s)", strings.Join(placeholders, ",")))
-		}
-	}
-
-	if subcategoryIDStr != "" {
-		subcategoryIDs, err := parseCommaSeparatedInts(subcategoryIDStr)
-		if err != nil {
-			http.Error(w, "Invalid subcategory_id parameter", http.StatusBadRequest)
-			return
-		}
-
-		if len(subcategoryIDs) > 0 {
-			placeholders := make([]string, len(subcategoryIDs))
-			for i := range subcategoryIDs {
-				placeholders[i] = "?"
-				args = append(args, subcategoryIDs[i])
-			}
-			conditions = append(conditions, fmt.Sprintf("e.subcategory_id IN (%s)", strings.Join(placeholders, ",")))
-		}
-	}
-
-	if dateFromStr != "" {
-		conditions = append(conditions, "DATE(e.created_at) >= ?")
-		args = append(args, dateFromStr)
-	}
-
-	if dateToStr != "" {
-		conditions = append(conditions, "DATE(e.created_at) <= ?")
-		args = append(args, dateToStr)
-	}
-
+	conditions, args := filter.conditions()
 	if len(conditions) > 0 {
 		query += " WHERE " + strings.Join(conditions, " AND ")
 	}
 
 	query += " GROUP BY "
-	switch groupByStr {
+	switch groupBy {
 	case "category":
 		query += "c.id, c.name"
 	case "subcategory":
 		query += "s.id, s.name"
 	case "user":
 		query += "e.user_id, u.display_name"
+	case "tag":
+		query += "t.id, t.name"
+	case "payee":
+		query += "p.id, p.name"
+	case "account":
+		query += "acc.id, acc.name"
 	}
 
 	query += fmt.Sprintf(" ORDER BY total_amount %s", orderDir)
 
-	rows, err := db.Query(query, args...)
+	rows, err := db.QueryContext(ctx, query, args...)
 	if err != nil {
-		logger.Error(fmt.Sprintf("Failed to query grouped expenses: %v", err))
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
-		return
+		return nil, fmt.Errorf("failed to query grouped expenses: %v", err)
 	}
 	defer rows.Close()
 
-	var groupedExpenses []map[string]interface{}
+	var groups []ExpenseGroup
 
 	for rows.Next() {
-		var groupName string
-		var totalAmount float64
-		var expenseCount int
-		var groupID int
-
-		if err := rows.Scan(&groupID, &groupName, &totalAmount, &expenseCount); err != nil {
-			logger.Error(fmt.Sprintf("Failed to scan grouped expense row: %v", err))
-			http.Error(w, "Internal server error", http.StatusInternalServerError)
-			return
+		var group ExpenseGroup
+		if err := rows.Scan(&group.ID, &group.Name, &group.Total, &group.Count); err != nil {
+			return nil, fmt.Errorf("failed to scan grouped expense row: %v", err)
 		}
+		groups = append(groups, group)
+	}
 
-		group := map[string]interface{}{
-			"group_name": groupName,
-			"total":      totalAmount,
-			"count":      expenseCount,
-		}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over grouped rows: %v", err)
+	}
+
+	return groups, nil
+}
+
+func queryExpenseTotal(filter ExpenseFilter) (float64, error) {
+	return queryExpenseTotalContext(context.Background(), filter)
+}
+
+func queryExpenseTotalContext(ctx context.Context, filter ExpenseFilter) (float64, error) {
+	query := "SELECT SUM(e.amount) as total_amount FROM expenses e LEFT JOIN subcategories s ON e.subcategory_id = s.id"
+
+	conditions, args := filter.conditions()
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
 
-		groupedExpenses = append(groupedExpenses, group)
+	var totalAmount sql.NullFloat64
+	if err := db.QueryRowContext(ctx, query, args...).Scan(&totalAmount); err != nil {
+		return 0, fmt.Errorf("failed to query expense total: %v", err)
 	}
 
-	if err = rows.Err(); err != nil {
-		logger.Error(fmt.Sprintf("Error iterating over grouped rows: %v", err))
+	return totalAmount.Float64, nil
+}
+
+func handleGroupedExpenses(w http.ResponseWriter, filter ExpenseFilter, groupByStr, orderDir string) {
+	groups, err := queryExpenseGroups(filter, groupByStr, orderDir)
+	if err != nil {
+		logger.Error(err.Error())
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	if groupedExpenses == nil {
-		groupedExpenses = []map[string]interface{}{}
+	if groupByStr == "category" {
+		writeCategoryGroups(w, filter.HouseholdID, groups)
+		return
 	}
+
+	writeGroups(w, groups)
+}
+
+// writeGroups encodes grouped totals in the response shape shared by
+// expenses and incomes.
+func writeGroups(w http.ResponseWriter, groups []ExpenseGroup) {
+	groupedExpenses := []map[string]interface{}{}
+	for _, group := range groups {
+		groupedExpenses = append(groupedExpenses, map[string]interface{}{
+			"group_name": group.Name,
+			"total":      group.Total,
+			"count":      group.Count,
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(groupedExpenses)
 }
 
@@ -727,7 +678,8 @@ func updateCategoryHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var requestBody struct {
-		Name string `json:"name"`
+		Name *string `json:"name"`
+		categoryMetadata
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
@@ -735,48 +687,70 @@ func updateCategoryHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if requestBody.Name == "" {
+	if requestBody.Name != nil && *requestBody.Name == "" {
 		http.Error(w, "Category name is required", http.StatusBadRequest)
 		return
 	}
 
-	var existingID int
-	err = db.QueryRow("SELECT id FROM categories WHERE name = ? AND id != ?", requestBody.Name, categoryID).Scan(&existingID)
-	if err == nil {
-		http.Error(w, "Category name already exists", http.StatusConflict)
-		return
-	} else if err != sql.ErrNoRows {
-		logger.Error(fmt.Sprintf("Failed to check category name uniqueness: %v", err))
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	if err := requestBody.validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	result, err := db.Exec("UPDATE categories SET name = ? WHERE id = ?", requestBody.Name, categoryID)
+	exists, err := inHousehold(householdID(r), "categories", categoryID)
 	if err != nil {
-		logger.Error(fmt.Sprintf("Failed to update category: %v", err))
+		logger.Error(err.Error())
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
+	if !exists {
+		http.Error(w, "Category not found", http.StatusNotFound)
+		return
+	}
 
-	rowsAffected, err := result.RowsAffected()
-	if err != nil {
-		logger.Error(fmt.Sprintf("Failed to get rows affected: %v", err))
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	sets, args := requestBody.sets()
+
+	if requestBody.Name != nil {
+		var existingID int
+		err = db.QueryRow("SELECT id FROM categories WHERE name = ? AND id != ? AND household_id = ?", *requestBody.Name, categoryID, householdID(r)).Scan(&existingID)
+		if err == nil {
+			http.Error(w, "Category name already exists", http.StatusConflict)
+			return
+		} else if err != sql.ErrNoRows {
+			logger.Error(fmt.Sprintf("Failed to check category name uniqueness: %v", err))
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		sets = append([]string{"name = ?"}, sets...)
+		args = append([]interface{}{*requestBody.Name}, args...)
+	}
+
+	if len(sets) == 0 {
+		http.Error(w, "Nothing to update", http.StatusBadRequest)
 		return
 	}
 
-	if rowsAffected == 0 {
-		http.Error(w, "Category not found", http.StatusNotFound)
+	if _, err := db.Exec("UPDATE categories SET "+strings.Join(sets, ", ")+" WHERE id = ?", append(args, categoryID)...); err != nil {
+		logger.Error(fmt.Sprintf("Failed to update category: %v", err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(map[string]interface{}{
+	if requestBody.Name != nil {
+		invalidateExpenseCaches()
+	}
+
+	response := map[string]interface{}{
 		"message": "Category updated successfully",
 		"id":      categoryID,
-		"name":    requestBody.Name,
-	})
+	}
+	if requestBody.Name != nil {
+		response["name"] = *requestBody.Name
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
 }
 
 func getSingleSubcategoryHandler(w http.ResponseWriter, r *http.Request) {
@@ -803,11 +777,14 @@ func getSingleSubcategoryHandler(w http.ResponseWriter, r *http.Request) {
 	var categoryName string
 
 	err = db.QueryRow(`
-		SELECT s.id, s.name, s.category_id, c.name as category_name
+		SELECT s.id, s.name, s.category_id, c.name as category_name, s.color, s.icon, s.sort_order, s.archived
 		FROM subcategories s
 		JOIN categories c ON s.category_id = c.id
-		WHERE s.id = ?
-	`, subcategoryID).Scan(&subcategory.ID, &subcategory.Name, &categoryID, &categoryName)
+		WHERE s.id = ? AND c.household_id = ?
+	`, subcategoryID, householdID(r)).Scan(
+		&subcategory.ID, &subcategory.Name, &categoryID, &categoryName,
+		&subcategory.Color, &subcategory.Icon, &subcategory.SortOrder, &subcategory.Archived,
+	)
 
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -824,6 +801,10 @@ func getSingleSubcategoryHandler(w http.ResponseWriter, r *http.Request) {
 		"name":          subcategory.Name,
 		"category_id":   categoryID,
 		"category_name": categoryName,
+		"color":         subcategory.Color,
+		"icon":          subcategory.Icon,
+		"sort_order":    subcategory.SortOrder,
+		"archived":      subcategory.Archived,
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -849,7 +830,8 @@ func updateSubcategoryHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var requestBody struct {
-		Name string `json:"name"`
+		Name *string `json:"name"`
+		categoryMetadata
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
@@ -857,48 +839,70 @@ func updateSubcategoryHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if requestBody.Name == "" {
+	if requestBody.Name != nil && *requestBody.Name == "" {
 		http.Error(w, "Subcategory name is required", http.StatusBadRequest)
 		return
 	}
 
-	var existingID int
-	err = db.QueryRow("SELECT id FROM subcategories WHERE name = ? AND category_id = (SELECT category_id FROM subcategories WHERE id = ?) AND id != ?", requestBody.Name, subcategoryID, subcategoryID).Scan(&existingID)
-	if err == nil {
-		http.Error(w, "Subcategory name already exists in this category", http.StatusConflict)
-		return
-	} else if err != sql.ErrNoRows {
-		logger.Error(fmt.Sprintf("Failed to check subcategory name uniqueness: %v", err))
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	if err := requestBody.validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	result, err := db.Exec("UPDATE subcategories SET name = ? WHERE id = ?", requestBody.Name, subcategoryID)
+	exists, err := inHousehold(householdID(r), "subcategories", subcategoryID)
 	if err != nil {
-		logger.Error(fmt.Sprintf("Failed to update subcategory: %v", err))
+		logger.Error(err.Error())
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
+	if !exists {
+		http.Error(w, "Subcategory not found", http.StatusNotFound)
+		return
+	}
 
-	rowsAffected, err := result.RowsAffected()
-	if err != nil {
-		logger.Error(fmt.Sprintf("Failed to get rows affected: %v", err))
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	sets, args := requestBody.sets()
+
+	if requestBody.Name != nil {
+		var existingID int
+		err = db.QueryRow("SELECT id FROM subcategories WHERE name = ? AND category_id = (SELECT category_id FROM subcategories WHERE id = ?) AND id != ?", *requestBody.Name, subcategoryID, subcategoryID).Scan(&existingID)
+		if err == nil {
+			http.Error(w, "Subcategory name already exists in this category", http.StatusConflict)
+			return
+		} else if err != sql.ErrNoRows {
+			logger.Error(fmt.Sprintf("Failed to check subcategory name uniqueness: %v", err))
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		sets = append([]string{"name = ?"}, sets...)
+		args = append([]interface{}{*requestBody.Name}, args...)
+	}
+
+	if len(sets) == 0 {
+		http.Error(w, "Nothing to update", http.StatusBadRequest)
 		return
 	}
 
-	if rowsAffected == 0 {
-		http.Error(w, "Subcategory not found", http.StatusNotFound)
+	if _, err := db.Exec("UPDATE subcategories SET "+strings.Join(sets, ", ")+" WHERE id = ?", append(args, subcategoryID)...); err != nil {
+		logger.Error(fmt.Sprintf("Failed to update subcategory: %v", err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(map[string]interface{}{
+	if requestBody.Name != nil {
+		invalidateExpenseCaches()
+	}
+
+	response := map[string]interface{}{
 		"message": "Subcategory updated successfully",
 		"id":      subcategoryID,
-		"name":    requestBody.Name,
-	})
+	}
+	if requestBody.Name != nil {
+		response["name"] = *requestBody.Name
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
 }
 
 func createCategoryHandler(w http.ResponseWriter, r *http.Request) {
@@ -908,7 +912,9 @@ func createCategoryHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var requestBody struct {
-		Name string `json:"name"`
+		Name     string `json:"name"`
+		ParentID *int   `json:"parent_id"`
+		categoryMetadata
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
@@ -921,8 +927,20 @@ func createCategoryHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err := requestBody.validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if requestBody.ParentID != nil && *requestBody.ParentID == 0 {
+		requestBody.ParentID = nil
+	}
+	if writeRefError(w, checkHouseholdRefs(householdID(r), householdRef{"parent_id", "categories", requestBody.ParentID})) {
+		return
+	}
+
 	var existingID int
-	err := db.QueryRow("SELECT id FROM categories WHERE name = ?", requestBody.Name).Scan(&existingID)
+	err := db.QueryRow("SELECT id FROM categories WHERE name = ? AND household_id = ?", requestBody.Name, householdID(r)).Scan(&existingID)
 	if err == nil {
 		http.Error(w, "Category name already exists", http.StatusConflict)
 		return
@@ -932,99 +950,342 @@ func createCategoryHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	result, err := db.Exec("INSERT INTO categories (name) VALUES (?)", requestBody.Name)
+	if requestBody.SortOrder == nil {
+		next, err := nextSortOrder("SELECT COALESCE(MAX(sort_order) + 1, 0) FROM categories WHERE household_id = ?", householdID(r))
+		if err != nil {
+			logger.Error(err.Error())
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		requestBody.SortOrder = &next
+	}
+
+	result, err := db.Exec(
+		"INSERT INTO categories (household_id, name, parent_id, color, icon, sort_order, archived) VALUES (?, ?, ?, ?, ?, ?, ?)",
+		householdID(r), requestBody.Name, requestBody.ParentID,
+		nullIfEmpty(requestBody.Color), nullIfEmpty(requestBody.Icon), *requestBody.SortOrder, requestBody.Archived != nil && *requestBody.Archived,
+	)
 	if err != nil {
 		logger.Error(fmt.Sprintf("Failed to create category: %v", err))
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
 
-	categoryID, err := result.LastInsertId()
-	if err != nil {
-		logger.Error(fmt.Sprintf("Failed to get last insert ID: %v", err))
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
-		return
+	categoryID, err := result.LastInsertId()
+	if err != nil {
+		logger.Error(fmt.Sprintf("Failed to get last insert ID: %v", err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	response := map[string]interface{}{
+		"id":        categoryID,
+		"name":      requestBody.Name,
+		"parent_id": requestBody.ParentID,
+		"message":   "Category created successfully",
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(response)
+}
+
+func createSubcategoryHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var requestBody struct {
+		Name       string `json:"name"`
+		CategoryID int    `json:"category_id"`
+		categoryMetadata
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
+		http.Error(w, "Invalid JSON body", http.StatusBadRequest)
+		return
+	}
+
+	if len(requestBody.Name) < 3 {
+		http.Error(w, "Subcategory name must be at least 3 characters long", http.StatusBadRequest)
+		return
+	}
+
+	if requestBody.CategoryID <= 0 {
+		http.Error(w, "Valid category_id is required", http.StatusBadRequest)
+		return
+	}
+
+	if err := requestBody.validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var categoryExists int
+	err := db.QueryRow("SELECT id FROM categories WHERE id = ? AND household_id = ?", requestBody.CategoryID, householdID(r)).Scan(&categoryExists)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Category not found", http.StatusNotFound)
+			return
+		}
+		logger.Error(fmt.Sprintf("Failed to check category existence: %v", err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	var existingID int
+	err = db.QueryRow("SELECT id FROM subcategories WHERE name = ? AND category_id = ?", requestBody.Name, requestBody.CategoryID).Scan(&existingID)
+	if err == nil {
+		http.Error(w, "Subcategory name already exists in this category", http.StatusConflict)
+		return
+	} else if err != sql.ErrNoRows {
+		logger.Error(fmt.Sprintf("Failed to check subcategory name uniqueness: %v", err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	if requestBody.SortOrder == nil {
+		next, err := nextSortOrder("SELECT COALESCE(MAX(sort_order) + 1, 0) FROM subcategories WHERE category_id = ?", requestBody.CategoryID)
+		if err != nil {
+			logger.Error(err.Error())
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		requestBody.SortOrder = &next
+	}
+
+	result, err := db.Exec(
+		"INSERT INTO subcategories (name, category_id, color, icon, sort_order, archived) VALUES (?, ?, ?, ?, ?, ?)",
+		requestBody.Name, requestBody.CategoryID,
+		nullIfEmpty(requestBody.Color), nullIfEmpty(requestBody.Icon), *requestBody.SortOrder, requestBody.Archived != nil && *requestBody.Archived,
+	)
+	if err != nil {
+		logger.Error(fmt.Sprintf("Failed to create subcategory: %v", err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	subcategoryID, err := result.LastInsertId()
+	if err != nil {
+		logger.Error(fmt.Sprintf("Failed to get last insert ID: %v", err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	response := map[string]interface{}{
+		"id":          subcategoryID,
+		"name":        requestBody.Name,
+		"category_id": requestBody.CategoryID,
+		"message":     "Subcategory created successfully",
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(response)
+}
+
+type CreateExpenseRequest struct {
+	Amount        float64       `json:"amount"`
+	SubcategoryID *int          `json:"subcategory_id,omitempty"`
+	UserID        *int          `json:"user_id,omitempty"`
+	Note          *string       `json:"note,omitempty"`
+	PayeeID       *int          `json:"payee_id,omitempty"`
+	AccountID     *int          `json:"account_id,omitempty"`
+	Tags          []string      `json:"tags,omitempty"`
+	Split         *SplitRequest `json:"split,omitempty"`
+	Reimbursable  bool          `json:"reimbursable,omitempty"`
+}
+
+type sqlExecer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+func validateCreateExpenseRequest(req CreateExpenseRequest) error {
+	if req.Amount <= 0 {
+		return fmt.Errorf("Amount must be greater than 0")
+	}
+	if _, err := normalizeTags(req.Tags); err != nil {
+		return err
+	}
+	if req.Split != nil {
+		if req.UserID == nil {
+			return fmt.Errorf("A split expense needs a user_id (the payer)")
+		}
+		if _, err := computeSplitShares(req.Amount, *req.Split); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// expenseRefs lists the rows an expense request points at, so they can be
+// checked against the caller's household.
+func expenseRefs(req CreateExpenseRequest) []householdRef {
+	refs := []householdRef{
+		{"subcategory_id", "subcategories", req.SubcategoryID},
+		{"user_id", "users", req.UserID},
+		{"payee_id", "payees", req.PayeeID},
+		{"account_id", "accounts", req.AccountID},
+	}
+	if req.Split != nil {
+		for i := range req.Split.Shares {
+			refs = append(refs, householdRef{"split user_id", "users", &req.Split.Shares[i].UserID})
+		}
+	}
+	return refs
+}
+
+// insertExpense stores a validated expense in the household. A nil createdAt
+// lets the database stamp the row with NOW().
+func insertExpense(exec sqlExecer, householdID int, req CreateExpenseRequest, createdAt *time.Time) (int64, error) {
+	var subcategoryID sql.NullInt64
+	if req.SubcategoryID != nil {
+		subcategoryID.Int64 = int64(*req.SubcategoryID)
+		subcategoryID.Valid = true
+	}
+
+	var userID sql.NullInt64
+	if req.UserID != nil {
+		userID.Int64 = int64(*req.UserID)
+		userID.Valid = true
+	}
+
+	var note sql.NullString
+	if req.Note != nil {
+		note.String = *req.Note
+		note.Valid = true
+	}
+
+	var payeeID sql.NullInt64
+	if req.PayeeID != nil {
+		payeeID.Int64 = int64(*req.PayeeID)
+		payeeID.Valid = true
+	}
+
+	var accountID sql.NullInt64
+	if req.AccountID != nil {
+		accountID.Int64 = int64(*req.AccountID)
+		accountID.Valid = true
+	}
+
+	var created sql.NullTime
+	if createdAt != nil {
+		created.Time = *createdAt
+		created.Valid = true
+	}
+
+	result, err := exec.Exec(`
+		INSERT INTO expenses (household_id, amount, subcategory_id, user_id, note, payee_id, account_id, reimbursable, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, COALESCE(?, NOW()))
+	`, householdID, req.Amount, subcategoryID, userID, note, payeeID, accountID, req.Reimbursable, created)
+
+	if err != nil {
+		return 0, fmt.Errorf("failed to create expense: %v", err)
+	}
+
+	expenseID, err := result.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get last insert ID: %v", err)
+	}
+
+	if len(req.Tags) > 0 {
+		if err := setExpenseTags(exec, householdID, expenseID, req.Tags); err != nil {
+			return 0, err
+		}
 	}
 
-	response := map[string]interface{}{
-		"id":      categoryID,
-		"name":    requestBody.Name,
-		"message": "Category created successfully",
+	if req.Split != nil {
+		shares, err := computeSplitShares(req.Amount, *req.Split)
+		if err != nil {
+			return 0, err
+		}
+		if err := saveExpenseSplit(exec, expenseID, shares); err != nil {
+			return 0, err
+		}
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(response)
+	return expenseID, nil
 }
 
-func createSubcategoryHandler(w http.ResponseWriter, r *http.Request) {
+func createExpenseHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	var requestBody struct {
-		Name       string `json:"name"`
-		CategoryID int    `json:"category_id"`
+	var req CreateExpenseRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		logger.Error(fmt.Sprintf("Failed to decode request body: %v", err))
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
 	}
 
-	if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
-		http.Error(w, "Invalid JSON body", http.StatusBadRequest)
+	if err := validateCreateExpenseRequest(req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	if len(requestBody.Name) < 3 {
-		http.Error(w, "Subcategory name must be at least 3 characters long", http.StatusBadRequest)
+	if writeRefError(w, checkHouseholdRefs(householdID(r), expenseRefs(req)...)) {
 		return
 	}
 
-	if requestBody.CategoryID <= 0 {
-		http.Error(w, "Valid category_id is required", http.StatusBadRequest)
-		return
+	if req.SubcategoryID == nil {
+		rules, err := loadCategorizationRules(householdID(r))
+		if err != nil {
+			logger.Error(err.Error())
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		categorizeExpenseRequest(rules, &req)
 	}
 
-	var categoryExists int
-	err := db.QueryRow("SELECT id FROM categories WHERE id = ?", requestBody.CategoryID).Scan(&categoryExists)
-	if err != nil {
-		if err == sql.ErrNoRows {
-			http.Error(w, "Category not found", http.StatusNotFound)
+	if req.PayeeID == nil && req.Note != nil {
+		matchers, err := loadPayeeMatchers(householdID(r))
+		if err != nil {
+			logger.Error(err.Error())
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
 			return
 		}
-		logger.Error(fmt.Sprintf("Failed to check category existence: %v", err))
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
-		return
+		suggestPayeeForRequest(matchers, &req)
 	}
 
-	var existingID int
-	err = db.QueryRow("SELECT id FROM subcategories WHERE name = ? AND category_id = ?", requestBody.Name, requestBody.CategoryID).Scan(&existingID)
-	if err == nil {
-		http.Error(w, "Subcategory name already exists in this category", http.StatusConflict)
-		return
-	} else if err != sql.ErrNoRows {
-		logger.Error(fmt.Sprintf("Failed to check subcategory name uniqueness: %v", err))
+	tx, err := db.Begin()
+	if err != nil {
+		logger.Error(fmt.Sprintf("Failed to begin transaction: %v", err))
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
+	defer tx.Rollback()
 
-	result, err := db.Exec("INSERT INTO subcategories (name, category_id) VALUES (?, ?)", requestBody.Name, requestBody.CategoryID)
+	expenseID, err := insertExpense(tx, householdID(r), req, nil)
 	if err != nil {
-		logger.Error(fmt.Sprintf("Failed to create subcategory: %v", err))
+		logger.Error(err.Error())
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
 
-	subcategoryID, err := result.LastInsertId()
-	if err != nil {
-		logger.Error(fmt.Sprintf("Failed to get last insert ID: %v", err))
+	if err := tx.Commit(); err != nil {
+		logger.Error(fmt.Sprintf("Failed to commit expense: %v", err))
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
 
+	invalidateExpenseCaches()
+
+	tags, _ := normalizeTags(req.Tags)
+	if tags == nil {
+		tags = []string{}
+	}
+
 	response := map[string]interface{}{
-		"id":          subcategoryID,
-		"name":        requestBody.Name,
-		"category_id": requestBody.CategoryID,
-		"message":     "Subcategory created successfully",
+		"id":             expenseID,
+		"amount":         req.Amount,
+		"subcategory_id": req.SubcategoryID,
+		"payee_id":       req.PayeeID,
+		"account_id":     req.AccountID,
+		"tags":           tags,
+		"message":        "Expense created successfully",
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -1032,76 +1293,192 @@ func createSubcategoryHandler(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(response)
 }
 
-type CreateExpenseRequest struct {
-	Amount        float64 `json:"amount"`
-	SubcategoryID *int    `json:"subcategory_id,omitempty"`
-	UserID        *int    `json:"user_id,omitempty"`
-	Note          *string `json:"note,omitempty"`
+type UpdateExpenseRequest struct {
+	Amount        *float64  `json:"amount"`
+	SubcategoryID *int      `json:"subcategory_id"`
+	UserID        *int      `json:"user_id"`
+	Note          *string   `json:"note"`
+	Date          *string   `json:"date"`
+	PayeeID       *int      `json:"payee_id"`
+	AccountID     *int      `json:"account_id"`
+	Tags          *[]string `json:"tags"`
+	Reimbursable  *bool     `json:"reimbursable"`
 }
 
-func createExpenseHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
+func updateExpenseHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut && r.Method != http.MethodPatch {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	var req CreateExpenseRequest
+	expenseID, err := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/api/v1/expenses/"))
+	if err != nil {
+		http.Error(w, "Invalid expense ID", http.StatusBadRequest)
+		return
+	}
+
+	var req UpdateExpenseRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		logger.Error(fmt.Sprintf("Failed to decode request body: %v", err))
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
 
-	if req.Amount <= 0 {
-		http.Error(w, "Amount must be greater than 0", http.StatusBadRequest)
-		return
-	}
+	// Only the fields present in the body change; tags: [] clears the tags.
+	var sets []string
+	var args []interface{}
 
-	var subcategoryID sql.NullInt64
+	if req.Amount != nil {
+		if *req.Amount <= 0 {
+			http.Error(w, "Amount must be greater than 0", http.StatusBadRequest)
+			return
+		}
+		sets = append(sets, "amount = ?")
+		args = append(args, *req.Amount)
+	}
+	// Unlike payee_id and account_id, subcategory_id and user_id cannot be
+	// cleared: 0 would be written as an ID.
 	if req.SubcategoryID != nil {
-		subcategoryID.Int64 = int64(*req.SubcategoryID)
-		subcategoryID.Valid = true
+		if *req.SubcategoryID <= 0 {
+			http.Error(w, "subcategory_id must be a positive number", http.StatusBadRequest)
+			return
+		}
+		sets = append(sets, "subcategory_id = ?")
+		args = append(args, *req.SubcategoryID)
 	}
-
-	var userID sql.NullInt64
 	if req.UserID != nil {
-		userID.Int64 = int64(*req.UserID)
-		userID.Valid = true
+		if *req.UserID <= 0 {
+			http.Error(w, "user_id must be a positive number", http.StatusBadRequest)
+			return
+		}
+		sets = append(sets, "user_id = ?")
+		args = append(args, *req.UserID)
 	}
-
-	var note sql.NullString
 	if req.Note != nil {
-		note.String = *req.Note
-		note.Valid = true
+		sets = append(sets, "note = ?")
+		args = append(args, *req.Note)
+	}
+	if req.Date != nil {
+		date, err := time.Parse("2006-01-02", *req.Date)
+		if err != nil {
+			http.Error(w, "date must be in YYYY-MM-DD format", http.StatusBadRequest)
+			return
+		}
+		sets = append(sets, "created_at = ?")
+		args = append(args, date)
+	}
+	if req.PayeeID != nil {
+		// payee_id: 0 detaches the expense from its payee.
+		var payeeID sql.NullInt64
+		if *req.PayeeID != 0 {
+			payeeID = sql.NullInt64{Int64: int64(*req.PayeeID), Valid: true}
+		}
+		sets = append(sets, "payee_id = ?")
+		args = append(args, payeeID)
+	}
+	if req.AccountID != nil {
+		// account_id: 0 detaches the expense from its account.
+		var accountID sql.NullInt64
+		if *req.AccountID != 0 {
+			accountID = sql.NullInt64{Int64: int64(*req.AccountID), Valid: true}
+		}
+		sets = append(sets, "account_id = ?")
+		args = append(args, accountID)
+	}
+	if req.Reimbursable != nil {
+		sets = append(sets, "reimbursable = ?")
+		args = append(args, *req.Reimbursable)
+	}
+
+	var tags []string
+	if req.Tags != nil {
+		if tags, err = normalizeTags(*req.Tags); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
 	}
 
-	result, err := db.Exec(`
-		INSERT INTO expenses (amount, subcategory_id, user_id, note, created_at)
-		VALUES (?, ?, ?, ?, NOW())
-	`, req.Amount, subcategoryID, userID, note)
+	if len(sets) == 0 && req.Tags == nil {
+		http.Error(w, "Nothing to update", http.StatusBadRequest)
+		return
+	}
 
+	exists, err := inHousehold(householdID(r), "expenses", expenseID)
 	if err != nil {
-		logger.Error(fmt.Sprintf("Failed to create expense: %v", err))
+		logger.Error(err.Error())
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
+	if !exists {
+		http.Error(w, "Expense not found", http.StatusNotFound)
+		return
+	}
 
-	expenseID, err := result.LastInsertId()
+	err = checkHouseholdRefs(householdID(r),
+		householdRef{"subcategory_id", "subcategories", req.SubcategoryID},
+		householdRef{"user_id", "users", req.UserID},
+		householdRef{"payee_id", "payees", req.PayeeID},
+		householdRef{"account_id", "accounts", req.AccountID},
+	)
+	if writeRefError(w, err) {
+		return
+	}
+
+	tx, err := db.Begin()
 	if err != nil {
-		logger.Error(fmt.Sprintf("Failed to get last insert ID: %v", err))
+		logger.Error(fmt.Sprintf("Failed to begin transaction: %v", err))
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
+	defer tx.Rollback()
 
-	response := map[string]interface{}{
-		"id":      expenseID,
-		"amount":  req.Amount,
-		"message": "Expense created successfully",
+	if !checkExpenseUnlocked(w, tx, expenseID) {
+		return
+	}
+
+	if len(sets) > 0 {
+		_, err := tx.Exec("UPDATE expenses SET "+strings.Join(sets, ", ")+" WHERE id = ?", append(args, expenseID)...)
+		if err != nil {
+			logger.Error(fmt.Sprintf("Failed to update expense: %v", err))
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		if err := detachFromClaim(tx, expenseID); err != nil {
+			logger.Error(err.Error())
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if req.Tags != nil {
+		if err := setExpenseTags(tx, householdID(r), int64(expenseID), tags); err != nil {
+			logger.Error(err.Error())
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if req.Amount != nil {
+		if err := rescaleExpenseSplit(tx, expenseID, *req.Amount); err != nil {
+			logger.Error(err.Error())
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		logger.Error(fmt.Sprintf("Failed to commit expense update: %v", err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
 	}
 
+	invalidateExpenseCaches()
+
 	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(response)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "Expense updated successfully",
+		"id":      expenseID,
+	})
 }
 
 type SubcategoryExpenseCount struct {
@@ -1126,11 +1503,12 @@ func debugSubcategoriesByExpenseCountHandler(w http.ResponseWriter, r *http.Requ
 			c.name as category_name,
 			COUNT(e.id) as expense_count
 		FROM subcategories s
-		LEFT JOIN categories c ON s.category_id = c.id
+		JOIN categories c ON s.category_id = c.id
 		LEFT JOIN expenses e ON s.id = e.subcategory_id
+		WHERE c.household_id = ?
 		GROUP BY s.id, s.name, c.id, c.name
 		ORDER BY expense_count DESC
-	`)
+	`, householdID(r))
 	if err != nil {
 		logger.Error(fmt.Sprintf("Failed to query subcategories by expense count: %v", err))
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
@@ -1188,7 +1566,7 @@ func deleteExpenseHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var existingID int
-	err = db.QueryRow("SELECT id FROM expenses WHERE id = ?", expenseID).Scan(&existingID)
+	err = db.QueryRow("SELECT id FROM expenses WHERE id = ? AND household_id = ?", expenseID, householdID(r)).Scan(&existingID)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			http.Error(w, "Expense not found", http.StatusNotFound)
@@ -1199,7 +1577,26 @@ func deleteExpenseHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	result, err := db.Exec("DELETE FROM expenses WHERE id = ?", expenseID)
+	tx, err := db.Begin()
+	if err != nil {
+		logger.Error(fmt.Sprintf("Failed to begin transaction: %v", err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	if !checkExpenseUnlocked(w, tx, expenseID) {
+		return
+	}
+
+	attachmentKeys, err := queryAttachmentKeys(expenseID)
+	if err != nil {
+		logger.Error(err.Error())
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	result, err := tx.Exec("DELETE FROM expenses WHERE id = ?", expenseID)
 	if err != nil {
 		logger.Error(fmt.Sprintf("Failed to delete expense: %v", err))
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
@@ -1218,6 +1615,20 @@ func deleteExpenseHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err := tx.Commit(); err != nil {
+		logger.Error(fmt.Sprintf("Failed to commit expense deletion: %v", err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	for _, key := range attachmentKeys {
+		if err := blobStorage.Delete(r.Context(), key); err != nil {
+			logger.Error(fmt.Sprintf("Failed to remove attachment %s from storage: %v", key, err))
+		}
+	}
+
+	invalidateExpenseCaches()
+
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(map[string]interface{}{
@@ -1245,7 +1656,7 @@ func deleteCategoryHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var existingID int
-	err = db.QueryRow("SELECT id FROM categories WHERE id = ?", categoryID).Scan(&existingID)
+	err = db.QueryRow("SELECT id FROM categories WHERE id = ? AND household_id = ?", categoryID, householdID(r)).Scan(&existingID)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			http.Error(w, "Category not found", http.StatusNotFound)
@@ -1269,6 +1680,19 @@ func deleteCategoryHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	var childCount int
+	err = db.QueryRow("SELECT COUNT(*) FROM categories WHERE parent_id = ?", categoryID).Scan(&childCount)
+	if err != nil {
+		logger.Error(fmt.Sprintf("Failed to check child categories count: %v", err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	if childCount > 0 {
+		http.Error(w, "Cannot delete category: it has child categories", http.StatusConflict)
+		return
+	}
+
 	result, err := db.Exec("DELETE FROM categories WHERE id = ?", categoryID)
 	if err != nil {
 		logger.Error(fmt.Sprintf("Failed to delete category: %v", err))
@@ -1314,17 +1738,16 @@ func deleteSubcategoryHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var existingID int
-	err = db.QueryRow("SELECT id FROM subcategories WHERE id = ?", subcategoryID).Scan(&existingID)
+	exists, err := inHousehold(householdID(r), "subcategories", subcategoryID)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			http.Error(w, "Subcategory not found", http.StatusNotFound)
-			return
-		}
-		logger.Error(fmt.Sprintf("Failed to check subcategory existence: %v", err))
+		logger.Error(err.Error())
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
+	if !exists {
+		http.Error(w, "Subcategory not found", http.StatusNotFound)
+		return
+	}
 
 	var expenseCount int
 	err = db.QueryRow("SELECT COUNT(*) FROM expenses WHERE subcategory_id = ?", subcategoryID).Scan(&expenseCount)
@@ -1434,12 +1857,29 @@ func getSubcategoriesHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Subcategories of an archived category or of any category below one are
+	// hidden along with the archived ones.
+	hidden := map[int]bool{}
+	archivedCondition := " AND s.archived = 0"
+	if r.URL.Query().Get("include_archived") == "true" {
+		archivedCondition = ""
+	} else {
+		roots, _, err := loadCategoryTree(householdID(r))
+		if err != nil {
+			logger.Error(err.Error())
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		hidden = archivedSubtrees(roots)
+	}
+
 	rows, err := db.Query(`
-		SELECT s.id, s.name, s.category_id, c.name as category_name
+		SELECT s.id, s.name, s.category_id, c.name as category_name, s.color, s.icon, s.sort_order, s.archived
 		FROM subcategories s
 		JOIN categories c ON s.category_id = c.id
-		ORDER BY c.name, s.name
-	`)
+		WHERE c.household_id = ?`+archivedCondition+`
+		ORDER BY c.sort_order, c.name, s.sort_order, s.name
+	`, householdID(r))
 	if err != nil {
 		logger.Error(fmt.Sprintf("Failed to query subcategories: %v", err))
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
@@ -1454,18 +1894,29 @@ func getSubcategoriesHandler(w http.ResponseWriter, r *http.Request) {
 		var name string
 		var categoryID int
 		var categoryName string
+		var color *string
+		var icon *string
+		var sortOrder int
+		var archived bool
 
-		if err := rows.Scan(&id, &name, &categoryID, &categoryName); err != nil {
+		if err := rows.Scan(&id, &name, &categoryID, &categoryName, &color, &icon, &sortOrder, &archived); err != nil {
 			logger.Error(fmt.Sprintf("Failed to scan subcategory row: %v", err))
 			http.Error(w, "Internal server error", http.StatusInternalServerError)
 			return
 		}
+		if hidden[categoryID] {
+			continue
+		}
 
 		subcategory := map[string]interface{}{
 			"id":            id,
 			"name":          name,
 			"category_id":   categoryID,
 			"category_name": categoryName,
+			"color":         color,
+			"icon":          icon,
+			"sort_order":    sortOrder,
+			"archived":      archived,
 		}
 		subcategories = append(subcategories, subcategory)
 	}
@@ -1520,6 +1971,53 @@ func getGroupedExpensesBySubcategoryHandler(w http.ResponseWriter, r *http.Reque
 	dateFrom := startOfMonth.Format("2006-01-02")
 	dateTo := endOfMonth.Format("2006-01-02")
 
+	var userID *int
+	if userIDStr != "" {
+		value, err := strconv.Atoi(userIDStr)
+		if err != nil {
+			http.Error(w, "Invalid user_id parameter", http.StatusBadRequest)
+			return
+		}
+		userID = &value
+	}
+
+	totals, err := querySubcategoryTotals(householdID(r), dateFrom, dateTo, userID)
+	if err != nil {
+		logger.Error(err.Error())
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	var expenses []map[string]interface{}
+	var totalAmount float64
+
+	for _, total := range totals {
+		expense := map[string]interface{}{
+			"subcategory_name": total.SubcategoryName,
+			"category_name":    total.CategoryName,
+			"total":            total.Total,
+		}
+
+		expenses = append(expenses, expense)
+		totalAmount += total.Total
+	}
+
+	response := map[string]interface{}{
+		"expenses": expenses,
+		"total":    fmt.Sprintf("%.2f", totalAmount),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+type SubcategoryTotal struct {
+	SubcategoryName string  `json:"subcategory_name"`
+	CategoryName    string  `json:"category_name"`
+	Total           float64 `json:"total"`
+}
+
+func querySubcategoryTotals(householdID int, dateFrom, dateTo string, userID *int) ([]SubcategoryTotal, error) {
 	query := `
 		SELECT 
 			s.name as subcategory_name,
@@ -1528,24 +2026,18 @@ func getGroupedExpensesBySubcategoryHandler(w http.ResponseWriter, r *http.Reque
 		FROM expenses e
 		JOIN subcategories s ON e.subcategory_id = s.id
 		JOIN categories c ON s.category_id = c.id
-		WHERE DATE(e.created_at) >= ? AND DATE(e.created_at) <= ?
+		WHERE e.household_id = ? AND DATE(e.created_at) >= ? AND DATE(e.created_at) <= ?
 	`
 
 	var args []interface{}
-	args = append(args, dateFrom, dateTo)
-
-	if userIDStr != "" {
-		userID, err := strconv.Atoi(userIDStr)
-		if err != nil {
-			http.Error(w, "Invalid user_id parameter", http.StatusBadRequest)
-			return
-		}
+	args = append(args, householdID, dateFrom, dateTo)
 
-		if userID == 0 {
+	if userID != nil {
+		if *userID == 0 {
 			query += " AND e.user_id IS NULL"
 		} else {
 			query += " AND e.user_id = ?"
-			args = append(args, userID)
+			args = append(args, *userID)
 		}
 	}
 
@@ -1553,49 +2045,25 @@ func getGroupedExpensesBySubcategoryHandler(w http.ResponseWriter, r *http.Reque
 
 	rows, err := db.Query(query, args...)
 	if err != nil {
-		logger.Error(fmt.Sprintf("Failed to query grouped expenses by subcategory: %v", err))
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
-		return
+		return nil, fmt.Errorf("failed to query grouped expenses by subcategory: %v", err)
 	}
 	defer rows.Close()
 
-	var expenses []map[string]interface{}
-	var totalAmount float64
+	var totals []SubcategoryTotal
 
 	for rows.Next() {
-		var subcategoryName string
-		var categoryName string
-		var amount float64
-
-		if err := rows.Scan(&subcategoryName, &categoryName, &amount); err != nil {
-			logger.Error(fmt.Sprintf("Failed to scan grouped expense row: %v", err))
-			http.Error(w, "Internal server error", http.StatusInternalServerError)
-			return
-		}
-
-		expense := map[string]interface{}{
-			"subcategory_name": subcategoryName,
-			"category_name":    categoryName,
-			"total":            amount,
+		var total SubcategoryTotal
+		if err := rows.Scan(&total.SubcategoryName, &total.CategoryName, &total.Total); err != nil {
+			return nil, fmt.Errorf("failed to scan grouped expense row: %v", err)
 		}
-
-		expenses = append(expenses, expense)
-		totalAmount += amount
+		totals = append(totals, total)
 	}
 
 	if err = rows.Err(); err != nil {
-		logger.Error(fmt.Sprintf("Error iterating over grouped rows: %v", err))
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
-		return
-	}
-
-	response := map[string]interface{}{
-		"expenses": expenses,
-		"total":    fmt.Sprintf("%.2f", totalAmount),
+		return nil, fmt.Errorf("error iterating over grouped rows: %v", err)
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
+	return totals, nil
 }
 
 func getMemberUsersHandler(w http.ResponseWriter, r *http.Request) {
@@ -1607,9 +2075,9 @@ func getMemberUsersHandler(w http.ResponseWriter, r *http.Request) {
 	rows, err := db.Query(`
 		SELECT id, email, display_name, created_at
 		FROM users 
-		WHERE role = 'MEMBER'
+		WHERE role = 'MEMBER' AND household_id = ?
 		ORDER BY display_name, email
-	`)
+	`, householdID(r))
 	if err != nil {
 		logger.Error(fmt.Sprintf("Failed to query member users: %v", err))
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
@@ -0,0 +1,839 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const invitationTTL = 7 * 24 * time.Hour
+
+type contextKey string
+
+const authUserKey contextKey = "auth_user"
+
+// authUser is the caller of an authenticated request. requireAuth stores it
+// in the request context; handlers scope every query to its household.
+type authUser struct {
+	ID          int
+	Email       string
+	Role        string
+	HouseholdID int
+}
+
+func withAuthUser(r *http.Request, user authUser) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), authUserKey, user))
+}
+
+func currentUser(r *http.Request) authUser {
+	user, _ := r.Context().Value(authUserKey).(authUser)
+	return user
+}
+
+func householdID(r *http.Request) int {
+	return currentUser(r).HouseholdID
+}
+
+type Household struct {
+	ID        int               `json:"id"`
+	Name      string            `json:"name"`
+	CreatedAt string            `json:"created_at"`
+	Members   []HouseholdMember `json:"members"`
+}
+
+type HouseholdMember struct {
+	ID          int     `json:"id"`
+	Email       string  `json:"email"`
+	DisplayName *string `json:"display_name"`
+	Role        string  `json:"role"`
+	CreatedAt   string  `json:"created_at"`
+}
+
+type Invitation struct {
+	ID          int     `json:"id"`
+	HouseholdID int     `json:"household_id"`
+	Email       string  `json:"email"`
+	InvitedBy   *int    `json:"invited_by"`
+	ExpiresAt   string  `json:"expires_at"`
+	AcceptedAt  *string `json:"accepted_at"`
+	CreatedAt   string  `json:"created_at"`
+	Token       string  `json:"token,omitempty"`
+}
+
+type AcceptInvitationRequest struct {
+	Token       string  `json:"token"`
+	Password    string  `json:"password"`
+	DisplayName *string `json:"display_name"`
+}
+
+// householdOwner locates the household of a row: the table, joined to its
+// owner when it has no household_id of its own, the row's ID column and the
+// household column.
+type householdOwner struct {
+	from      string
+	id        string
+	household string
+}
+
+// householdOwners finds the household of a row in each household-owned
+// table. Subcategories belong to the household of their category.
+var householdOwners = map[string]householdOwner{
+	"users":                {"users", "id", "household_id"},
+	"categories":           {"categories", "id", "household_id"},
+	"subcategories":        {"subcategories s JOIN categories c ON c.id = s.category_id", "s.id", "c.household_id"},
+	"expenses":             {"expenses", "id", "household_id"},
+	"accounts":             {"accounts", "id", "household_id"},
+	"payees":               {"payees", "id", "household_id"},
+	"tags":                 {"tags", "id", "household_id"},
+	"income_categories":    {"income_categories", "id", "household_id"},
+	"incomes":              {"incomes", "id", "household_id"},
+	"categorization_rules": {"categorization_rules", "id", "household_id"},
+	"settlements":          {"settlements", "id", "household_id"},
+	"reimbursement_claims": {"reimbursement_claims", "id", "household_id"},
+	"receipt_jobs":         {"receipt_jobs", "id", "household_id"},
+	"savings_goals":        {"savings_goals", "id", "household_id"},
+}
+
+// inHousehold reports whether the row exists and belongs to the household.
+// Rows of other households look exactly like missing ones.
+func inHousehold(householdID int, table string, id int) (bool, error) {
+	owner, ok := householdOwners[table]
+	if !ok {
+		return false, fmt.Errorf("no household owner for table %s", table)
+	}
+
+	var household int
+	err := db.QueryRow(fmt.Sprintf("SELECT %s FROM %s WHERE %s = ?", owner.household, owner.from, owner.id), id).Scan(&household)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to check household of %s %d: %v", table, id, err)
+	}
+
+	return household == householdID, nil
+}
+
+type householdRef struct {
+	Field string
+	Table string
+	ID    *int
+}
+
+// unknownRefError is a request referencing a row outside the household.
+type unknownRefError struct {
+	field string
+	id    int
+}
+
+func (e unknownRefError) Error() string {
+	return fmt.Sprintf("Unknown %s: %d", e.field, e.id)
+}
+
+// checkHouseholdRefs fails with unknownRefError for the first referenced row
+// that is not in the household. Missing and zero IDs are skipped, since they
+// leave the reference empty.
+func checkHouseholdRefs(householdID int, refs ...householdRef) error {
+	for _, ref := range refs {
+		if ref.ID == nil || *ref.ID == 0 {
+			continue
+		}
+		ok, err := inHousehold(householdID, ref.Table, *ref.ID)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return unknownRefError{field: ref.Field, id: *ref.ID}
+		}
+	}
+	return nil
+}
+
+// checkHouseholdRefsBatch is checkHouseholdRefs for many references at
+// once: the distinct IDs of each table are looked up in one query. On an
+// unknownRefError it also returns the index of the first failing reference.
+func checkHouseholdRefsBatch(householdID int, refs []householdRef) (int, error) {
+	found := make(map[string]map[int]bool)
+	var tables []string
+	for _, ref := range refs {
+		if ref.ID == nil || *ref.ID == 0 {
+			continue
+		}
+		if found[ref.Table] == nil {
+			found[ref.Table] = make(map[int]bool)
+			tables = append(tables, ref.Table)
+		}
+		found[ref.Table][*ref.ID] = false
+	}
+
+	for _, table := range tables {
+		owner, ok := householdOwners[table]
+		if !ok {
+			return 0, fmt.Errorf("no household owner for table %s", table)
+		}
+
+		args := []interface{}{householdID}
+		for id := range found[table] {
+			args = append(args, id)
+		}
+		rows, err := db.Query(fmt.Sprintf(
+			"SELECT %s FROM %s WHERE %s = ? AND %s IN (%s)",
+			owner.id, owner.from, owner.household, owner.id, placeholders(len(args)-1),
+		), args...)
+		if err != nil {
+			return 0, fmt.Errorf("failed to check household of %s: %v", table, err)
+		}
+		for rows.Next() {
+			var id int
+			if err := rows.Scan(&id); err != nil {
+				rows.Close()
+				return 0, fmt.Errorf("failed to scan %s id: %v", table, err)
+			}
+			found[table][id] = true
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return 0, fmt.Errorf("error iterating %s ids: %v", table, err)
+		}
+	}
+
+	for i, ref := range refs {
+		if ref.ID != nil && *ref.ID != 0 && !found[ref.Table][*ref.ID] {
+			return i, unknownRefError{field: ref.Field, id: *ref.ID}
+		}
+	}
+	return 0, nil
+}
+
+// writeRefError answers for a failed checkHouseholdRefs and reports whether
+// there was anything to answer.
+func writeRefError(w http.ResponseWriter, err error) bool {
+	if err == nil {
+		return false
+	}
+	if _, ok := err.(unknownRefError); ok {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return true
+	}
+	logger.Error(err.Error())
+	http.Error(w, "Internal server error", http.StatusInternalServerError)
+	return true
+}
+
+// lookupMembership returns the user's household and their role in it. The
+// role belongs to the membership, not the login: it is reset when the user
+// moves to another household.
+func lookupMembership(userID int) (int, string, error) {
+	var id int
+	var role string
+	err := db.QueryRow("SELECT household_id, role FROM users WHERE id = ?", userID).Scan(&id, &role)
+	if err != nil {
+		return 0, "", err
+	}
+	return id, role, nil
+}
+
+// userDataTables lists where a user's own entries live, as table and user
+// column. A user with any of them cannot move to another household, since
+// the entries would stay behind pointing at someone outside the household.
+var userDataTables = [][2]string{
+	{"expenses", "user_id"},
+	{"expense_splits", "user_id"},
+	{"incomes", "user_id"},
+	{"settlements", "from_user_id"},
+	{"settlements", "to_user_id"},
+	{"reimbursement_claims", "user_id"},
+	{"categorization_rules", "user_id"},
+	{"receipt_jobs", "user_id"},
+	{"savings_goals", "user_id"},
+	{"goal_contributions", "user_id"},
+}
+
+func userHasData(q sqlQueryer, userID int) (bool, error) {
+	for _, table := range userDataTables {
+		var found int
+		err := q.QueryRow("SELECT 1 FROM "+table[0]+" WHERE "+table[1]+" = ? LIMIT 1", userID).Scan(&found)
+		if err == nil {
+			return true, nil
+		}
+		if err != sql.ErrNoRows {
+			return false, fmt.Errorf("failed to check %s for user %d: %v", table[0], userID, err)
+		}
+	}
+	return false, nil
+}
+
+func hashInvitationToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func createInvitation(exec sqlExecer, householdID int, email string, invitedBy *int) (Invitation, error) {
+	invitation := Invitation{HouseholdID: householdID, Email: email, InvitedBy: invitedBy}
+
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return invitation, fmt.Errorf("failed to generate invitation token: %v", err)
+	}
+	invitation.Token = hex.EncodeToString(raw)
+
+	expiresAt := time.Now().UTC().Add(invitationTTL)
+	result, err := exec.Exec(`
+		INSERT INTO household_invitations (household_id, email, token_hash, invited_by, expires_at)
+		VALUES (?, ?, ?, ?, ?)
+	`, householdID, email, hashInvitationToken(invitation.Token), invitedBy, expiresAt)
+	if err != nil {
+		return invitation, fmt.Errorf("failed to create invitation: %v", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return invitation, fmt.Errorf("failed to get last insert ID: %v", err)
+	}
+	invitation.ID = int(id)
+	invitation.ExpiresAt = expiresAt.Format(time.RFC3339)
+	invitation.CreatedAt = time.Now().UTC().Format(time.RFC3339)
+
+	return invitation, nil
+}
+
+func normalizeInvitationEmail(email string) (string, error) {
+	email = strings.ToLower(strings.TrimSpace(email))
+	at := strings.Index(email, "@")
+	if at < 1 || at == len(email)-1 || strings.ContainsAny(email, " \t") || len(email) > 255 {
+		return "", fmt.Errorf("A valid email is required")
+	}
+	return email, nil
+}
+
+func getHousehold(id int) (*Household, error) {
+	household := Household{ID: id, Members: []HouseholdMember{}}
+	err := db.QueryRow("SELECT name, created_at FROM households WHERE id = ?", id).Scan(&household.Name, &household.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query household: %v", err)
+	}
+
+	rows, err := db.Query(`
+		SELECT id, email, display_name, role, created_at
+		FROM users
+		WHERE household_id = ?
+		ORDER BY display_name, email
+	`, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query household members: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var member HouseholdMember
+		if err := rows.Scan(&member.ID, &member.Email, &member.DisplayName, &member.Role, &member.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan household member: %v", err)
+		}
+		household.Members = append(household.Members, member)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over household members: %v", err)
+	}
+
+	return &household, nil
+}
+
+func householdHandler(w http.ResponseWriter, r *http.Request) {
+	id := householdID(r)
+
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut, http.MethodPatch:
+		if currentUser(r).Role != "ADMIN" {
+			http.Error(w, "Only admins can rename the household", http.StatusForbidden)
+			return
+		}
+
+		var requestBody struct {
+			Name string `json:"name"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
+			http.Error(w, "Invalid JSON body", http.StatusBadRequest)
+			return
+		}
+
+		name := strings.TrimSpace(requestBody.Name)
+		if name == "" || len(name) > 255 {
+			http.Error(w, "Name must be 1-255 characters", http.StatusBadRequest)
+			return
+		}
+
+		if _, err := db.Exec("UPDATE households SET name = ? WHERE id = ?", name, id); err != nil {
+			logger.Error(fmt.Sprintf("Failed to rename household: %v", err))
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	household, err := getHousehold(id)
+	if err != nil {
+		logger.Error(err.Error())
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if household == nil {
+		http.Error(w, "Household not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(household)
+}
+
+// canCreateHouseholds reports whether the email is one of the operators in
+// HOUSEHOLD_CREATORS, a comma-separated list of emails. Roles belong to a
+// household, so being its ADMIN is not enough to open new ones; without the
+// setting nobody can.
+func canCreateHouseholds(email string) bool {
+	for _, creator := range strings.Split(os.Getenv("HOUSEHOLD_CREATORS"), ",") {
+		creator = strings.TrimSpace(creator)
+		if creator != "" && strings.EqualFold(creator, email) {
+			return true
+		}
+	}
+	return false
+}
+
+// createHouseholdHandler lets an operator open a new household for another
+// family. The household starts empty, or with the SEED_CATEGORY_TEMPLATE
+// categories when one is configured; its first member joins through the
+// returned invitation.
+func createHouseholdHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	caller := currentUser(r)
+	if !canCreateHouseholds(caller.Email) {
+		http.Error(w, "You are not allowed to create households", http.StatusForbidden)
+		return
+	}
+
+	var requestBody struct {
+		Name       string `json:"name"`
+		OwnerEmail string `json:"owner_email"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
+		http.Error(w, "Invalid JSON body", http.StatusBadRequest)
+		return
+	}
+
+	name := strings.TrimSpace(requestBody.Name)
+	if name == "" || len(name) > 255 {
+		http.Error(w, "Name must be 1-255 characters", http.StatusBadRequest)
+		return
+	}
+
+	email, err := normalizeInvitationEmail(requestBody.OwnerEmail)
+	if err != nil {
+		http.Error(w, "owner_email: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		logger.Error(fmt.Sprintf("Failed to begin transaction: %v", err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec("INSERT INTO households (name) VALUES (?)", name)
+	if err != nil {
+		logger.Error(fmt.Sprintf("Failed to create household: %v", err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	newID, err := result.LastInsertId()
+	if err != nil {
+		logger.Error(fmt.Sprintf("Failed to get last insert ID: %v", err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	if template, err := defaultCategoryTemplate(); err != nil {
+		logger.Error(err.Error())
+	} else if template != nil {
+		if _, err := applyCategoryTemplate(tx, int(newID), *template, false); err != nil {
+			logger.Error(err.Error())
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	invitation, err := createInvitation(tx, int(newID), email, &caller.ID)
+	if err != nil {
+		logger.Error(err.Error())
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		logger.Error(fmt.Sprintf("Failed to commit household: %v", err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"id":         newID,
+		"name":       name,
+		"invitation": invitation,
+		"message":    "Household created successfully",
+	})
+}
+
+func getInvitationsHandler(w http.ResponseWriter, r *http.Request) {
+	rows, err := db.Query(`
+		SELECT id, household_id, email, invited_by, expires_at, accepted_at, created_at
+		FROM household_invitations
+		WHERE household_id = ? AND accepted_at IS NULL AND expires_at > UTC_TIMESTAMP()
+		ORDER BY created_at DESC
+	`, householdID(r))
+	if err != nil {
+		logger.Error(fmt.Sprintf("Failed to query invitations: %v", err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	invitations := []Invitation{}
+	for rows.Next() {
+		var invitation Invitation
+		var invitedBy sql.NullInt64
+		var acceptedAt sql.NullString
+		if err := rows.Scan(&invitation.ID, &invitation.HouseholdID, &invitation.Email, &invitedBy, &invitation.ExpiresAt, &acceptedAt, &invitation.CreatedAt); err != nil {
+			logger.Error(fmt.Sprintf("Failed to scan invitation: %v", err))
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		invitation.InvitedBy = nullableInt(invitedBy)
+		if acceptedAt.Valid {
+			invitation.AcceptedAt = &acceptedAt.String
+		}
+		invitations = append(invitations, invitation)
+	}
+
+	if err := rows.Err(); err != nil {
+		logger.Error(fmt.Sprintf("Error iterating invitations: %v", err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(invitations)
+}
+
+func createInvitationHandler(w http.ResponseWriter, r *http.Request) {
+	if currentUser(r).Role != "ADMIN" {
+		http.Error(w, "Only admins can invite members", http.StatusForbidden)
+		return
+	}
+
+	var requestBody struct {
+		Email string `json:"email"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
+		http.Error(w, "Invalid JSON body", http.StatusBadRequest)
+		return
+	}
+
+	email, err := normalizeInvitationEmail(requestBody.Email)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	caller := currentUser(r)
+
+	var memberID int
+	err = db.QueryRow("SELECT id FROM users WHERE email = ? AND household_id = ?", email, caller.HouseholdID).Scan(&memberID)
+	if err == nil {
+		http.Error(w, "User is already a member of this household", http.StatusConflict)
+		return
+	} else if err != sql.ErrNoRows {
+		logger.Error(fmt.Sprintf("Failed to check household membership: %v", err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	invitation, err := createInvitation(db, caller.HouseholdID, email, &caller.ID)
+	if err != nil {
+		logger.Error(err.Error())
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	// The token is only ever returned here; the database keeps its hash.
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(invitation)
+}
+
+func deleteInvitationHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if currentUser(r).Role != "ADMIN" {
+		http.Error(w, "Only admins can revoke invitations", http.StatusForbidden)
+		return
+	}
+
+	invitationID, err := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/api/v1/household/invitations/"))
+	if err != nil {
+		http.Error(w, "Invalid invitation ID", http.StatusBadRequest)
+		return
+	}
+
+	result, err := db.Exec("DELETE FROM household_invitations WHERE id = ? AND household_id = ? AND accepted_at IS NULL", invitationID, householdID(r))
+	if err != nil {
+		logger.Error(fmt.Sprintf("Failed to delete invitation: %v", err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		logger.Error(fmt.Sprintf("Failed to get rows affected: %v", err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	if rowsAffected == 0 {
+		http.Error(w, "Invitation not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "Invitation revoked successfully",
+		"id":      invitationID,
+	})
+}
+
+func invitationsHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		getInvitationsHandler(w, r)
+	case http.MethodPost:
+		createInvitationHandler(w, r)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// acceptInvitationHandler is public like login: the token stands in for the
+// session. Someone without an account gets one with the given password;
+// an existing user proves it is them with their current password and moves
+// to the new household, which is refused while they have entries in their
+// current one or are the only admin of members they would leave behind. The first member of a household becomes its ADMIN; everyone
+// else joins as a MEMBER, whatever role they had before.
+func acceptInvitationHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req AcceptInvitationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if req.Token == "" || req.Password == "" {
+		http.Error(w, "Token and password are required", http.StatusBadRequest)
+		return
+	}
+
+	var invitationID, invitationHouseholdID int
+	var email string
+	err := db.QueryRow(`
+		SELECT id, household_id, email
+		FROM household_invitations
+		WHERE token_hash = ? AND accepted_at IS NULL AND expires_at > UTC_TIMESTAMP()
+	`, hashInvitationToken(req.Token)).Scan(&invitationID, &invitationHouseholdID, &email)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Invitation is invalid or has expired", http.StatusNotFound)
+		return
+	} else if err != nil {
+		logger.Error(fmt.Sprintf("Failed to query invitation: %v", err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	user, err := getUserByEmail(email)
+	if err != nil {
+		logger.Error(fmt.Sprintf("Failed to get user by email: %v", err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	if user != nil {
+		if user.Password == nil || verifyPassword(req.Password, *user.Password) != nil {
+			http.Error(w, "Invalid email or password", http.StatusUnauthorized)
+			return
+		}
+	} else if len(req.Password) < 8 {
+		http.Error(w, "Password must be at least 8 characters long", http.StatusBadRequest)
+		return
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		logger.Error(fmt.Sprintf("Failed to begin transaction: %v", err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	// Claiming the invitation first makes a second accept with the same
+	// token fail instead of racing this one.
+	result, err := tx.Exec("UPDATE household_invitations SET accepted_at = UTC_TIMESTAMP() WHERE id = ? AND accepted_at IS NULL", invitationID)
+	if err != nil {
+		logger.Error(fmt.Sprintf("Failed to accept invitation: %v", err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if claimed, _ := result.RowsAffected(); claimed == 0 {
+		http.Error(w, "Invitation is invalid or has expired", http.StatusNotFound)
+		return
+	}
+
+	moving := user != nil && user.HouseholdID != invitationHouseholdID
+
+	// Locking the household rows, in ID order, keeps two first members from
+	// both becoming ADMIN and two admins from both leaving.
+	locked := []int{invitationHouseholdID}
+	if moving {
+		locked = append(locked, user.HouseholdID)
+		sort.Ints(locked)
+	}
+	for _, id := range locked {
+		var lockedID int
+		err := tx.QueryRow("SELECT id FROM households WHERE id = ? FOR UPDATE", id).Scan(&lockedID)
+		if err == sql.ErrNoRows && id == invitationHouseholdID {
+			http.Error(w, "Invitation is invalid or has expired", http.StatusNotFound)
+			return
+		} else if err != nil && err != sql.ErrNoRows {
+			logger.Error(fmt.Sprintf("Failed to lock household: %v", err))
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if moving {
+		hasData, err := userHasData(tx, user.ID)
+		if err != nil {
+			logger.Error(err.Error())
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		if hasData {
+			http.Error(w, "You still have entries in your current household. Delete them or ask its admin to remove them before joining another household", http.StatusConflict)
+			return
+		}
+
+		// The role is read again under the lock: the one loaded with the user
+		// may be stale.
+		var isAdmin, others, otherAdmins int
+		err = tx.QueryRow(`
+			SELECT COALESCE(SUM(id = ? AND role = 'ADMIN'), 0), COALESCE(SUM(id != ?), 0), COALESCE(SUM(id != ? AND role = 'ADMIN'), 0)
+			FROM users
+			WHERE household_id = ?
+		`, user.ID, user.ID, user.ID, user.HouseholdID).Scan(&isAdmin, &others, &otherAdmins)
+		if err != nil {
+			logger.Error(fmt.Sprintf("Failed to count household admins: %v", err))
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		if isAdmin > 0 && others > 0 && otherAdmins == 0 {
+			http.Error(w, "You are the only admin of your current household and it has other members, so you cannot leave it", http.StatusConflict)
+			return
+		}
+	}
+
+	role := "MEMBER"
+	var members int
+	if err := tx.QueryRow("SELECT COUNT(*) FROM users WHERE household_id = ?", invitationHouseholdID).Scan(&members); err != nil {
+		logger.Error(fmt.Sprintf("Failed to count household members: %v", err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if members == 0 {
+		role = "ADMIN"
+	}
+
+	if moving {
+		_, err = tx.Exec("UPDATE users SET household_id = ?, role = ? WHERE id = ?", invitationHouseholdID, role, user.ID)
+	} else if user == nil {
+		var hash []byte
+		hash, err = bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+		if err == nil {
+			_, err = tx.Exec(
+				"INSERT INTO users (email, display_name, password, role, household_id) VALUES (?, ?, ?, ?, ?)",
+				email, req.DisplayName, string(hash), role, invitationHouseholdID,
+			)
+		}
+	}
+	if err != nil {
+		logger.Error(fmt.Sprintf("Failed to join household: %v", err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		logger.Error(fmt.Sprintf("Failed to commit invitation: %v", err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	user, err = getUserByEmail(email)
+	if err != nil || user == nil {
+		logger.Error(fmt.Sprintf("Failed to load user after joining household: %v", err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	token, err := generateToken(user.ID, user.Email, user.Role)
+	if err != nil {
+		logger.Error(fmt.Sprintf("Failed to generate token: %v", err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(LoginResponse{
+		User:  *user,
+		Token: token,
+	})
+}
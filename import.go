@@ -0,0 +1,758 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+const (
+	maxImportFileSize       = 5 << 20
+	maxImportCommitSize     = 10 << 20
+	maxImportCommitRows     = 5000
+	duplicateDateTolerance  = 24 * time.Hour
+	duplicateNoteSimilarity = 0.5
+)
+
+type ImportMapping struct {
+	Date             string `json:"date"`
+	Amount           string `json:"amount"`
+	Note             string `json:"note"`
+	Payee            string `json:"payee"`
+	DateFormat       string `json:"date_format"`
+	Delimiter        string `json:"delimiter"`
+	DecimalSeparator string `json:"decimal_separator"`
+	DebitsPositive   bool   `json:"debits_positive"`
+}
+
+type ImportedTransaction struct {
+	Line   int
+	Date   time.Time
+	Amount float64
+	Note   string
+}
+
+type ImportPreviewRow struct {
+	Line          int      `json:"line"`
+	Date          string   `json:"date"`
+	Amount        float64  `json:"amount"`
+	Note          string   `json:"note"`
+	SubcategoryID *int     `json:"subcategory_id"`
+	UserID        *int     `json:"user_id"`
+	PayeeID       *int     `json:"payee_id"`
+	Status        string   `json:"status"`
+	Reason        string   `json:"reason,omitempty"`
+	DuplicateOf   *int     `json:"duplicate_of,omitempty"`
+	RepeatsLine   *int     `json:"repeats_line,omitempty"`
+	Similarity    *float64 `json:"similarity,omitempty"`
+}
+
+type importRowKey struct {
+	Date  string
+	Cents int64
+	Note  string
+}
+
+type ImportCommitRow struct {
+	CreateExpenseRequest
+	Date string `json:"date"`
+}
+
+type ImportCommitRequest struct {
+	Rows []ImportCommitRow `json:"rows"`
+}
+
+func parseImportAmount(value, decimalSeparator string) (float64, error) {
+	value = strings.TrimSpace(value)
+	value = strings.Map(func(r rune) rune {
+		if unicode.IsSpace(r) || r == '\'' {
+			return -1
+		}
+		return r
+	}, value)
+
+	if decimalSeparator == "," {
+		value = strings.ReplaceAll(value, ".", "")
+		value = strings.ReplaceAll(value, ",", ".")
+	} else {
+		value = strings.ReplaceAll(value, ",", "")
+	}
+
+	negative := false
+	if strings.HasPrefix(value, "(") && strings.HasSuffix(value, ")") {
+		negative = true
+		value = strings.Trim(value, "()")
+	}
+
+	amount, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid amount %q", value)
+	}
+	if negative {
+		amount = -amount
+	}
+	return amount, nil
+}
+
+func parseCSVStatement(data []byte, mapping ImportMapping) ([]ImportedTransaction, error) {
+	if mapping.Date == "" || mapping.Amount == "" {
+		return nil, fmt.Errorf("mapping must name the date and amount columns")
+	}
+	if mapping.DateFormat == "" {
+		mapping.DateFormat = "2006-01-02"
+	}
+
+	reader := csv.NewReader(bytes.NewReader(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))))
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	if mapping.Delimiter != "" {
+		delimiter := []rune(mapping.Delimiter)
+		if len(delimiter) != 1 {
+			return nil, fmt.Errorf("delimiter must be a single character")
+		}
+		reader.Comma = delimiter[0]
+	}
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CSV header: %v", err)
+	}
+
+	columns := make(map[string]int)
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+
+	column := func(name string) (int, error) {
+		if name == "" {
+			return -1, nil
+		}
+		i, ok := columns[strings.ToLower(strings.TrimSpace(name))]
+		if !ok {
+			return -1, fmt.Errorf("column %q not found in CSV header", name)
+		}
+		return i, nil
+	}
+
+	dateColumn, err := column(mapping.Date)
+	if err != nil {
+		return nil, err
+	}
+	amountColumn, err := column(mapping.Amount)
+	if err != nil {
+		return nil, err
+	}
+	noteColumn, err := column(mapping.Note)
+	if err != nil {
+		return nil, err
+	}
+	payeeColumn, err := column(mapping.Payee)
+	if err != nil {
+		return nil, err
+	}
+
+	field := func(record []string, i int) string {
+		if i < 0 || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	var transactions []ImportedTransaction
+	line := 1
+
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		line++
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+		if field(record, dateColumn) == "" && field(record, amountColumn) == "" {
+			continue
+		}
+
+		date, err := time.Parse(mapping.DateFormat, field(record, dateColumn))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid date %q for format %q", line, field(record, dateColumn), mapping.DateFormat)
+		}
+
+		amount, err := parseImportAmount(field(record, amountColumn), mapping.DecimalSeparator)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+		if mapping.DebitsPositive {
+			amount = -amount
+		}
+
+		note := strings.TrimSpace(field(record, payeeColumn) + " " + field(record, noteColumn))
+
+		transactions = append(transactions, ImportedTransaction{Line: line, Date: date, Amount: amount, Note: note})
+	}
+
+	return transactions, nil
+}
+
+var (
+	ofxTransactionStart = regexp.MustCompile(`(?i)<STMTTRN>`)
+	ofxTransactionEnd   = regexp.MustCompile(`(?i)</STMTTRN>|</BANKTRANLIST>`)
+	ofxFieldPattern     = regexp.MustCompile(`(?i)<([A-Z0-9.]+)>([^<\r\n]*)`)
+)
+
+// parseOFXStatement accepts both SGML (OFX 1.x, unclosed tags) and XML
+// (OFX 2.x) statements by reading each STMTTRN up to the next one.
+func parseOFXStatement(data []byte) ([]ImportedTransaction, error) {
+	starts := ofxTransactionStart.FindAllIndex(data, -1)
+	if len(starts) == 0 {
+		return nil, fmt.Errorf("no STMTTRN records found in OFX file")
+	}
+
+	var transactions []ImportedTransaction
+
+	for i, start := range starts {
+		end := len(data)
+		if i+1 < len(starts) {
+			end = starts[i+1][0]
+		}
+		block := data[start[1]:end]
+		if loc := ofxTransactionEnd.FindIndex(block); loc != nil {
+			block = block[:loc[0]]
+		}
+		line := bytes.Count(data[:start[0]], []byte("\n")) + 1
+
+		fields := make(map[string]string)
+		for _, field := range ofxFieldPattern.FindAllSubmatch(block, -1) {
+			fields[strings.ToUpper(string(field[1]))] = strings.TrimSpace(string(field[2]))
+		}
+
+		posted := fields["DTPOSTED"]
+		if len(posted) < 8 {
+			return nil, fmt.Errorf("line %d: missing or invalid DTPOSTED", line)
+		}
+		date, err := time.Parse("20060102", posted[:8])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid DTPOSTED %q", line, posted)
+		}
+
+		amount, err := parseImportAmount(fields["TRNAMT"], ".")
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+
+		note := fields["NAME"]
+		if memo := fields["MEMO"]; memo != "" && memo != note {
+			note = strings.TrimSpace(note + " " + memo)
+		}
+
+		transactions = append(transactions, ImportedTransaction{Line: line, Date: date, Amount: amount, Note: note})
+	}
+
+	return transactions, nil
+}
+
+var qifDateLayouts = []string{"01/02/2006", "1/2/2006", "01/02'06", "1/2'06", "01/02/06", "1/2/06", "2006-01-02"}
+
+func parseQIFDate(value, layout string) (time.Time, error) {
+	value = strings.ReplaceAll(strings.TrimSpace(value), " ", "")
+	if layout != "" {
+		return time.Parse(layout, value)
+	}
+	for _, candidate := range qifDateLayouts {
+		if date, err := time.Parse(candidate, value); err == nil {
+			return date, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unrecognized date %q", value)
+}
+
+func parseQIFStatement(data []byte, dateFormat string) ([]ImportedTransaction, error) {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+
+	var transactions []ImportedTransaction
+	var current ImportedTransaction
+	var payee, memo string
+	var hasDate, hasAmount bool
+	line := 0
+
+	for scanner.Scan() {
+		line++
+		text := strings.TrimRight(scanner.Text(), "\r")
+		if text == "" || strings.HasPrefix(text, "!") {
+			continue
+		}
+
+		if current.Line == 0 {
+			current.Line = line
+		}
+
+		value := text[1:]
+		switch text[0] {
+		case 'D':
+			date, err := parseQIFDate(value, dateFormat)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %v", line, err)
+			}
+			current.Date = date
+			hasDate = true
+		case 'T', 'U':
+			amount, err := parseImportAmount(value, ".")
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %v", line, err)
+			}
+			current.Amount = amount
+			hasAmount = true
+		case 'P':
+			payee = strings.TrimSpace(value)
+		case 'M':
+			memo = strings.TrimSpace(value)
+		case '^':
+			if !hasDate || !hasAmount {
+				return nil, fmt.Errorf("line %d: record is missing a date or amount", line)
+			}
+			current.Note = strings.TrimSpace(payee + " " + memo)
+			transactions = append(transactions, current)
+			current = ImportedTransaction{}
+			payee, memo = "", ""
+			hasDate, hasAmount = false, false
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read QIF file: %v", err)
+	}
+
+	return transactions, nil
+}
+
+func noteTokens(note string) map[string]bool {
+	tokens := make(map[string]bool)
+	for _, token := range strings.FieldsFunc(strings.ToLower(note), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		tokens[token] = true
+	}
+	return tokens
+}
+
+// noteSimilarity is the Jaccard index of the lower-cased word sets. Two empty
+// notes are considered identical.
+func noteSimilarity(a, b string) float64 {
+	x, y := noteTokens(a), noteTokens(b)
+	if len(x) == 0 && len(y) == 0 {
+		return 1
+	}
+
+	shared := 0
+	for token := range x {
+		if y[token] {
+			shared++
+		}
+	}
+
+	return float64(shared) / float64(len(x)+len(y)-shared)
+}
+
+type existingExpense struct {
+	ID        int
+	Cents     int64
+	CreatedAt time.Time
+	Note      string
+}
+
+func loadDuplicateCandidates(householdID int, transactions []ImportedTransaction, userID *int) ([]existingExpense, error) {
+	if len(transactions) == 0 {
+		return nil, nil
+	}
+
+	from, to := transactions[0].Date, transactions[0].Date
+	for _, transaction := range transactions {
+		if transaction.Date.Before(from) {
+			from = transaction.Date
+		}
+		if transaction.Date.After(to) {
+			to = transaction.Date
+		}
+	}
+
+	filter := ExpenseFilter{
+		HouseholdID: householdID,
+		UserID:      userID,
+		DateFrom:    from.Add(-duplicateDateTolerance).Format("2006-01-02"),
+		DateTo:      to.Add(duplicateDateTolerance).Format("2006-01-02"),
+	}
+
+	expenses, err := queryExpenses(filter, "e.created_at", "ASC")
+	if err != nil {
+		return nil, err
+	}
+
+	candidates := make([]existingExpense, 0, len(expenses))
+	for _, expense := range expenses {
+		createdAt, err := time.Parse(time.RFC3339Nano, expense.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse expense date %q: %v", expense.CreatedAt, err)
+		}
+		candidates = append(candidates, existingExpense{
+			ID:        expense.ID,
+			Cents:     toCents(expense.Amount),
+			CreatedAt: time.Date(createdAt.Year(), createdAt.Month(), createdAt.Day(), 0, 0, 0, 0, time.UTC),
+			Note:      optionalString(expense.Note),
+		})
+	}
+
+	return candidates, nil
+}
+
+func buildImportPreview(transactions []ImportedTransaction, candidates []existingExpense, subcategoryID, userID *int) []ImportPreviewRow {
+	preview := make([]ImportPreviewRow, 0, len(transactions))
+	seen := make(map[importRowKey]int)
+
+	for _, transaction := range transactions {
+		row := ImportPreviewRow{
+			Line:          transaction.Line,
+			Date:          transaction.Date.Format("2006-01-02"),
+			Amount:        -transaction.Amount,
+			Note:          transaction.Note,
+			SubcategoryID: subcategoryID,
+			UserID:        userID,
+			Status:        "new",
+		}
+
+		if row.Amount <= 0 {
+			row.Status = "skipped"
+			row.Reason = "Credit or zero-amount transaction"
+			preview = append(preview, row)
+			continue
+		}
+
+		cents := toCents(row.Amount)
+		bestSimilarity := -1.0
+		for _, candidate := range candidates {
+			if candidate.Cents != cents {
+				continue
+			}
+			if math.Abs(float64(candidate.CreatedAt.Sub(transaction.Date))) > float64(duplicateDateTolerance) {
+				continue
+			}
+
+			similarity := noteSimilarity(transaction.Note, candidate.Note)
+			if similarity > bestSimilarity {
+				bestSimilarity = similarity
+				id := candidate.ID
+				row.DuplicateOf = &id
+			}
+		}
+
+		if row.DuplicateOf != nil {
+			row.Similarity = &bestSimilarity
+			if bestSimilarity >= duplicateNoteSimilarity {
+				row.Status = "duplicate"
+				row.Reason = "Same amount and date with a similar note"
+			} else {
+				row.Status = "possible_duplicate"
+				row.Reason = "Same amount and date"
+			}
+		}
+
+		// A statement can hold two genuinely identical payments, so a repeat
+		// within the file is only flagged for review.
+		key := importRowKey{Date: row.Date, Cents: cents, Note: strings.ToLower(strings.TrimSpace(row.Note))}
+		if line, ok := seen[key]; ok {
+			if row.Status == "new" {
+				row.Status = "possible_duplicate"
+				row.Reason = fmt.Sprintf("Same amount, date and note as line %d", line)
+			}
+			row.RepeatsLine = &line
+		} else {
+			seen[key] = transaction.Line
+		}
+
+		preview = append(preview, row)
+	}
+
+	return preview
+}
+
+func detectImportFormat(format, filename string) string {
+	if format != "" {
+		return strings.ToLower(format)
+	}
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".ofx", ".qfx":
+		return "ofx"
+	case ".qif":
+		return "qif"
+	default:
+		return "csv"
+	}
+}
+
+func parseOptionalIntField(value, name string) (*int, error) {
+	if value == "" {
+		return nil, nil
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil || parsed <= 0 {
+		return nil, fmt.Errorf("Invalid %s parameter", name)
+	}
+	return &parsed, nil
+}
+
+func importPreviewHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxImportFileSize+1<<20)
+	if err := r.ParseMultipartForm(maxImportFileSize); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid multipart form or file larger than %d MB", maxImportFileSize>>20), http.StatusBadRequest)
+		return
+	}
+
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		http.Error(w, "file is required", http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, maxImportFileSize+1))
+	if err != nil {
+		logger.Error(fmt.Sprintf("Failed to read import file: %v", err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if len(data) > maxImportFileSize {
+		http.Error(w, fmt.Sprintf("File larger than %d MB", maxImportFileSize>>20), http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	userID, err := parseOptionalIntField(r.FormValue("user_id"), "user_id")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	subcategoryID, err := parseOptionalIntField(r.FormValue("subcategory_id"), "subcategory_id")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	err = checkHouseholdRefs(householdID(r),
+		householdRef{"user_id", "users", userID},
+		householdRef{"subcategory_id", "subcategories", subcategoryID},
+	)
+	if writeRefError(w, err) {
+		return
+	}
+
+	var mapping ImportMapping
+	if mappingStr := r.FormValue("mapping"); mappingStr != "" {
+		if err := json.Unmarshal([]byte(mappingStr), &mapping); err != nil {
+			http.Error(w, "Invalid mapping JSON", http.StatusBadRequest)
+			return
+		}
+	}
+
+	format := detectImportFormat(r.FormValue("format"), header.Filename)
+
+	var transactions []ImportedTransaction
+	switch format {
+	case "csv":
+		transactions, err = parseCSVStatement(data, mapping)
+	case "ofx":
+		transactions, err = parseOFXStatement(data)
+	case "qif":
+		transactions, err = parseQIFStatement(data, mapping.DateFormat)
+	default:
+		http.Error(w, "Invalid format parameter. Must be 'csv', 'ofx', or 'qif'", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to parse %s file: %v", strings.ToUpper(format), err), http.StatusBadRequest)
+		return
+	}
+
+	candidates, err := loadDuplicateCandidates(householdID(r), transactions, userID)
+	if err != nil {
+		logger.Error(err.Error())
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	preview := buildImportPreview(transactions, candidates, subcategoryID, userID)
+
+	if subcategoryID == nil {
+		rules, err := loadCategorizationRules(householdID(r))
+		if err != nil {
+			logger.Error(err.Error())
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		for i := range preview {
+			if preview[i].Status == "skipped" {
+				continue
+			}
+			note := preview[i].Note
+			if rule := matchCategorizationRule(rules, preview[i].Amount, &note, userID); rule != nil {
+				suggested := rule.SubcategoryID
+				preview[i].SubcategoryID = &suggested
+			}
+		}
+	}
+
+	matchers, err := loadPayeeMatchers(householdID(r))
+	if err != nil {
+		logger.Error(err.Error())
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	for i := range preview {
+		if preview[i].Status == "skipped" {
+			continue
+		}
+		note := preview[i].Note
+		if payee := matchPayee(matchers, &note); payee != nil {
+			payeeID := payee.ID
+			preview[i].PayeeID = &payeeID
+		}
+	}
+
+	counts := map[string]int{}
+	for _, row := range preview {
+		counts[row.Status]++
+	}
+
+	logger.Info(fmt.Sprintf("Import preview of %s (%s): %d transactions", header.Filename, format, len(preview)))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"format":  format,
+		"rows":    preview,
+		"summary": counts,
+	})
+}
+
+func importCommitHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req ImportCommitRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxImportCommitSize)).Decode(&req); err != nil {
+		logger.Error(fmt.Sprintf("Failed to decode import commit body: %v", err))
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if len(req.Rows) == 0 {
+		http.Error(w, "At least one row is required", http.StatusBadRequest)
+		return
+	}
+	if len(req.Rows) > maxImportCommitRows {
+		http.Error(w, fmt.Sprintf("At most %d rows can be imported at once", maxImportCommitRows), http.StatusBadRequest)
+		return
+	}
+
+	// References are collected and checked together once the rows are valid;
+	// refRows holds the row of each reference for the error message.
+	dates := make([]time.Time, len(req.Rows))
+	var refs []householdRef
+	var refRows []int
+	for i, row := range req.Rows {
+		if err := validateCreateExpenseRequest(row.CreateExpenseRequest); err != nil {
+			http.Error(w, fmt.Sprintf("Row %d: %v", i+1, err), http.StatusBadRequest)
+			return
+		}
+
+		date, err := time.Parse("2006-01-02", row.Date)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Row %d: date must be in YYYY-MM-DD format", i+1), http.StatusBadRequest)
+			return
+		}
+		dates[i] = date
+
+		for _, ref := range expenseRefs(row.CreateExpenseRequest) {
+			refs = append(refs, ref)
+			refRows = append(refRows, i)
+		}
+	}
+
+	if failed, err := checkHouseholdRefsBatch(householdID(r), refs); err != nil {
+		if _, ok := err.(unknownRefError); ok {
+			http.Error(w, fmt.Sprintf("Row %d: %v", refRows[failed]+1, err), http.StatusBadRequest)
+		} else {
+			logger.Error(err.Error())
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	rules, err := loadCategorizationRules(householdID(r))
+	if err != nil {
+		logger.Error(err.Error())
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	matchers, err := loadPayeeMatchers(householdID(r))
+	if err != nil {
+		logger.Error(err.Error())
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	for i := range req.Rows {
+		categorizeExpenseRequest(rules, &req.Rows[i].CreateExpenseRequest)
+		suggestPayeeForRequest(matchers, &req.Rows[i].CreateExpenseRequest)
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		logger.Error(fmt.Sprintf("Failed to begin import transaction: %v", err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	ids := make([]int64, 0, len(req.Rows))
+	for i, row := range req.Rows {
+		id, err := insertExpense(tx, householdID(r), row.CreateExpenseRequest, &dates[i])
+		if err != nil {
+			logger.Error(fmt.Sprintf("Import row %d: %v", i+1, err))
+			http.Error(w, fmt.Sprintf("Row %d could not be saved", i+1), http.StatusBadRequest)
+			return
+		}
+		ids = append(ids, id)
+	}
+
+	if err := tx.Commit(); err != nil {
+		logger.Error(fmt.Sprintf("Failed to commit import transaction: %v", err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	invalidateExpenseCaches()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "Expenses imported successfully",
+		"count":   len(ids),
+		"ids":     ids,
+	})
+}
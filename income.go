@@ -0,0 +1,741 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type IncomeCategory struct {
+	ID          int     `json:"id"`
+	Name        string  `json:"name"`
+	IncomeCount int     `json:"income_count"`
+	Total       float64 `json:"total"`
+	CreatedAt   string  `json:"created_at"`
+}
+
+type Income struct {
+	ID                 int     `json:"id"`
+	Amount             float64 `json:"amount"`
+	IncomeCategoryID   *int    `json:"income_category_id"`
+	IncomeCategoryName *string `json:"income_category_name"`
+	UserID             *int    `json:"user_id"`
+	UserEmail          *string `json:"user_email"`
+	AccountID          *int    `json:"account_id"`
+	AccountName        *string `json:"account_name"`
+	Note               *string `json:"note"`
+	CreatedAt          string  `json:"created_at"`
+}
+
+type CreateIncomeRequest struct {
+	Amount           float64 `json:"amount"`
+	IncomeCategoryID *int    `json:"income_category_id,omitempty"`
+	UserID           *int    `json:"user_id,omitempty"`
+	AccountID        *int    `json:"account_id,omitempty"`
+	Note             *string `json:"note,omitempty"`
+	Date             *string `json:"date,omitempty"`
+}
+
+type UpdateIncomeRequest struct {
+	Amount           *float64 `json:"amount"`
+	IncomeCategoryID *int     `json:"income_category_id"`
+	UserID           *int     `json:"user_id"`
+	AccountID        *int     `json:"account_id"`
+	Note             *string  `json:"note"`
+	Date             *string  `json:"date"`
+}
+
+// IncomeFilter reuses the user, account and date filters of expenses; the
+// category filter is the income category.
+type IncomeFilter struct {
+	ExpenseFilter
+	IncomeCategoryIDs []int
+}
+
+func parseIncomeFilter(r *http.Request) (IncomeFilter, error) {
+	var filter IncomeFilter
+
+	expenseFilter, err := parseExpenseFilter(r)
+	if err != nil {
+		return filter, err
+	}
+	if expenseFilter.expenseOnly() {
+		return filter, fmt.Errorf("category_id, subcategory_id, payee_id and tag only apply to expenses; use income_category_id")
+	}
+	filter.ExpenseFilter = expenseFilter
+
+	if idStr := r.URL.Query().Get("income_category_id"); idStr != "" {
+		ids, err := parseCommaSeparatedInts(idStr)
+		if err != nil {
+			return filter, fmt.Errorf("Invalid income_category_id parameter")
+		}
+		filter.IncomeCategoryIDs = ids
+	}
+
+	return filter, nil
+}
+
+// conditions expects the query to alias incomes as i.
+func (f IncomeFilter) conditions() ([]string, []interface{}) {
+	conditions, args := f.periodConditions("i")
+
+	if len(f.IncomeCategoryIDs) > 0 {
+		conditions = append(conditions, fmt.Sprintf("i.income_category_id IN (%s)", placeholders(len(f.IncomeCategoryIDs))))
+		for _, id := range f.IncomeCategoryIDs {
+			args = append(args, id)
+		}
+	}
+
+	return conditions, args
+}
+
+func queryIncomes(filter IncomeFilter, orderBy, orderDir string) ([]Income, error) {
+	query := `
+		SELECT
+			i.id,
+			i.amount,
+			i.income_category_id,
+			ic.name as income_category_name,
+			i.user_id,
+			u.email as user_email,
+			i.account_id,
+			acc.name as account_name,
+			i.note,
+			i.created_at
+		FROM incomes i
+		LEFT JOIN income_categories ic ON i.income_category_id = ic.id
+		LEFT JOIN users u ON i.user_id = u.id
+		LEFT JOIN accounts acc ON i.account_id = acc.id
+	`
+
+	conditions, args := filter.conditions()
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	query += fmt.Sprintf(" ORDER BY %s %s", orderBy, orderDir)
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query incomes: %v", err)
+	}
+	defer rows.Close()
+
+	var incomes []Income
+
+	for rows.Next() {
+		var income Income
+		var categoryID, userID, accountID sql.NullInt64
+		var categoryName, userEmail, accountName, note sql.NullString
+
+		if err := rows.Scan(
+			&income.ID,
+			&income.Amount,
+			&categoryID,
+			&categoryName,
+			&userID,
+			&userEmail,
+			&accountID,
+			&accountName,
+			&note,
+			&income.CreatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan income row: %v", err)
+		}
+
+		income.IncomeCategoryID = nullableInt(categoryID)
+		income.UserID = nullableInt(userID)
+		income.AccountID = nullableInt(accountID)
+		if categoryName.Valid {
+			income.IncomeCategoryName = &categoryName.String
+		}
+		if userEmail.Valid {
+			income.UserEmail = &userEmail.String
+		}
+		if accountName.Valid {
+			income.AccountName = &accountName.String
+		}
+		if note.Valid {
+			income.Note = &note.String
+		}
+
+		incomes = append(incomes, income)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over income rows: %v", err)
+	}
+
+	return incomes, nil
+}
+
+func queryIncomeTotal(filter IncomeFilter) (float64, error) {
+	return queryIncomeTotalContext(context.Background(), filter)
+}
+
+func queryIncomeTotalContext(ctx context.Context, filter IncomeFilter) (float64, error) {
+	query := "SELECT SUM(i.amount) FROM incomes i"
+
+	conditions, args := filter.conditions()
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	var total sql.NullFloat64
+	if err := db.QueryRowContext(ctx, query, args...).Scan(&total); err != nil {
+		return 0, fmt.Errorf("failed to query income total: %v", err)
+	}
+
+	return total.Float64, nil
+}
+
+func queryIncomeGroups(filter IncomeFilter, groupBy, orderDir string) ([]ExpenseGroup, error) {
+	var columns, joins, groupColumns string
+
+	switch groupBy {
+	case "category":
+		columns = "COALESCE(i.income_category_id, 0), COALESCE(ic.name, 'Uncategorized')"
+		joins = "LEFT JOIN income_categories ic ON i.income_category_id = ic.id"
+		groupColumns = "i.income_category_id, ic.name"
+	case "user":
+		columns = "COALESCE(i.user_id, 0), COALESCE(u.display_name, 'Unknown User')"
+		joins = "LEFT JOIN users u ON i.user_id = u.id"
+		groupColumns = "i.user_id, u.display_name"
+	case "account":
+		columns = "acc.id, acc.name"
+		joins = "JOIN accounts acc ON i.account_id = acc.id"
+		groupColumns = "acc.id, acc.name"
+	default:
+		return nil, fmt.Errorf("unsupported group_by: %s", groupBy)
+	}
+
+	query := fmt.Sprintf("SELECT %s, SUM(i.amount) as total_amount, COUNT(*) FROM incomes i %s", columns, joins)
+
+	conditions, args := filter.conditions()
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	query += fmt.Sprintf(" GROUP BY %s ORDER BY total_amount %s", groupColumns, orderDir)
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query grouped incomes: %v", err)
+	}
+	defer rows.Close()
+
+	var groups []ExpenseGroup
+
+	for rows.Next() {
+		var group ExpenseGroup
+		if err := rows.Scan(&group.ID, &group.Name, &group.Total, &group.Count); err != nil {
+			return nil, fmt.Errorf("failed to scan grouped income row: %v", err)
+		}
+		groups = append(groups, group)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over grouped income rows: %v", err)
+	}
+
+	return groups, nil
+}
+
+func getIncomesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+
+	filter, err := parseIncomeFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	orderBy := "i.created_at"
+	switch query.Get("order_by") {
+	case "", "date":
+	case "amount":
+		orderBy = "i.amount"
+	default:
+		http.Error(w, "Invalid order_by parameter. Must be 'amount' or 'date'", http.StatusBadRequest)
+		return
+	}
+
+	orderDir := "DESC"
+	switch query.Get("order_dir") {
+	case "", "desc":
+	case "asc":
+		orderDir = "ASC"
+	default:
+		http.Error(w, "Invalid order_dir parameter. Must be 'asc' or 'desc'", http.StatusBadRequest)
+		return
+	}
+
+	if groupBy := query.Get("group_by"); groupBy != "" {
+		switch groupBy {
+		case "category", "user", "account":
+		default:
+			http.Error(w, "Invalid group_by parameter. Must be 'category', 'user', or 'account'", http.StatusBadRequest)
+			return
+		}
+
+		groups, err := queryIncomeGroups(filter, groupBy, orderDir)
+		if err != nil {
+			logger.Error(err.Error())
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		writeGroups(w, groups)
+		return
+	}
+
+	if query.Get("aggregates_only") == "true" {
+		total, err := queryIncomeTotal(filter)
+		if err != nil {
+			logger.Error(err.Error())
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"total_amount": total,
+		})
+		return
+	}
+
+	incomes, err := queryIncomes(filter, orderBy, orderDir)
+	if err != nil {
+		logger.Error(err.Error())
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if incomes == nil {
+		incomes = []Income{}
+	}
+	json.NewEncoder(w).Encode(incomes)
+}
+
+func createIncomeHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req CreateIncomeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		logger.Error(fmt.Sprintf("Failed to decode request body: %v", err))
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if req.Amount <= 0 {
+		http.Error(w, "Amount must be greater than 0", http.StatusBadRequest)
+		return
+	}
+
+	err := checkHouseholdRefs(householdID(r),
+		householdRef{"income_category_id", "income_categories", req.IncomeCategoryID},
+		householdRef{"user_id", "users", req.UserID},
+		householdRef{"account_id", "accounts", req.AccountID},
+	)
+	if writeRefError(w, err) {
+		return
+	}
+
+	var created sql.NullTime
+	if req.Date != nil {
+		date, err := time.Parse("2006-01-02", *req.Date)
+		if err != nil {
+			http.Error(w, "date must be in YYYY-MM-DD format", http.StatusBadRequest)
+			return
+		}
+		created = sql.NullTime{Time: date, Valid: true}
+	}
+
+	result, err := db.Exec(`
+		INSERT INTO incomes (household_id, amount, income_category_id, user_id, account_id, note, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, COALESCE(?, NOW()))
+	`, householdID(r), req.Amount, req.IncomeCategoryID, req.UserID, req.AccountID, req.Note, created)
+	if err != nil {
+		logger.Error(fmt.Sprintf("Failed to create income: %v", err))
+		http.Error(w, "Income could not be saved; check income_category_id, user_id and account_id", http.StatusBadRequest)
+		return
+	}
+
+	incomeID, err := result.LastInsertId()
+	if err != nil {
+		logger.Error(fmt.Sprintf("Failed to get last insert ID: %v", err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"id":                 incomeID,
+		"amount":             req.Amount,
+		"income_category_id": req.IncomeCategoryID,
+		"account_id":         req.AccountID,
+		"message":            "Income created successfully",
+	})
+}
+
+func updateIncomeHandler(w http.ResponseWriter, r *http.Request) {
+	incomeID, err := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/api/v1/incomes/"))
+	if err != nil {
+		http.Error(w, "Invalid income ID", http.StatusBadRequest)
+		return
+	}
+
+	var req UpdateIncomeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		logger.Error(fmt.Sprintf("Failed to decode request body: %v", err))
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	// Only the fields present in the body change; 0 clears the category,
+	// user or account.
+	var sets []string
+	var args []interface{}
+
+	clearable := func(column string, value *int) {
+		var id sql.NullInt64
+		if *value != 0 {
+			id = sql.NullInt64{Int64: int64(*value), Valid: true}
+		}
+		sets = append(sets, column+" = ?")
+		args = append(args, id)
+	}
+
+	if req.Amount != nil {
+		if *req.Amount <= 0 {
+			http.Error(w, "Amount must be greater than 0", http.StatusBadRequest)
+			return
+		}
+		sets = append(sets, "amount = ?")
+		args = append(args, *req.Amount)
+	}
+	if req.IncomeCategoryID != nil {
+		clearable("income_category_id", req.IncomeCategoryID)
+	}
+	if req.UserID != nil {
+		clearable("user_id", req.UserID)
+	}
+	if req.AccountID != nil {
+		clearable("account_id", req.AccountID)
+	}
+	if req.Note != nil {
+		sets = append(sets, "note = ?")
+		args = append(args, *req.Note)
+	}
+	if req.Date != nil {
+		date, err := time.Parse("2006-01-02", *req.Date)
+		if err != nil {
+			http.Error(w, "date must be in YYYY-MM-DD format", http.StatusBadRequest)
+			return
+		}
+		sets = append(sets, "created_at = ?")
+		args = append(args, date)
+	}
+
+	if len(sets) == 0 {
+		http.Error(w, "Nothing to update", http.StatusBadRequest)
+		return
+	}
+
+	var existingID int
+	err = db.QueryRow("SELECT id FROM incomes WHERE id = ? AND household_id = ?", incomeID, householdID(r)).Scan(&existingID)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Income not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		logger.Error(fmt.Sprintf("Failed to query income: %v", err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	err = checkHouseholdRefs(householdID(r),
+		householdRef{"income_category_id", "income_categories", req.IncomeCategoryID},
+		householdRef{"user_id", "users", req.UserID},
+		householdRef{"account_id", "accounts", req.AccountID},
+	)
+	if writeRefError(w, err) {
+		return
+	}
+
+	if _, err := db.Exec("UPDATE incomes SET "+strings.Join(sets, ", ")+" WHERE id = ?", append(args, incomeID)...); err != nil {
+		logger.Error(fmt.Sprintf("Failed to update income: %v", err))
+		http.Error(w, "Income could not be updated; check income_category_id, user_id and account_id", http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "Income updated successfully",
+		"id":      incomeID,
+	})
+}
+
+func deleteIncomeHandler(w http.ResponseWriter, r *http.Request) {
+	incomeID, err := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/api/v1/incomes/"))
+	if err != nil {
+		http.Error(w, "Invalid income ID", http.StatusBadRequest)
+		return
+	}
+
+	result, err := db.Exec("DELETE FROM incomes WHERE id = ? AND household_id = ?", incomeID, householdID(r))
+	if err != nil {
+		logger.Error(fmt.Sprintf("Failed to delete income: %v", err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		logger.Error(fmt.Sprintf("Failed to get rows affected: %v", err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	if rowsAffected == 0 {
+		http.Error(w, "Income not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "Income deleted successfully",
+		"id":      incomeID,
+	})
+}
+
+func singleIncomeHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPut, http.MethodPatch:
+		updateIncomeHandler(w, r)
+	case http.MethodDelete:
+		deleteIncomeHandler(w, r)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func getIncomeCategoriesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	rows, err := db.Query(`
+		SELECT ic.id, ic.name, COUNT(i.id), COALESCE(SUM(i.amount), 0), ic.created_at
+		FROM income_categories ic
+		LEFT JOIN incomes i ON i.income_category_id = ic.id
+		WHERE ic.household_id = ?
+		GROUP BY ic.id, ic.name, ic.created_at
+		ORDER BY ic.name
+	`, householdID(r))
+	if err != nil {
+		logger.Error(fmt.Sprintf("Failed to query income categories: %v", err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	categories := []IncomeCategory{}
+	for rows.Next() {
+		var category IncomeCategory
+		if err := rows.Scan(&category.ID, &category.Name, &category.IncomeCount, &category.Total, &category.CreatedAt); err != nil {
+			logger.Error(fmt.Sprintf("Failed to scan income category: %v", err))
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		categories = append(categories, category)
+	}
+
+	if err := rows.Err(); err != nil {
+		logger.Error(fmt.Sprintf("Error iterating income categories: %v", err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(categories)
+}
+
+func decodeIncomeCategoryName(w http.ResponseWriter, r *http.Request) (string, bool) {
+	var requestBody struct {
+		Name string `json:"name"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
+		http.Error(w, "Invalid JSON body", http.StatusBadRequest)
+		return "", false
+	}
+
+	name := strings.TrimSpace(requestBody.Name)
+	if name == "" || len(name) > 255 {
+		http.Error(w, "Name must be 1-255 characters", http.StatusBadRequest)
+		return "", false
+	}
+
+	return name, true
+}
+
+func createIncomeCategoryHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	name, ok := decodeIncomeCategoryName(w, r)
+	if !ok {
+		return
+	}
+
+	var existingID int
+	err := db.QueryRow("SELECT id FROM income_categories WHERE name = ? AND household_id = ?", name, householdID(r)).Scan(&existingID)
+	if err == nil {
+		http.Error(w, "Income category already exists", http.StatusConflict)
+		return
+	} else if err != sql.ErrNoRows {
+		logger.Error(fmt.Sprintf("Failed to check income category uniqueness: %v", err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	result, err := db.Exec("INSERT INTO income_categories (household_id, name) VALUES (?, ?)", householdID(r), name)
+	if err != nil {
+		logger.Error(fmt.Sprintf("Failed to create income category: %v", err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	categoryID, err := result.LastInsertId()
+	if err != nil {
+		logger.Error(fmt.Sprintf("Failed to get last insert ID: %v", err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "Income category created successfully",
+		"id":      categoryID,
+		"name":    name,
+	})
+}
+
+func updateIncomeCategoryHandler(w http.ResponseWriter, r *http.Request) {
+	categoryID, err := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/api/v1/income-categories/"))
+	if err != nil {
+		http.Error(w, "Invalid income category ID", http.StatusBadRequest)
+		return
+	}
+
+	name, ok := decodeIncomeCategoryName(w, r)
+	if !ok {
+		return
+	}
+
+	var existingID int
+	err = db.QueryRow("SELECT id FROM income_categories WHERE name = ? AND id != ? AND household_id = ?", name, categoryID, householdID(r)).Scan(&existingID)
+	if err == nil {
+		http.Error(w, "Income category name already exists", http.StatusConflict)
+		return
+	} else if err != sql.ErrNoRows {
+		logger.Error(fmt.Sprintf("Failed to check income category uniqueness: %v", err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	result, err := db.Exec("UPDATE income_categories SET name = ? WHERE id = ? AND household_id = ?", name, categoryID, householdID(r))
+	if err != nil {
+		logger.Error(fmt.Sprintf("Failed to update income category: %v", err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		logger.Error(fmt.Sprintf("Failed to get rows affected: %v", err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	if rowsAffected == 0 {
+		err = db.QueryRow("SELECT id FROM income_categories WHERE id = ? AND household_id = ?", categoryID, householdID(r)).Scan(&existingID)
+		if err == sql.ErrNoRows {
+			http.Error(w, "Income category not found", http.StatusNotFound)
+			return
+		} else if err != nil {
+			logger.Error(fmt.Sprintf("Failed to query income category: %v", err))
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "Income category updated successfully",
+		"id":      categoryID,
+		"name":    name,
+	})
+}
+
+func deleteIncomeCategoryHandler(w http.ResponseWriter, r *http.Request) {
+	categoryID, err := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/api/v1/income-categories/"))
+	if err != nil {
+		http.Error(w, "Invalid income category ID", http.StatusBadRequest)
+		return
+	}
+
+	result, err := db.Exec("DELETE FROM income_categories WHERE id = ? AND household_id = ?", categoryID, householdID(r))
+	if err != nil {
+		logger.Error(fmt.Sprintf("Failed to delete income category: %v", err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		logger.Error(fmt.Sprintf("Failed to get rows affected: %v", err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	if rowsAffected == 0 {
+		http.Error(w, "Income category not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "Income category deleted successfully",
+		"id":      categoryID,
+	})
+}
+
+func singleIncomeCategoryHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPut, http.MethodPatch:
+		updateIncomeCategoryHandler(w, r)
+	case http.MethodDelete:
+		deleteIncomeCategoryHandler(w, r)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,388 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultInsightsWindowDays = 180
+	maxInsightsWindowDays     = 730
+	defaultInsightsThreshold  = 3.0
+	minInsightsSamples        = 5
+	insightsCacheTTL          = 10 * time.Minute
+	maxInsightsCacheEntries   = 256
+)
+
+type AmountStats struct {
+	Count  int     `json:"count"`
+	Total  float64 `json:"total"`
+	Mean   float64 `json:"mean"`
+	Median float64 `json:"median"`
+	P25    float64 `json:"p25"`
+	P75    float64 `json:"p75"`
+	P90    float64 `json:"p90"`
+}
+
+type SubcategoryInsight struct {
+	SubcategoryID   int    `json:"subcategory_id"`
+	SubcategoryName string `json:"subcategory_name"`
+	CategoryID      int    `json:"category_id"`
+	CategoryName    string `json:"category_name"`
+	AmountStats
+	MonthlyMedian float64 `json:"monthly_median"`
+}
+
+type ExpenseAnomaly struct {
+	ExpenseID       int     `json:"expense_id"`
+	Amount          float64 `json:"amount"`
+	CreatedAt       string  `json:"created_at"`
+	Note            *string `json:"note"`
+	SubcategoryID   int     `json:"subcategory_id"`
+	SubcategoryName string  `json:"subcategory_name"`
+	Median          float64 `json:"median"`
+	Ratio           float64 `json:"ratio"`
+}
+
+type PeriodAnomaly struct {
+	SubcategoryID   int     `json:"subcategory_id"`
+	SubcategoryName string  `json:"subcategory_name"`
+	Month           string  `json:"month"`
+	Total           float64 `json:"total"`
+	Median          float64 `json:"median"`
+	Ratio           float64 `json:"ratio"`
+}
+
+type InsightsReport struct {
+	UserID          *int                 `json:"user_id"`
+	WindowDays      int                  `json:"window_days"`
+	DateFrom        string               `json:"date_from"`
+	DateTo          string               `json:"date_to"`
+	Threshold       float64              `json:"threshold"`
+	GeneratedAt     string               `json:"generated_at"`
+	Subcategories   []SubcategoryInsight `json:"subcategories"`
+	Anomalies       []ExpenseAnomaly     `json:"anomalies"`
+	PeriodAnomalies []PeriodAnomaly      `json:"period_anomalies"`
+}
+
+type insightsCacheEntry struct {
+	report  InsightsReport
+	expires time.Time
+}
+
+var insightsCache = struct {
+	sync.Mutex
+	entries map[string]insightsCacheEntry
+}{entries: make(map[string]insightsCacheEntry)}
+
+// invalidateExpenseCaches must be called after any write to the expenses table,
+// after renaming a category or subcategory, since reports carry the names, and
+// after moving a category, since reports roll totals up the tree.
+func invalidateExpenseCaches() {
+	insightsCache.Lock()
+	insightsCache.entries = make(map[string]insightsCacheEntry)
+	insightsCache.Unlock()
+}
+
+// cachedInsights returns an unexpired report, dropping the entry if it has
+// expired.
+func cachedInsights(key string, now time.Time) (insightsCacheEntry, bool) {
+	insightsCache.Lock()
+	defer insightsCache.Unlock()
+
+	entry, ok := insightsCache.entries[key]
+	if ok && now.After(entry.expires) {
+		delete(insightsCache.entries, key)
+		return entry, false
+	}
+	return entry, ok
+}
+
+// storeInsights caches a report. When the cache is full, expired entries are
+// swept first and then the entry closest to expiring is dropped, so the
+// number of threshold and date combinations cached stays bounded.
+func storeInsights(key string, entry insightsCacheEntry, now time.Time) {
+	insightsCache.Lock()
+	defer insightsCache.Unlock()
+
+	if _, ok := insightsCache.entries[key]; !ok && len(insightsCache.entries) >= maxInsightsCacheEntries {
+		for k, e := range insightsCache.entries {
+			if now.After(e.expires) {
+				delete(insightsCache.entries, k)
+			}
+		}
+		for len(insightsCache.entries) >= maxInsightsCacheEntries {
+			var oldestKey string
+			var oldest time.Time
+			for k, e := range insightsCache.entries {
+				if oldestKey == "" || e.expires.Before(oldest) {
+					oldestKey, oldest = k, e.expires
+				}
+			}
+			delete(insightsCache.entries, oldestKey)
+		}
+	}
+	insightsCache.entries[key] = entry
+}
+
+func percentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	if len(sorted) == 1 {
+		return sorted[0]
+	}
+
+	rank := p / 100 * float64(len(sorted)-1)
+	lower := int(math.Floor(rank))
+	upper := int(math.Ceil(rank))
+	weight := rank - float64(lower)
+
+	return sorted[lower]*(1-weight) + sorted[upper]*weight
+}
+
+func summarizeAmounts(amounts []float64) AmountStats {
+	stats := AmountStats{Count: len(amounts)}
+	if len(amounts) == 0 {
+		return stats
+	}
+
+	sorted := append([]float64(nil), amounts...)
+	sort.Float64s(sorted)
+
+	for _, amount := range sorted {
+		stats.Total += amount
+	}
+
+	stats.Mean = stats.Total / float64(len(sorted))
+	stats.Median = percentile(sorted, 50)
+	stats.P25 = percentile(sorted, 25)
+	stats.P75 = percentile(sorted, 75)
+	stats.P90 = percentile(sorted, 90)
+
+	return stats
+}
+
+type insightExpense struct {
+	ID        int
+	Amount    float64
+	CreatedAt time.Time
+	Note      *string
+}
+
+type insightGroup struct {
+	insight  SubcategoryInsight
+	expenses []insightExpense
+}
+
+func buildInsightsReport(filter ExpenseFilter, threshold float64) (InsightsReport, error) {
+	report := InsightsReport{
+		Subcategories:   []SubcategoryInsight{},
+		Anomalies:       []ExpenseAnomaly{},
+		PeriodAnomalies: []PeriodAnomaly{},
+	}
+
+	query := `
+		SELECT e.id, e.amount, e.created_at, e.note, s.id, s.name, c.id, c.name
+		FROM expenses e
+		JOIN subcategories s ON e.subcategory_id = s.id
+		JOIN categories c ON s.category_id = c.id
+	`
+
+	conditions, args := filter.conditions()
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += " ORDER BY e.created_at"
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return report, fmt.Errorf("failed to query insight expenses: %v", err)
+	}
+	defer rows.Close()
+
+	var groups []*insightGroup
+	groupIndex := make(map[int]*insightGroup)
+
+	for rows.Next() {
+		var expense insightExpense
+		var note *string
+		var subcategoryID, categoryID int
+		var subcategoryName, categoryName string
+
+		if err := rows.Scan(&expense.ID, &expense.Amount, &expense.CreatedAt, &note, &subcategoryID, &subcategoryName, &categoryID, &categoryName); err != nil {
+			return report, fmt.Errorf("failed to scan insight expense row: %v", err)
+		}
+		expense.Note = note
+
+		group, ok := groupIndex[subcategoryID]
+		if !ok {
+			group = &insightGroup{insight: SubcategoryInsight{
+				SubcategoryID:   subcategoryID,
+				SubcategoryName: subcategoryName,
+				CategoryID:      categoryID,
+				CategoryName:    categoryName,
+			}}
+			groupIndex[subcategoryID] = group
+			groups = append(groups, group)
+		}
+		group.expenses = append(group.expenses, expense)
+	}
+
+	if err = rows.Err(); err != nil {
+		return report, fmt.Errorf("error iterating over insight expense rows: %v", err)
+	}
+
+	for _, group := range groups {
+		amounts := make([]float64, len(group.expenses))
+		monthly := make(map[string]float64)
+		var months []string
+
+		for i, expense := range group.expenses {
+			amounts[i] = expense.Amount
+			month := expense.CreatedAt.Format("2006-01")
+			if _, ok := monthly[month]; !ok {
+				months = append(months, month)
+			}
+			monthly[month] += expense.Amount
+		}
+
+		group.insight.AmountStats = summarizeAmounts(amounts)
+
+		monthlyTotals := make([]float64, len(months))
+		for i, month := range months {
+			monthlyTotals[i] = monthly[month]
+		}
+		group.insight.MonthlyMedian = summarizeAmounts(monthlyTotals).Median
+
+		report.Subcategories = append(report.Subcategories, group.insight)
+
+		median := group.insight.Median
+		if group.insight.Count >= minInsightsSamples && median > 0 {
+			for _, expense := range group.expenses {
+				ratio := expense.Amount / median
+				if ratio < threshold {
+					continue
+				}
+				report.Anomalies = append(report.Anomalies, ExpenseAnomaly{
+					ExpenseID:       expense.ID,
+					Amount:          expense.Amount,
+					CreatedAt:       expense.CreatedAt.Format(time.RFC3339),
+					Note:            expense.Note,
+					SubcategoryID:   group.insight.SubcategoryID,
+					SubcategoryName: group.insight.SubcategoryName,
+					Median:          median,
+					Ratio:           ratio,
+				})
+			}
+		}
+
+		monthlyMedian := group.insight.MonthlyMedian
+		if len(months) >= 3 && monthlyMedian > 0 {
+			for _, month := range months {
+				ratio := monthly[month] / monthlyMedian
+				if ratio < threshold {
+					continue
+				}
+				report.PeriodAnomalies = append(report.PeriodAnomalies, PeriodAnomaly{
+					SubcategoryID:   group.insight.SubcategoryID,
+					SubcategoryName: group.insight.SubcategoryName,
+					Month:           month,
+					Total:           monthly[month],
+					Median:          monthlyMedian,
+					Ratio:           ratio,
+				})
+			}
+		}
+	}
+
+	sort.SliceStable(report.Subcategories, func(a, b int) bool {
+		return report.Subcategories[a].Total > report.Subcategories[b].Total
+	})
+	sort.SliceStable(report.Anomalies, func(a, b int) bool {
+		return report.Anomalies[a].Ratio > report.Anomalies[b].Ratio
+	})
+	sort.SliceStable(report.PeriodAnomalies, func(a, b int) bool {
+		return report.PeriodAnomalies[a].Ratio > report.PeriodAnomalies[b].Ratio
+	})
+
+	return report, nil
+}
+
+func insightsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userIDStr := r.URL.Query().Get("user_id")
+	windowDaysStr := r.URL.Query().Get("window_days")
+	thresholdStr := r.URL.Query().Get("threshold")
+
+	filter := ExpenseFilter{HouseholdID: householdID(r)}
+	if userIDStr != "" {
+		userID, err := strconv.Atoi(userIDStr)
+		if err != nil {
+			http.Error(w, "Invalid user_id parameter", http.StatusBadRequest)
+			return
+		}
+		filter.UserID = &userID
+	}
+
+	windowDays := defaultInsightsWindowDays
+	if windowDaysStr != "" {
+		days, err := strconv.Atoi(windowDaysStr)
+		if err != nil || days < 1 || days > maxInsightsWindowDays {
+			http.Error(w, fmt.Sprintf("Invalid window_days parameter. Must be 1-%d", maxInsightsWindowDays), http.StatusBadRequest)
+			return
+		}
+		windowDays = days
+	}
+
+	threshold := defaultInsightsThreshold
+	if thresholdStr != "" {
+		value, err := strconv.ParseFloat(thresholdStr, 64)
+		if err != nil || value <= 1 {
+			http.Error(w, "Invalid threshold parameter. Must be a number greater than 1", http.StatusBadRequest)
+			return
+		}
+		threshold = value
+	}
+
+	today := time.Now().UTC()
+	filter.DateTo = today.Format("2006-01-02")
+	filter.DateFrom = today.AddDate(0, 0, -(windowDays - 1)).Format("2006-01-02")
+
+	cacheKey := fmt.Sprintf("%d|%s|%s|%s|%g", filter.HouseholdID, userIDStr, filter.DateFrom, filter.DateTo, threshold)
+
+	entry, ok := cachedInsights(cacheKey, time.Now())
+	if !ok {
+		report, err := buildInsightsReport(filter, threshold)
+		if err != nil {
+			logger.Error(err.Error())
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		report.UserID = filter.UserID
+		report.WindowDays = windowDays
+		report.DateFrom = filter.DateFrom
+		report.DateTo = filter.DateTo
+		report.Threshold = threshold
+		report.GeneratedAt = time.Now().UTC().Format(time.RFC3339)
+
+		entry = insightsCacheEntry{report: report, expires: time.Now().Add(insightsCacheTTL)}
+
+		storeInsights(cacheKey, entry, time.Now())
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(entry.report)
+}
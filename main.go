@@ -1,6 +1,7 @@
 package main
 
 import (
+	"database/sql"
 	"fmt"
 	"net/http"
 	"os"
@@ -11,43 +12,56 @@ import (
 
 var logger *Logger
 
-func requireAuth(w http.ResponseWriter, r *http.Request) bool {
+// requireAuth validates the bearer token and returns the request with the
+// caller stored in its context (see currentUser).
+func requireAuth(w http.ResponseWriter, r *http.Request) (*http.Request, bool) {
 	authHeader := r.Header.Get("Authorization")
 	if authHeader == "" {
 		http.Error(w, "Authorization header required", http.StatusUnauthorized)
-		return false
+		return r, false
 	}
 
 	if !strings.HasPrefix(authHeader, "Bearer ") {
 		http.Error(w, "Invalid authorization format", http.StatusUnauthorized)
-		return false
+		return r, false
 	}
 
 	token := strings.TrimPrefix(authHeader, "Bearer ")
 
 	if token == "" {
 		http.Error(w, "Token is required", http.StatusUnauthorized)
-		return false
+		return r, false
 	}
 
 	claims, err := validateToken(token)
 	if err != nil {
 		logger.Error(fmt.Sprintf("Token validation failed: %v", err))
 		http.Error(w, "Invalid token", http.StatusUnauthorized)
-		return false
+		return r, false
 	}
 
-	// Add user info to request context for use in handlers
 	userID, _ := claims["user_id"].(float64)
 	email, _ := claims["email"].(string)
-	role, _ := claims["role"].(string)
 
-	// Store user info in request context (you can extend this later)
-	_ = userID
-	_ = email
-	_ = role
+	// The household and role are looked up on every request rather than
+	// trusted from the token, so joining another household, which resets the
+	// role, takes effect immediately.
+	householdID, role, err := lookupMembership(int(userID))
+	if err == sql.ErrNoRows {
+		http.Error(w, "Invalid token", http.StatusUnauthorized)
+		return r, false
+	} else if err != nil {
+		logger.Error(fmt.Sprintf("Failed to look up household: %v", err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return r, false
+	}
 
-	return true
+	return withAuthUser(r, authUser{
+		ID:          int(userID),
+		Email:       email,
+		Role:        role,
+		HouseholdID: householdID,
+	}), true
 }
 
 func main() {
@@ -70,6 +84,26 @@ func main() {
 		os.Exit(1)
 	}
 
+	if err := seedEmptyHouseholds(); err != nil {
+		logger.Error(fmt.Sprintf("Failed to seed category templates: %v", err))
+		os.Exit(1)
+	}
+
+	if err := initBlobStorage(); err != nil {
+		logger.Error(fmt.Sprintf("Failed to initialize attachment storage: %v", err))
+		os.Exit(1)
+	}
+
+	if err := initReceiptExtractor(); err != nil {
+		logger.Error(fmt.Sprintf("Failed to initialize receipt extractor: %v", err))
+		os.Exit(1)
+	}
+
+	if err := startReceiptWorkers(); err != nil {
+		logger.Error(fmt.Sprintf("Failed to start receipt workers: %v", err))
+		os.Exit(1)
+	}
+
 	logger.Info(fmt.Sprintf("Server running at %s:%s", host, port))
 
 	mux := http.NewServeMux()
@@ -87,7 +121,13 @@ func main() {
 			return
 		}
 
-		if !requireAuth(w, r) {
+		if r.URL.Path == "/api/v1/invitations/accept" {
+			acceptInvitationHandler(w, r)
+			return
+		}
+
+		r, ok := requireAuth(w, r)
+		if !ok {
 			return
 		}
 
@@ -102,6 +142,12 @@ func main() {
 				} else {
 					http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 				}
+			} else if r.URL.Path == "/api/v1/categories/order" {
+				reorderHandler(w, r, "categories", "category")
+			} else if r.URL.Path == "/api/v1/categories/tree" {
+				categoryTreeHandler(w, r)
+			} else if strings.HasSuffix(r.URL.Path, "/move") {
+				moveCategoryHandler(w, r)
 			} else if strings.HasPrefix(r.URL.Path, "/api/v1/categories/") {
 				logger.Info("Calling single category handler")
 				path := strings.TrimPrefix(r.URL.Path, "/api/v1/categories/")
@@ -119,6 +165,18 @@ func main() {
 					getCategoriesHandler(w, r)
 				}
 			}
+		} else if r.URL.Path == "/api/v1/household" {
+			householdHandler(w, r)
+		} else if r.URL.Path == "/api/v1/household/invitations" {
+			invitationsHandler(w, r)
+		} else if strings.HasPrefix(r.URL.Path, "/api/v1/household/invitations/") {
+			deleteInvitationHandler(w, r)
+		} else if r.URL.Path == "/api/v1/households" {
+			createHouseholdHandler(w, r)
+		} else if r.URL.Path == "/api/v1/category-templates" {
+			categoryTemplatesHandler(w, r)
+		} else if strings.HasPrefix(r.URL.Path, "/api/v1/category-templates/") {
+			applyCategoryTemplateHandler(w, r)
 		} else if r.URL.Path == "/api/v1/subcategories-by-expense-count" {
 			debugSubcategoriesByExpenseCountHandler(w, r)
 		} else if r.URL.Path == "/api/v1/grouped-expenses-by-subcategory" {
@@ -136,6 +194,10 @@ func main() {
 				} else {
 					http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 				}
+			} else if r.URL.Path == "/api/v1/subcategories/order" {
+				reorderHandler(w, r, "subcategories", "subcategory")
+			} else if isSubcategoryActionPath(r.URL.Path) {
+				subcategoryActionHandler(w, r)
 			} else if strings.HasPrefix(r.URL.Path, "/api/v1/subcategories/") {
 				if r.Method == http.MethodGet {
 					getSingleSubcategoryHandler(w, r)
@@ -149,9 +211,15 @@ func main() {
 			}
 		} else if strings.HasPrefix(r.URL.Path, "/api/v1/expenses/") {
 			path := strings.TrimPrefix(r.URL.Path, "/api/v1/expenses/")
-			if path != "" {
+			if isAttachmentPath(r.URL.Path) {
+				attachmentsHandler(w, r)
+			} else if isSplitPath(r.URL.Path) {
+				expenseSplitHandler(w, r)
+			} else if path != "" {
 				if r.Method == http.MethodDelete {
 					deleteExpenseHandler(w, r)
+				} else if r.Method == http.MethodPut || r.Method == http.MethodPatch {
+					updateExpenseHandler(w, r)
 				} else {
 					http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 				}
@@ -166,6 +234,136 @@ func main() {
 			} else {
 				http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 			}
+		} else if r.URL.Path == "/api/v1/incomes" {
+			if r.Method == http.MethodGet {
+				getIncomesHandler(w, r)
+			} else if r.Method == http.MethodPost {
+				createIncomeHandler(w, r)
+			} else {
+				http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			}
+		} else if strings.HasPrefix(r.URL.Path, "/api/v1/incomes/") {
+			singleIncomeHandler(w, r)
+		} else if r.URL.Path == "/api/v1/income-categories" {
+			if r.Method == http.MethodGet {
+				getIncomeCategoriesHandler(w, r)
+			} else if r.Method == http.MethodPost {
+				createIncomeCategoryHandler(w, r)
+			} else {
+				http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			}
+		} else if strings.HasPrefix(r.URL.Path, "/api/v1/income-categories/") {
+			singleIncomeCategoryHandler(w, r)
+		} else if r.URL.Path == "/api/v1/balances" {
+			balancesHandler(w, r)
+		} else if r.URL.Path == "/api/v1/settlements" {
+			settlementsHandler(w, r)
+		} else if strings.HasPrefix(r.URL.Path, "/api/v1/settlements/") {
+			deleteSettlementHandler(w, r)
+		} else if r.URL.Path == "/api/v1/claims" {
+			claimsHandler(w, r)
+		} else if r.URL.Path == "/api/v1/claims/export" {
+			exportClaimsHandler(w, r)
+		} else if strings.HasPrefix(r.URL.Path, "/api/v1/claims/") {
+			singleClaimHandler(w, r)
+		} else if r.URL.Path == "/api/v1/tags" {
+			if r.Method == http.MethodGet {
+				getTagsHandler(w, r)
+			} else if r.Method == http.MethodPost {
+				createTagHandler(w, r)
+			} else {
+				http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			}
+		} else if strings.HasPrefix(r.URL.Path, "/api/v1/tags/") {
+			if r.Method == http.MethodPut || r.Method == http.MethodPatch {
+				updateTagHandler(w, r)
+			} else if r.Method == http.MethodDelete {
+				deleteTagHandler(w, r)
+			} else {
+				http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			}
+		} else if r.URL.Path == "/api/v1/payees" {
+			if r.Method == http.MethodGet {
+				getPayeesHandler(w, r)
+			} else if r.Method == http.MethodPost {
+				createPayeeHandler(w, r)
+			} else {
+				http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			}
+		} else if r.URL.Path == "/api/v1/payees/suggest" {
+			suggestPayeeHandler(w, r)
+		} else if strings.HasPrefix(r.URL.Path, "/api/v1/payees/") {
+			if r.Method == http.MethodPut || r.Method == http.MethodPatch {
+				updatePayeeHandler(w, r)
+			} else if r.Method == http.MethodDelete {
+				deletePayeeHandler(w, r)
+			} else {
+				http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			}
+		} else if r.URL.Path == "/api/v1/accounts" {
+			if r.Method == http.MethodGet {
+				getAccountsHandler(w, r)
+			} else if r.Method == http.MethodPost {
+				createAccountHandler(w, r)
+			} else {
+				http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			}
+		} else if strings.HasPrefix(r.URL.Path, "/api/v1/accounts/") {
+			singleAccountHandler(w, r)
+		} else if r.URL.Path == "/api/v1/goals" {
+			goalsHandler(w, r)
+		} else if strings.HasPrefix(r.URL.Path, "/api/v1/goals/") {
+			singleGoalHandler(w, r)
+		} else if r.URL.Path == "/api/v1/dashboard" {
+			dashboardHandler(w, r)
+		} else if r.URL.Path == "/api/v1/receipts" {
+			receiptsHandler(w, r)
+		} else if strings.HasPrefix(r.URL.Path, "/api/v1/receipts/") {
+			singleReceiptHandler(w, r)
+		} else if r.URL.Path == "/api/v1/imports/preview" {
+			importPreviewHandler(w, r)
+		} else if r.URL.Path == "/api/v1/imports/commit" {
+			importCommitHandler(w, r)
+		} else if r.URL.Path == "/api/v1/rules/preview" {
+			previewRulesHandler(w, r)
+		} else if r.URL.Path == "/api/v1/rules/apply" {
+			applyRulesHandler(w, r)
+		} else if r.URL.Path == "/api/v1/rules" {
+			if r.Method == http.MethodGet {
+				getRulesHandler(w, r)
+			} else if r.Method == http.MethodPost {
+				createRuleHandler(w, r)
+			} else {
+				http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			}
+		} else if strings.HasPrefix(r.URL.Path, "/api/v1/rules/") {
+			if r.Method == http.MethodPut || r.Method == http.MethodPatch {
+				updateRuleHandler(w, r)
+			} else if r.Method == http.MethodDelete {
+				deleteRuleHandler(w, r)
+			} else {
+				http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			}
+		} else if r.URL.Path == "/api/v1/archive/export" {
+			exportArchiveHandler(w, r)
+		} else if r.URL.Path == "/api/v1/archive/import" {
+			importArchiveHandler(w, r)
+		} else if r.URL.Path == "/api/v1/reports/statement" {
+			statementReportHandler(w, r)
+		} else if r.URL.Path == "/api/v1/reports/cashflow" {
+			cashFlowReportHandler(w, r)
+		} else if r.URL.Path == "/api/v1/reports/timeseries" {
+			timeseriesReportHandler(w, r)
+		} else if r.URL.Path == "/api/v1/reports/compare" {
+			comparisonReportHandler(w, r)
+		} else if r.URL.Path == "/api/v1/reports/forecast" {
+			forecastReportHandler(w, r)
+		} else if r.URL.Path == "/api/v1/reports/pivot" {
+			pivotReportHandler(w, r)
+		} else if r.URL.Path == "/api/v1/reports/reimbursements" {
+			reimbursementsReportHandler(w, r)
+		} else if r.URL.Path == "/api/v1/insights" {
+			insightsHandler(w, r)
 		} else if r.URL.Path == "/api/v1/health" {
 			healthCheckHandler(w, r)
 		} else {
@@ -0,0 +1,17 @@
+-- Rules that pick a subcategory for new and imported expenses.
+
+CREATE TABLE categorization_rules (
+  id int NOT NULL AUTO_INCREMENT,
+  name varchar(255) NOT NULL,
+  subcategory_id int NOT NULL,
+  note_contains varchar(255) DEFAULT NULL,
+  note_regex varchar(255) DEFAULT NULL,
+  min_amount decimal(10,2) DEFAULT NULL,
+  max_amount decimal(10,2) DEFAULT NULL,
+  user_id int DEFAULT NULL,
+  priority int NOT NULL DEFAULT 0,
+  created_at timestamp NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (id),
+  CONSTRAINT categorization_rules_ibfk_1 FOREIGN KEY (subcategory_id) REFERENCES subcategories (id) ON DELETE CASCADE,
+  CONSTRAINT categorization_rules_ibfk_2 FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
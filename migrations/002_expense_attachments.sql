@@ -0,0 +1,16 @@
+-- Receipt files attached to expenses; the bytes live in blob storage under
+-- storage_key.
+
+CREATE TABLE expense_attachments (
+  id int NOT NULL AUTO_INCREMENT,
+  expense_id int NOT NULL,
+  filename varchar(255) NOT NULL,
+  content_type varchar(100) NOT NULL,
+  size int NOT NULL,
+  storage_key varchar(255) NOT NULL,
+  created_at timestamp NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (id),
+  UNIQUE KEY storage_key (storage_key),
+  KEY expense_id (expense_id),
+  CONSTRAINT expense_attachments_ibfk_1 FOREIGN KEY (expense_id) REFERENCES expenses (id) ON DELETE CASCADE
+);
@@ -0,0 +1,26 @@
+-- Uploaded receipts waiting for, or done with, text extraction.
+
+CREATE TABLE receipt_jobs (
+  id int NOT NULL AUTO_INCREMENT,
+  user_id int DEFAULT NULL,
+  filename varchar(255) NOT NULL,
+  content_type varchar(100) NOT NULL,
+  size int NOT NULL,
+  storage_key varchar(255) NOT NULL,
+  status varchar(20) NOT NULL DEFAULT 'queued',
+  extractor varchar(50) DEFAULT NULL,
+  error text,
+  amount decimal(10,2) DEFAULT NULL,
+  receipt_date date DEFAULT NULL,
+  merchant varchar(255) DEFAULT NULL,
+  subcategory_id int DEFAULT NULL,
+  raw_text text,
+  expense_id int DEFAULT NULL,
+  created_at timestamp NULL DEFAULT CURRENT_TIMESTAMP,
+  updated_at timestamp NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+  PRIMARY KEY (id),
+  KEY status (status),
+  CONSTRAINT receipt_jobs_ibfk_1 FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE,
+  CONSTRAINT receipt_jobs_ibfk_2 FOREIGN KEY (subcategory_id) REFERENCES subcategories (id) ON DELETE SET NULL,
+  CONSTRAINT receipt_jobs_ibfk_3 FOREIGN KEY (expense_id) REFERENCES expenses (id) ON DELETE SET NULL
+);
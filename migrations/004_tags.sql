@@ -0,0 +1,18 @@
+-- Free-form tags and the expenses they are on.
+
+CREATE TABLE tags (
+  id int NOT NULL AUTO_INCREMENT,
+  name varchar(50) NOT NULL,
+  created_at timestamp NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (id),
+  UNIQUE KEY name (name)
+);
+
+CREATE TABLE expense_tags (
+  expense_id int NOT NULL,
+  tag_id int NOT NULL,
+  PRIMARY KEY (expense_id, tag_id),
+  KEY tag_id (tag_id),
+  CONSTRAINT expense_tags_ibfk_1 FOREIGN KEY (expense_id) REFERENCES expenses (id) ON DELETE CASCADE,
+  CONSTRAINT expense_tags_ibfk_2 FOREIGN KEY (tag_id) REFERENCES tags (id) ON DELETE CASCADE
+);
@@ -0,0 +1,25 @@
+-- Payees with the aliases that match them in expense notes, and the payee
+-- of each expense.
+
+CREATE TABLE payees (
+  id int NOT NULL AUTO_INCREMENT,
+  name varchar(255) NOT NULL,
+  created_at timestamp NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (id),
+  UNIQUE KEY name (name)
+);
+
+CREATE TABLE payee_aliases (
+  id int NOT NULL AUTO_INCREMENT,
+  payee_id int NOT NULL,
+  alias varchar(255) NOT NULL,
+  created_at timestamp NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (id),
+  UNIQUE KEY alias (alias),
+  CONSTRAINT payee_aliases_ibfk_1 FOREIGN KEY (payee_id) REFERENCES payees (id) ON DELETE CASCADE
+);
+
+ALTER TABLE expenses
+  ADD COLUMN payee_id int DEFAULT NULL AFTER note,
+  ADD KEY payee_id (payee_id),
+  ADD CONSTRAINT expenses_ibfk_3 FOREIGN KEY (payee_id) REFERENCES payees (id) ON DELETE SET NULL;
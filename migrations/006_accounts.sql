@@ -0,0 +1,17 @@
+-- Accounts money is spent from, and the account of each expense.
+
+CREATE TABLE accounts (
+  id int NOT NULL AUTO_INCREMENT,
+  name varchar(255) NOT NULL,
+  type enum('card','cash','bank') NOT NULL,
+  currency char(3) NOT NULL,
+  opening_balance decimal(12,2) NOT NULL DEFAULT 0,
+  created_at timestamp NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (id),
+  UNIQUE KEY name (name)
+);
+
+ALTER TABLE expenses
+  ADD COLUMN account_id int DEFAULT NULL AFTER payee_id,
+  ADD KEY account_id (account_id),
+  ADD CONSTRAINT expenses_ibfk_4 FOREIGN KEY (account_id) REFERENCES accounts (id) ON DELETE RESTRICT;
@@ -0,0 +1,25 @@
+-- Income entries and their categories.
+
+CREATE TABLE income_categories (
+  id int NOT NULL AUTO_INCREMENT,
+  name varchar(255) NOT NULL,
+  created_at timestamp NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (id),
+  UNIQUE KEY name (name)
+);
+
+CREATE TABLE incomes (
+  id int NOT NULL AUTO_INCREMENT,
+  amount decimal(10,2) NOT NULL,
+  income_category_id int DEFAULT NULL,
+  user_id int DEFAULT NULL,
+  account_id int DEFAULT NULL,
+  note text,
+  created_at timestamp NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (id),
+  KEY user_id (user_id),
+  KEY created_at (created_at),
+  CONSTRAINT incomes_ibfk_1 FOREIGN KEY (income_category_id) REFERENCES income_categories (id) ON DELETE SET NULL,
+  CONSTRAINT incomes_ibfk_2 FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE,
+  CONSTRAINT incomes_ibfk_3 FOREIGN KEY (account_id) REFERENCES accounts (id) ON DELETE RESTRICT
+);
@@ -0,0 +1,26 @@
+-- How an expense is shared between users, and the payments that settle
+-- the balances.
+
+CREATE TABLE expense_splits (
+  expense_id int NOT NULL,
+  user_id int NOT NULL,
+  amount decimal(10,2) NOT NULL,
+  PRIMARY KEY (expense_id,user_id),
+  KEY user_id (user_id),
+  CONSTRAINT expense_splits_ibfk_1 FOREIGN KEY (expense_id) REFERENCES expenses (id) ON DELETE CASCADE,
+  CONSTRAINT expense_splits_ibfk_2 FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+
+CREATE TABLE settlements (
+  id int NOT NULL AUTO_INCREMENT,
+  from_user_id int NOT NULL,
+  to_user_id int NOT NULL,
+  amount decimal(10,2) NOT NULL,
+  note text,
+  created_at timestamp NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (id),
+  KEY from_user_id (from_user_id),
+  KEY to_user_id (to_user_id),
+  CONSTRAINT settlements_ibfk_1 FOREIGN KEY (from_user_id) REFERENCES users (id) ON DELETE CASCADE,
+  CONSTRAINT settlements_ibfk_2 FOREIGN KEY (to_user_id) REFERENCES users (id) ON DELETE CASCADE
+);
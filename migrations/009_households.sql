@@ -0,0 +1,115 @@
+-- Moves a database from before households into one default household.
+-- household_id is added as a nullable column, backfilled, and only then made
+-- NOT NULL, so the migration works on tables that already hold rows. Existing
+-- ADMIN users keep the role, now scoped to the default household.
+-- Run it once, after 001-008 and before starting a server version with
+-- household support.
+
+CREATE TABLE households (
+  id int NOT NULL AUTO_INCREMENT,
+  name varchar(255) NOT NULL,
+  created_at timestamp NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (id)
+);
+
+INSERT INTO households (name) VALUES ('Default household');
+SET @household_id = LAST_INSERT_ID();
+
+ALTER TABLE users ADD COLUMN household_id int NULL AFTER role;
+UPDATE users SET household_id = @household_id;
+ALTER TABLE users
+  MODIFY household_id int NOT NULL,
+  ADD KEY household_id (household_id),
+  ADD CONSTRAINT users_ibfk_1 FOREIGN KEY (household_id) REFERENCES households (id) ON DELETE CASCADE;
+
+ALTER TABLE categories ADD COLUMN household_id int NULL AFTER id;
+UPDATE categories SET household_id = @household_id;
+ALTER TABLE categories
+  MODIFY household_id int NOT NULL,
+  DROP INDEX name,
+  ADD UNIQUE KEY household_name (household_id,name),
+  ADD CONSTRAINT categories_ibfk_1 FOREIGN KEY (household_id) REFERENCES households (id) ON DELETE CASCADE;
+
+ALTER TABLE expenses ADD COLUMN household_id int NULL AFTER id;
+UPDATE expenses SET household_id = @household_id;
+ALTER TABLE expenses
+  MODIFY household_id int NOT NULL,
+  ADD KEY household_id (household_id),
+  ADD CONSTRAINT expenses_ibfk_5 FOREIGN KEY (household_id) REFERENCES households (id) ON DELETE CASCADE;
+
+ALTER TABLE categorization_rules ADD COLUMN household_id int NULL AFTER id;
+UPDATE categorization_rules SET household_id = @household_id;
+ALTER TABLE categorization_rules
+  MODIFY household_id int NOT NULL,
+  ADD CONSTRAINT categorization_rules_ibfk_3 FOREIGN KEY (household_id) REFERENCES households (id) ON DELETE CASCADE;
+
+ALTER TABLE receipt_jobs ADD COLUMN household_id int NULL AFTER id;
+UPDATE receipt_jobs SET household_id = @household_id;
+ALTER TABLE receipt_jobs
+  MODIFY household_id int NOT NULL,
+  ADD CONSTRAINT receipt_jobs_ibfk_4 FOREIGN KEY (household_id) REFERENCES households (id) ON DELETE CASCADE;
+
+ALTER TABLE tags ADD COLUMN household_id int NULL AFTER id;
+UPDATE tags SET household_id = @household_id;
+ALTER TABLE tags
+  MODIFY household_id int NOT NULL,
+  DROP INDEX name,
+  ADD UNIQUE KEY household_name (household_id,name),
+  ADD CONSTRAINT tags_ibfk_1 FOREIGN KEY (household_id) REFERENCES households (id) ON DELETE CASCADE;
+
+ALTER TABLE payees ADD COLUMN household_id int NULL AFTER id;
+UPDATE payees SET household_id = @household_id;
+ALTER TABLE payees
+  MODIFY household_id int NOT NULL,
+  DROP INDEX name,
+  ADD UNIQUE KEY household_name (household_id,name),
+  ADD CONSTRAINT payees_ibfk_1 FOREIGN KEY (household_id) REFERENCES households (id) ON DELETE CASCADE;
+
+-- Aliases are unique per household now, which the application checks.
+ALTER TABLE payee_aliases
+  DROP INDEX alias,
+  ADD KEY alias (alias);
+
+ALTER TABLE accounts ADD COLUMN household_id int NULL AFTER id;
+UPDATE accounts SET household_id = @household_id;
+ALTER TABLE accounts
+  MODIFY household_id int NOT NULL,
+  DROP INDEX name,
+  ADD UNIQUE KEY household_name (household_id,name),
+  ADD CONSTRAINT accounts_ibfk_1 FOREIGN KEY (household_id) REFERENCES households (id) ON DELETE CASCADE;
+
+ALTER TABLE income_categories ADD COLUMN household_id int NULL AFTER id;
+UPDATE income_categories SET household_id = @household_id;
+ALTER TABLE income_categories
+  MODIFY household_id int NOT NULL,
+  DROP INDEX name,
+  ADD UNIQUE KEY household_name (household_id,name),
+  ADD CONSTRAINT income_categories_ibfk_1 FOREIGN KEY (household_id) REFERENCES households (id) ON DELETE CASCADE;
+
+ALTER TABLE incomes ADD COLUMN household_id int NULL AFTER id;
+UPDATE incomes SET household_id = @household_id;
+ALTER TABLE incomes
+  MODIFY household_id int NOT NULL,
+  ADD CONSTRAINT incomes_ibfk_4 FOREIGN KEY (household_id) REFERENCES households (id) ON DELETE CASCADE;
+
+ALTER TABLE settlements ADD COLUMN household_id int NULL AFTER id;
+UPDATE settlements SET household_id = @household_id;
+ALTER TABLE settlements
+  MODIFY household_id int NOT NULL,
+  ADD CONSTRAINT settlements_ibfk_3 FOREIGN KEY (household_id) REFERENCES households (id) ON DELETE CASCADE;
+
+CREATE TABLE household_invitations (
+  id int NOT NULL AUTO_INCREMENT,
+  household_id int NOT NULL,
+  email varchar(255) NOT NULL,
+  token_hash char(64) NOT NULL,
+  invited_by int DEFAULT NULL,
+  expires_at timestamp NOT NULL,
+  accepted_at timestamp NULL DEFAULT NULL,
+  created_at timestamp NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (id),
+  UNIQUE KEY token_hash (token_hash),
+  KEY household_id (household_id),
+  CONSTRAINT household_invitations_ibfk_1 FOREIGN KEY (household_id) REFERENCES households (id) ON DELETE CASCADE,
+  CONSTRAINT household_invitations_ibfk_2 FOREIGN KEY (invited_by) REFERENCES users (id) ON DELETE SET NULL
+);
@@ -0,0 +1,27 @@
+-- Reimbursement claims, and which expenses are reimbursable and on which
+-- claim. Existing expenses start as not reimbursable and unclaimed.
+
+CREATE TABLE reimbursement_claims (
+  id int NOT NULL AUTO_INCREMENT,
+  household_id int NOT NULL,
+  user_id int NOT NULL,
+  title varchar(255) NOT NULL,
+  status enum('draft','submitted','approved','paid') NOT NULL DEFAULT 'draft',
+  submitted_at timestamp NULL DEFAULT NULL,
+  approved_at timestamp NULL DEFAULT NULL,
+  approved_by int DEFAULT NULL,
+  paid_at timestamp NULL DEFAULT NULL,
+  created_at timestamp NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (id),
+  KEY household_status (household_id,status),
+  KEY user_id (user_id),
+  CONSTRAINT reimbursement_claims_ibfk_1 FOREIGN KEY (household_id) REFERENCES households (id) ON DELETE CASCADE,
+  CONSTRAINT reimbursement_claims_ibfk_2 FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE,
+  CONSTRAINT reimbursement_claims_ibfk_3 FOREIGN KEY (approved_by) REFERENCES users (id) ON DELETE SET NULL
+);
+
+ALTER TABLE expenses
+  ADD COLUMN reimbursable tinyint(1) NOT NULL DEFAULT '0' AFTER account_id,
+  ADD COLUMN claim_id int DEFAULT NULL AFTER reimbursable,
+  ADD KEY claim_id (claim_id),
+  ADD CONSTRAINT expenses_ibfk_6 FOREIGN KEY (claim_id) REFERENCES reimbursement_claims (id) ON DELETE SET NULL;
@@ -0,0 +1,30 @@
+-- Savings goals and the contributions made towards them.
+
+CREATE TABLE savings_goals (
+  id int NOT NULL AUTO_INCREMENT,
+  household_id int NOT NULL,
+  name varchar(255) NOT NULL,
+  target_amount decimal(12,2) NOT NULL,
+  target_date date NOT NULL,
+  user_id int DEFAULT NULL,
+  created_at timestamp NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (id),
+  UNIQUE KEY household_name (household_id,name),
+  KEY user_id (user_id),
+  CONSTRAINT savings_goals_ibfk_1 FOREIGN KEY (household_id) REFERENCES households (id) ON DELETE CASCADE,
+  CONSTRAINT savings_goals_ibfk_2 FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE SET NULL
+);
+
+CREATE TABLE goal_contributions (
+  id int NOT NULL AUTO_INCREMENT,
+  goal_id int NOT NULL,
+  amount decimal(10,2) NOT NULL,
+  user_id int DEFAULT NULL,
+  note varchar(255) DEFAULT NULL,
+  created_at timestamp NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (id),
+  KEY goal_created (goal_id,created_at),
+  KEY user_id (user_id),
+  CONSTRAINT goal_contributions_ibfk_1 FOREIGN KEY (goal_id) REFERENCES savings_goals (id) ON DELETE CASCADE,
+  CONSTRAINT goal_contributions_ibfk_2 FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE SET NULL
+);
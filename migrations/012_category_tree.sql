@@ -0,0 +1,8 @@
+-- Lets categories nest. Existing data converts in place: every category
+-- becomes a root (parent_id NULL) and subcategories stay the leaves under
+-- it, so no category, subcategory or expense changes ID.
+
+ALTER TABLE categories
+  ADD COLUMN parent_id int DEFAULT NULL AFTER household_id,
+  ADD KEY parent_id (parent_id),
+  ADD CONSTRAINT categories_ibfk_2 FOREIGN KEY (parent_id) REFERENCES categories (id);
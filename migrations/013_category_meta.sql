@@ -0,0 +1,15 @@
+-- Display metadata for categories and subcategories. Existing rows get no
+-- color or icon, sort_order 0 (so they keep listing by name) and are not
+-- archived.
+
+ALTER TABLE categories
+  ADD COLUMN color char(7) DEFAULT NULL AFTER name,
+  ADD COLUMN icon varchar(64) DEFAULT NULL AFTER color,
+  ADD COLUMN sort_order int NOT NULL DEFAULT 0 AFTER icon,
+  ADD COLUMN archived tinyint(1) NOT NULL DEFAULT 0 AFTER sort_order;
+
+ALTER TABLE subcategories
+  ADD COLUMN color char(7) DEFAULT NULL AFTER name,
+  ADD COLUMN icon varchar(64) DEFAULT NULL AFTER color,
+  ADD COLUMN sort_order int NOT NULL DEFAULT 0 AFTER icon,
+  ADD COLUMN archived tinyint(1) NOT NULL DEFAULT 0 AFTER sort_order;
@@ -0,0 +1,11 @@
+-- Which version of each category template a household has applied, so
+-- applying it again only adds what a newer version brings.
+
+CREATE TABLE category_template_applications (
+  household_id int NOT NULL,
+  template_key varchar(64) NOT NULL,
+  version int NOT NULL,
+  applied_at timestamp NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (household_id,template_key),
+  CONSTRAINT category_template_applications_ibfk_1 FOREIGN KEY (household_id) REFERENCES households (id) ON DELETE CASCADE
+);
@@ -0,0 +1,494 @@
+package main
+
+import (
+	"bytes"
+	"compress/zlib"
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+type ReceiptFields struct {
+	Amount   *float64 `json:"amount"`
+	Date     *string  `json:"date"`
+	Merchant *string  `json:"merchant"`
+	Text     string   `json:"-"`
+}
+
+// ReceiptExtractor pulls the total, date and merchant out of an uploaded
+// receipt. Implementations must honour ctx so stuck extractions can be cut
+// off by the job runner.
+type ReceiptExtractor interface {
+	Name() string
+	Extract(ctx context.Context, data []byte, contentType string) (ReceiptFields, error)
+}
+
+var receiptExtractor ReceiptExtractor
+
+func initReceiptExtractor() error {
+	parser := receiptParser{DayFirst: os.Getenv("RECEIPT_DATE_ORDER") != "mdy"}
+
+	tesseractPath := os.Getenv("TESSERACT_PATH")
+	if tesseractPath == "" {
+		tesseractPath = "tesseract"
+	}
+
+	switch backend := os.Getenv("RECEIPT_EXTRACTOR"); backend {
+	case "", "auto":
+		if path, err := exec.LookPath(tesseractPath); err == nil {
+			receiptExtractor = &TesseractExtractor{Path: path, Language: os.Getenv("TESSERACT_LANG"), Parser: parser}
+		} else {
+			receiptExtractor = &RuleBasedExtractor{Parser: parser}
+		}
+	case "tesseract":
+		path, err := exec.LookPath(tesseractPath)
+		if err != nil {
+			return fmt.Errorf("tesseract not found: %v", err)
+		}
+		receiptExtractor = &TesseractExtractor{Path: path, Language: os.Getenv("TESSERACT_LANG"), Parser: parser}
+	case "rules":
+		receiptExtractor = &RuleBasedExtractor{Parser: parser}
+	default:
+		return fmt.Errorf("unknown RECEIPT_EXTRACTOR %q", backend)
+	}
+
+	logger.Info(fmt.Sprintf("Using %s receipt extractor", receiptExtractor.Name()))
+	return nil
+}
+
+// RuleBasedExtractor reads the text layer of PDF receipts and applies the
+// receipt rules to it. It cannot read photos.
+type RuleBasedExtractor struct {
+	Parser receiptParser
+}
+
+func (e *RuleBasedExtractor) Name() string {
+	return "rules"
+}
+
+func (e *RuleBasedExtractor) Extract(ctx context.Context, data []byte, contentType string) (ReceiptFields, error) {
+	if contentType != "application/pdf" {
+		return ReceiptFields{}, fmt.Errorf("the rule-based extractor only reads PDFs with a text layer; install tesseract to read photos")
+	}
+
+	text := pdfText(data)
+	if strings.TrimSpace(text) == "" {
+		return ReceiptFields{}, fmt.Errorf("PDF has no text layer")
+	}
+
+	return e.Parser.parse(text), nil
+}
+
+// TesseractExtractor runs the tesseract CLI on photos. PDFs go through the
+// text layer like the rule-based extractor, as tesseract cannot read them.
+type TesseractExtractor struct {
+	Path     string
+	Language string
+	Parser   receiptParser
+}
+
+func (e *TesseractExtractor) Name() string {
+	return "tesseract"
+}
+
+func (e *TesseractExtractor) Extract(ctx context.Context, data []byte, contentType string) (ReceiptFields, error) {
+	if contentType == "application/pdf" {
+		return (&RuleBasedExtractor{Parser: e.Parser}).Extract(ctx, data, contentType)
+	}
+
+	args := []string{"stdin", "stdout"}
+	if e.Language != "" {
+		args = append(args, "-l", e.Language)
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, e.Path, args...)
+	cmd.Stdin = bytes.NewReader(data)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return ReceiptFields{}, fmt.Errorf("tesseract timed out")
+		}
+		return ReceiptFields{}, fmt.Errorf("tesseract failed: %v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	return e.Parser.parse(stdout.String()), nil
+}
+
+var (
+	receiptAmountPattern   = regexp.MustCompile(`(\d{1,3}(?:[ ,.]\d{3})+|\d+)[.,](\d{2})\b`)
+	receiptTotalPattern    = regexp.MustCompile(`(?i)\b(grand total|total|amount due|balance due|to pay|amount)\b`)
+	receiptNotTotalPattern = regexp.MustCompile(`(?i)sub\s*-?\s*total|total\s+(tax|vat|discount|savings|items)|\b(change|tax|vat|cash|tendered)\b`)
+	receiptISODatePattern  = regexp.MustCompile(`\b(\d{4})[-/.](\d{1,2})[-/.](\d{1,2})\b`)
+	receiptDatePattern     = regexp.MustCompile(`\b(\d{1,2})[-/.](\d{1,2})[-/.](\d{4}|\d{2})\b`)
+	receiptDayMonthPattern = regexp.MustCompile(`(?i)\b(\d{1,2})\s+(jan|feb|mar|apr|may|jun|jul|aug|sep|oct|nov|dec)[a-z]*\.?,?\s+(\d{4})\b`)
+	receiptMonthDayPattern = regexp.MustCompile(`(?i)\b(jan|feb|mar|apr|may|jun|jul|aug|sep|oct|nov|dec)[a-z]*\.?\s+(\d{1,2}),?\s+(\d{4})\b`)
+	receiptSkipLinePattern = regexp.MustCompile(`(?i)^(receipt|invoice|tax invoice|welcome|thank you)`)
+)
+
+var receiptMonths = map[string]time.Month{
+	"jan": time.January, "feb": time.February, "mar": time.March, "apr": time.April,
+	"may": time.May, "jun": time.June, "jul": time.July, "aug": time.August,
+	"sep": time.September, "oct": time.October, "nov": time.November, "dec": time.December,
+}
+
+type receiptParser struct {
+	DayFirst bool
+}
+
+func parseReceiptAmount(match []string) (float64, bool) {
+	whole := strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' {
+			return r
+		}
+		return -1
+	}, match[1])
+
+	amount, err := strconv.ParseFloat(whole+"."+match[2], 64)
+	return amount, err == nil && amount > 0
+}
+
+func (p receiptParser) amount(lines []string) *float64 {
+	best, fallback := 0.0, 0.0
+
+	for _, line := range lines {
+		line = receiptISODatePattern.ReplaceAllString(line, " ")
+		line = receiptDatePattern.ReplaceAllString(line, " ")
+
+		matches := receiptAmountPattern.FindAllStringSubmatch(line, -1)
+		if len(matches) == 0 {
+			continue
+		}
+
+		last, ok := parseReceiptAmount(matches[len(matches)-1])
+		if !ok {
+			continue
+		}
+
+		fallback = math.Max(fallback, last)
+		if receiptTotalPattern.MatchString(line) && !receiptNotTotalPattern.MatchString(line) {
+			best = math.Max(best, last)
+		}
+	}
+
+	if best == 0 {
+		best = fallback
+	}
+	if best == 0 {
+		return nil
+	}
+	return &best
+}
+
+func receiptDate(year, month, day int) (time.Time, bool) {
+	if year < 100 {
+		year += 2000
+	}
+	date := time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.UTC)
+	if date.Year() != year || int(date.Month()) != month || date.Day() != day {
+		return time.Time{}, false
+	}
+	if year < 2000 || date.After(time.Now().AddDate(0, 0, 1)) {
+		return time.Time{}, false
+	}
+	return date, true
+}
+
+func (p receiptParser) date(text string) *string {
+	var found []time.Time
+
+	for _, m := range receiptISODatePattern.FindAllStringSubmatch(text, -1) {
+		year, _ := strconv.Atoi(m[1])
+		month, _ := strconv.Atoi(m[2])
+		day, _ := strconv.Atoi(m[3])
+		if date, ok := receiptDate(year, month, day); ok {
+			found = append(found, date)
+		}
+	}
+
+	for _, m := range receiptDatePattern.FindAllStringSubmatch(text, -1) {
+		first, _ := strconv.Atoi(m[1])
+		second, _ := strconv.Atoi(m[2])
+		year, _ := strconv.Atoi(m[3])
+
+		day, month := first, second
+		if first <= 12 && (second > 12 || !p.DayFirst) {
+			day, month = second, first
+		}
+		if date, ok := receiptDate(year, month, day); ok {
+			found = append(found, date)
+		}
+	}
+
+	for _, m := range receiptDayMonthPattern.FindAllStringSubmatch(text, -1) {
+		day, _ := strconv.Atoi(m[1])
+		year, _ := strconv.Atoi(m[3])
+		if date, ok := receiptDate(year, int(receiptMonths[strings.ToLower(m[2])]), day); ok {
+			found = append(found, date)
+		}
+	}
+
+	for _, m := range receiptMonthDayPattern.FindAllStringSubmatch(text, -1) {
+		day, _ := strconv.Atoi(m[2])
+		year, _ := strconv.Atoi(m[3])
+		if date, ok := receiptDate(year, int(receiptMonths[strings.ToLower(m[1])]), day); ok {
+			found = append(found, date)
+		}
+	}
+
+	if len(found) == 0 {
+		return nil
+	}
+
+	// Receipts sometimes print a return-by or expiry date; the purchase date
+	// is the earliest one.
+	earliest := found[0]
+	for _, date := range found[1:] {
+		if date.Before(earliest) {
+			earliest = date
+		}
+	}
+
+	formatted := earliest.Format("2006-01-02")
+	return &formatted
+}
+
+// merchant is the first line near the top that reads like a name rather
+// than an amount, date or greeting.
+func (p receiptParser) merchant(lines []string) *string {
+	for i, line := range lines {
+		if i >= 8 {
+			break
+		}
+
+		letters := 0
+		for _, r := range line {
+			if unicode.IsLetter(r) {
+				letters++
+			}
+		}
+
+		if letters < 3 || receiptAmountPattern.MatchString(line) || receiptSkipLinePattern.MatchString(line) {
+			continue
+		}
+		if receiptISODatePattern.MatchString(line) || receiptDatePattern.MatchString(line) {
+			continue
+		}
+
+		name := strings.Join(strings.Fields(line), " ")
+		if runes := []rune(name); len(runes) > 100 {
+			name = string(runes[:100])
+		}
+		return &name
+	}
+
+	return nil
+}
+
+func (p receiptParser) parse(text string) ReceiptFields {
+	var lines []string
+	for _, line := range strings.Split(text, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+
+	return ReceiptFields{
+		Amount:   p.amount(lines),
+		Date:     p.date(text),
+		Merchant: p.merchant(lines),
+		Text:     strings.Join(lines, "\n"),
+	}
+}
+
+var pdfStreamPattern = regexp.MustCompile(`(?s)<<(.*?)>>\s*stream\r?\n`)
+
+// pdfText extracts the strings shown by Tj, TJ, ' and " operators from every
+// content stream, starting a new line whenever the text position moves.
+// It is enough for receipts generated by point-of-sale and billing systems;
+// fonts with custom encodings come out garbled.
+func pdfText(data []byte) string {
+	var out strings.Builder
+
+	for _, loc := range pdfStreamPattern.FindAllSubmatchIndex(data, -1) {
+		dict := data[loc[2]:loc[3]]
+		start := loc[1]
+		end := bytes.Index(data[start:], []byte("endstream"))
+		if end < 0 {
+			break
+		}
+		stream := data[start : start+end]
+
+		if bytes.Contains(dict, []byte("/FlateDecode")) {
+			reader, err := zlib.NewReader(bytes.NewReader(stream))
+			if err != nil {
+				continue
+			}
+			decoded, err := io.ReadAll(io.LimitReader(reader, 10<<20))
+			reader.Close()
+			if err != nil && len(decoded) == 0 {
+				continue
+			}
+			stream = decoded
+		} else if bytes.Contains(dict, []byte("/Filter")) {
+			continue
+		}
+
+		pdfContentText(stream, &out)
+	}
+
+	return out.String()
+}
+
+func pdfContentText(content []byte, out *strings.Builder) {
+	var operands []float64
+	var strs []string
+	lineY := 0.0
+	lastY := math.NaN()
+
+	// Td/TD/Tm moves change the line only when y changes; pieces placed on
+	// the same baseline (a label and its right-aligned amount) stay together.
+	show := func() {
+		text := strings.Join(strs, "")
+		if strings.TrimSpace(text) == "" {
+			return
+		}
+		if !math.IsNaN(lastY) {
+			if math.Abs(lineY-lastY) > 1 {
+				out.WriteByte('\n')
+			} else {
+				out.WriteByte(' ')
+			}
+		}
+		out.WriteString(text)
+		lastY = lineY
+	}
+
+	isDelimiter := func(c byte) bool {
+		return strings.IndexByte(" \t\r\n\f\x00()<>[]{}/%", c) >= 0
+	}
+
+	for i := 0; i < len(content); i++ {
+		c := content[i]
+
+		switch {
+		case c == '%':
+			for i < len(content) && content[i] != '\n' && content[i] != '\r' {
+				i++
+			}
+		case c == '(':
+			var str strings.Builder
+			depth := 1
+			for i++; i < len(content) && depth > 0; i++ {
+				c = content[i]
+				switch c {
+				case '\\':
+					if i+1 >= len(content) {
+						continue
+					}
+					i++
+					switch next := content[i]; next {
+					case 'n', 'r', 't':
+						str.WriteByte(' ')
+					case '0', '1', '2', '3', '4', '5', '6', '7':
+						octal := string(next)
+						for len(octal) < 3 && i+1 < len(content) && content[i+1] >= '0' && content[i+1] <= '7' {
+							i++
+							octal += string(content[i])
+						}
+						value, _ := strconv.ParseUint(octal, 8, 8)
+						str.WriteRune(rune(value))
+					default:
+						str.WriteByte(next)
+					}
+				case '(':
+					depth++
+					str.WriteByte(c)
+				case ')':
+					depth--
+					if depth > 0 {
+						str.WriteByte(c)
+					}
+				default:
+					str.WriteRune(rune(c))
+				}
+			}
+			i--
+			strs = append(strs, str.String())
+		case c == '<' && i+1 < len(content) && content[i+1] != '<':
+			end := bytes.IndexByte(content[i:], '>')
+			if end < 0 {
+				return
+			}
+			hexDigits := strings.Map(func(r rune) rune {
+				if unicode.Is(unicode.ASCII_Hex_Digit, r) {
+					return r
+				}
+				return -1
+			}, string(content[i+1:i+end]))
+			if len(hexDigits)%2 == 1 {
+				hexDigits += "0"
+			}
+			var str strings.Builder
+			for j := 0; j < len(hexDigits); j += 2 {
+				value, _ := strconv.ParseUint(hexDigits[j:j+2], 16, 8)
+				str.WriteRune(rune(value))
+			}
+			strs = append(strs, str.String())
+			i += end
+		case c == '/':
+			for i+1 < len(content) && !isDelimiter(content[i+1]) {
+				i++
+			}
+		case isDelimiter(c):
+			continue
+		default:
+			j := i
+			for j < len(content) && !isDelimiter(content[j]) {
+				j++
+			}
+			token := string(content[i:j])
+			i = j - 1
+
+			if value, err := strconv.ParseFloat(token, 64); err == nil {
+				operands = append(operands, value)
+				continue
+			}
+
+			switch token {
+			case "BT":
+				lineY = 0
+			case "Td", "TD":
+				if len(operands) >= 2 {
+					lineY += operands[len(operands)-1]
+				}
+			case "Tm":
+				if len(operands) >= 6 {
+					lineY = operands[len(operands)-1]
+				}
+			case "T*":
+				lineY -= 10
+			case "Tj", "TJ":
+				show()
+			case "'", "\"":
+				lineY -= 10
+				show()
+			}
+
+			operands = operands[:0]
+			strs = strs[:0]
+		}
+	}
+
+	out.WriteByte('\n')
+}
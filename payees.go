@@ -0,0 +1,522 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+type Payee struct {
+	ID           int      `json:"id"`
+	Name         string   `json:"name"`
+	Aliases      []string `json:"aliases"`
+	ExpenseCount int      `json:"expense_count"`
+	Total        float64  `json:"total"`
+	CreatedAt    string   `json:"created_at"`
+}
+
+type PayeeRequest struct {
+	Name    *string  `json:"name"`
+	Aliases []string `json:"aliases"`
+}
+
+// payeeMatcher holds the normalized canonical name and aliases of a payee.
+type payeeMatcher struct {
+	ID       int
+	Name     string
+	patterns []string
+}
+
+// normalizePayeeText lowercases text and reduces everything that is not a
+// letter or digit to single spaces, so "LIDL  Sofia-1" becomes "lidl sofia 1".
+func normalizePayeeText(text string) string {
+	return strings.Join(strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}), " ")
+}
+
+func loadPayeeMatchers(householdID int) ([]payeeMatcher, error) {
+	rows, err := db.Query(`
+		SELECT p.id, p.name, a.alias
+		FROM payees p
+		LEFT JOIN payee_aliases a ON a.payee_id = p.id
+		WHERE p.household_id = ?
+		ORDER BY p.id, a.id
+	`, householdID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query payees: %v", err)
+	}
+	defer rows.Close()
+
+	var matchers []payeeMatcher
+
+	for rows.Next() {
+		var id int
+		var name string
+		var alias sql.NullString
+		if err := rows.Scan(&id, &name, &alias); err != nil {
+			return nil, fmt.Errorf("failed to scan payee row: %v", err)
+		}
+
+		if len(matchers) == 0 || matchers[len(matchers)-1].ID != id {
+			matchers = append(matchers, payeeMatcher{ID: id, Name: name, patterns: []string{normalizePayeeText(name)}})
+		}
+		if alias.Valid {
+			current := &matchers[len(matchers)-1]
+			current.patterns = append(current.patterns, alias.String)
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over payee rows: %v", err)
+	}
+
+	return matchers, nil
+}
+
+// matchPayee finds the payee whose name or alias appears as whole words in
+// the note. The longest match wins, so "lidl plus" beats "lidl".
+func matchPayee(matchers []payeeMatcher, note *string) *payeeMatcher {
+	if note == nil {
+		return nil
+	}
+
+	text := " " + normalizePayeeText(*note) + " "
+	if text == "  " {
+		return nil
+	}
+
+	var best *payeeMatcher
+	bestLength := 0
+
+	for i := range matchers {
+		for _, pattern := range matchers[i].patterns {
+			if pattern == "" || len(pattern) <= bestLength {
+				continue
+			}
+			if strings.Contains(text, " "+pattern+" ") {
+				best = &matchers[i]
+				bestLength = len(pattern)
+			}
+		}
+	}
+
+	return best
+}
+
+func suggestPayeeForRequest(matchers []payeeMatcher, req *CreateExpenseRequest) {
+	if req.PayeeID != nil {
+		return
+	}
+	if payee := matchPayee(matchers, req.Note); payee != nil {
+		payeeID := payee.ID
+		req.PayeeID = &payeeID
+	}
+}
+
+func getPayee(householdID, payeeID int) (*Payee, error) {
+	var payee Payee
+	err := db.QueryRow(`
+		SELECT p.id, p.name, COUNT(e.id), COALESCE(SUM(e.amount), 0), p.created_at
+		FROM payees p
+		LEFT JOIN expenses e ON e.payee_id = p.id
+		WHERE p.id = ? AND p.household_id = ?
+		GROUP BY p.id, p.name, p.created_at
+	`, payeeID, householdID).Scan(&payee.ID, &payee.Name, &payee.ExpenseCount, &payee.Total, &payee.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query payee: %v", err)
+	}
+
+	rows, err := db.Query("SELECT alias FROM payee_aliases WHERE payee_id = ? ORDER BY alias", payeeID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query payee aliases: %v", err)
+	}
+	defer rows.Close()
+
+	payee.Aliases = []string{}
+	for rows.Next() {
+		var alias string
+		if err := rows.Scan(&alias); err != nil {
+			return nil, fmt.Errorf("failed to scan payee alias: %v", err)
+		}
+		payee.Aliases = append(payee.Aliases, alias)
+	}
+
+	return &payee, rows.Err()
+}
+
+// validatePayee normalizes the name and aliases and checks that neither is
+// already claimed by another payee of the household. The returned status is
+// the HTTP status to answer with when err is set.
+func validatePayee(householdID int, name string, aliases []string, payeeID int) (string, []string, int, error) {
+	name = strings.Join(strings.Fields(name), " ")
+	if name == "" || len(name) > 255 {
+		return "", nil, http.StatusBadRequest, fmt.Errorf("Payee name is required and must be at most 255 characters")
+	}
+
+	normalizedName := normalizePayeeText(name)
+	if normalizedName == "" {
+		return "", nil, http.StatusBadRequest, fmt.Errorf("Payee name must contain letters or digits")
+	}
+
+	seen := map[string]bool{normalizedName: true}
+	var normalized []string
+	for _, alias := range aliases {
+		alias = normalizePayeeText(alias)
+		if alias == "" || seen[alias] {
+			continue
+		}
+		if len(alias) > 255 {
+			return "", nil, http.StatusBadRequest, fmt.Errorf("Aliases must be at most 255 characters")
+		}
+		seen[alias] = true
+		normalized = append(normalized, alias)
+	}
+	sort.Strings(normalized)
+
+	matchers, err := loadPayeeMatchers(householdID)
+	if err != nil {
+		return "", nil, http.StatusInternalServerError, err
+	}
+
+	for _, matcher := range matchers {
+		if matcher.ID == payeeID {
+			continue
+		}
+		for _, pattern := range matcher.patterns {
+			if seen[pattern] {
+				return "", nil, http.StatusConflict, fmt.Errorf("%q is already used by payee %q", pattern, matcher.Name)
+			}
+		}
+	}
+
+	return name, normalized, http.StatusOK, nil
+}
+
+func savePayeeAliases(tx *sql.Tx, payeeID int64, aliases []string) error {
+	if _, err := tx.Exec("DELETE FROM payee_aliases WHERE payee_id = ?", payeeID); err != nil {
+		return fmt.Errorf("failed to clear payee aliases: %v", err)
+	}
+
+	for _, alias := range aliases {
+		if _, err := tx.Exec("INSERT INTO payee_aliases (payee_id, alias) VALUES (?, ?)", payeeID, alias); err != nil {
+			return fmt.Errorf("failed to save payee alias: %v", err)
+		}
+	}
+
+	return nil
+}
+
+func writePayeeError(w http.ResponseWriter, status int, err error) {
+	if status == http.StatusInternalServerError {
+		logger.Error(err.Error())
+		http.Error(w, "Internal server error", status)
+		return
+	}
+	http.Error(w, err.Error(), status)
+}
+
+func getPayeesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	rows, err := db.Query(`
+		SELECT p.id, p.name, COUNT(e.id), COALESCE(SUM(e.amount), 0), p.created_at
+		FROM payees p
+		LEFT JOIN expenses e ON e.payee_id = p.id
+		WHERE p.household_id = ?
+		GROUP BY p.id, p.name, p.created_at
+		ORDER BY p.name
+	`, householdID(r))
+	if err != nil {
+		logger.Error(fmt.Sprintf("Failed to query payees: %v", err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	payees := []Payee{}
+	index := make(map[int]int)
+	for rows.Next() {
+		payee := Payee{Aliases: []string{}}
+		if err := rows.Scan(&payee.ID, &payee.Name, &payee.ExpenseCount, &payee.Total, &payee.CreatedAt); err != nil {
+			logger.Error(fmt.Sprintf("Failed to scan payee: %v", err))
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		index[payee.ID] = len(payees)
+		payees = append(payees, payee)
+	}
+
+	if err := rows.Err(); err != nil {
+		logger.Error(fmt.Sprintf("Error iterating payees: %v", err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	aliasRows, err := db.Query(`
+		SELECT a.payee_id, a.alias
+		FROM payee_aliases a
+		JOIN payees p ON p.id = a.payee_id
+		WHERE p.household_id = ?
+		ORDER BY a.alias
+	`, householdID(r))
+	if err != nil {
+		logger.Error(fmt.Sprintf("Failed to query payee aliases: %v", err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	defer aliasRows.Close()
+
+	for aliasRows.Next() {
+		var payeeID int
+		var alias string
+		if err := aliasRows.Scan(&payeeID, &alias); err != nil {
+			logger.Error(fmt.Sprintf("Failed to scan payee alias: %v", err))
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		if i, ok := index[payeeID]; ok {
+			payees[i].Aliases = append(payees[i].Aliases, alias)
+		}
+	}
+
+	if err := aliasRows.Err(); err != nil {
+		logger.Error(fmt.Sprintf("Error iterating payee aliases: %v", err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(payees)
+}
+
+func createPayeeHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req PayeeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON body", http.StatusBadRequest)
+		return
+	}
+
+	name, aliases, status, err := validatePayee(householdID(r), optionalString(req.Name), req.Aliases, 0)
+	if err != nil {
+		writePayeeError(w, status, err)
+		return
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		logger.Error(fmt.Sprintf("Failed to begin transaction: %v", err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec("INSERT INTO payees (household_id, name) VALUES (?, ?)", householdID(r), name)
+	if err != nil {
+		logger.Error(fmt.Sprintf("Failed to create payee: %v", err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	payeeID, err := result.LastInsertId()
+	if err != nil {
+		logger.Error(fmt.Sprintf("Failed to get last insert ID: %v", err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	if err := savePayeeAliases(tx, payeeID, aliases); err != nil {
+		logger.Error(err.Error())
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		logger.Error(fmt.Sprintf("Failed to commit payee: %v", err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	payee, err := getPayee(householdID(r), int(payeeID))
+	if err != nil || payee == nil {
+		logger.Error(fmt.Sprintf("Failed to load created payee: %v", err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(payee)
+}
+
+func updatePayeeHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut && r.Method != http.MethodPatch {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	payeeID, err := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/api/v1/payees/"))
+	if err != nil {
+		http.Error(w, "Invalid payee ID", http.StatusBadRequest)
+		return
+	}
+
+	var req PayeeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON body", http.StatusBadRequest)
+		return
+	}
+
+	existing, err := getPayee(householdID(r), payeeID)
+	if err != nil {
+		logger.Error(err.Error())
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if existing == nil {
+		http.Error(w, "Payee not found", http.StatusNotFound)
+		return
+	}
+
+	// Fields left out keep their current value; aliases replaces the list.
+	name := existing.Name
+	if req.Name != nil {
+		name = *req.Name
+	}
+	aliases := existing.Aliases
+	if req.Aliases != nil {
+		aliases = req.Aliases
+	}
+
+	name, aliases, status, err := validatePayee(householdID(r), name, aliases, payeeID)
+	if err != nil {
+		writePayeeError(w, status, err)
+		return
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		logger.Error(fmt.Sprintf("Failed to begin transaction: %v", err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("UPDATE payees SET name = ? WHERE id = ?", name, payeeID); err != nil {
+		logger.Error(fmt.Sprintf("Failed to update payee: %v", err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	if err := savePayeeAliases(tx, int64(payeeID), aliases); err != nil {
+		logger.Error(err.Error())
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		logger.Error(fmt.Sprintf("Failed to commit payee: %v", err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	invalidateExpenseCaches()
+
+	payee, err := getPayee(householdID(r), payeeID)
+	if err != nil || payee == nil {
+		logger.Error(fmt.Sprintf("Failed to load updated payee: %v", err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(payee)
+}
+
+func deletePayeeHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	payeeID, err := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/api/v1/payees/"))
+	if err != nil {
+		http.Error(w, "Invalid payee ID", http.StatusBadRequest)
+		return
+	}
+
+	result, err := db.Exec("DELETE FROM payees WHERE id = ? AND household_id = ?", payeeID, householdID(r))
+	if err != nil {
+		logger.Error(fmt.Sprintf("Failed to delete payee: %v", err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		logger.Error(fmt.Sprintf("Failed to get rows affected: %v", err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	if rowsAffected == 0 {
+		http.Error(w, "Payee not found", http.StatusNotFound)
+		return
+	}
+
+	invalidateExpenseCaches()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "Payee deleted successfully",
+		"id":      payeeID,
+	})
+}
+
+func suggestPayeeHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	note := r.URL.Query().Get("note")
+	if strings.TrimSpace(note) == "" {
+		http.Error(w, "note is required", http.StatusBadRequest)
+		return
+	}
+
+	matchers, err := loadPayeeMatchers(householdID(r))
+	if err != nil {
+		logger.Error(err.Error())
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	response := map[string]interface{}{
+		"payee_id":   nil,
+		"payee_name": nil,
+	}
+	if payee := matchPayee(matchers, &note); payee != nil {
+		response["payee_id"] = payee.ID
+		response["payee_name"] = payee.Name
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+)
+
+const (
+	pdfPageWidth  = 595.28
+	pdfPageHeight = 841.89
+	pdfMargin     = 50.0
+)
+
+// pdfDocument is a minimal PDF 1.4 writer: A4 pages, the two built-in
+// Helvetica faces and filled rectangles. Text is WinAnsi encoded; the first
+// text WinAnsi cannot encode is kept in err and fails writeTo, rather than
+// printing as '?'.
+type pdfDocument struct {
+	pages []*bytes.Buffer
+	y     float64
+	err   error
+}
+
+// pdfTextError is text the built-in fonts cannot print.
+type pdfTextError struct {
+	text string
+	r    rune
+}
+
+func (e pdfTextError) Error() string {
+	return fmt.Sprintf("%q cannot be printed: %q is outside the Latin-1 characters of the PDF fonts", e.text, e.r)
+}
+
+// winAnsiExtras are the WinAnsi characters outside Latin-1, by their code.
+var winAnsiExtras = map[rune]byte{
+	'€': 0x80, '‚': 0x82, 'ƒ': 0x83, '„': 0x84, '…': 0x85, '†': 0x86, '‡': 0x87,
+	'ˆ': 0x88, '‰': 0x89, 'Š': 0x8A, '‹': 0x8B, 'Œ': 0x8C, 'Ž': 0x8E, '‘': 0x91,
+	'’': 0x92, '“': 0x93, '”': 0x94, '•': 0x95, '–': 0x96, '—': 0x97, '˜': 0x98,
+	'™': 0x99, 'š': 0x9A, '›': 0x9B, 'œ': 0x9C, 'ž': 0x9E, 'Ÿ': 0x9F,
+}
+
+// pdfUnprintable returns the first rune of text that WinAnsi cannot encode.
+func pdfUnprintable(text string) (rune, bool) {
+	for _, r := range text {
+		if r >= 0x80 && r < 0xA0 || r >= 256 {
+			if _, ok := winAnsiExtras[r]; !ok {
+				return r, true
+			}
+		}
+	}
+	return 0, false
+}
+
+func newPDFDocument() *pdfDocument {
+	doc := &pdfDocument{}
+	doc.addPage()
+	return doc
+}
+
+func (doc *pdfDocument) addPage() {
+	doc.pages = append(doc.pages, &bytes.Buffer{})
+	doc.y = pdfPageHeight - pdfMargin
+}
+
+func (doc *pdfDocument) page() *bytes.Buffer {
+	return doc.pages[len(doc.pages)-1]
+}
+
+// ensureSpace starts a new page when fewer than height points remain.
+func (doc *pdfDocument) ensureSpace(height float64) {
+	if doc.y-height < pdfMargin {
+		doc.addPage()
+	}
+}
+
+func pdfEscape(text string) string {
+	var builder strings.Builder
+	for _, r := range text {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			builder.WriteByte('\\')
+			builder.WriteRune(r)
+		case r == '\t':
+			builder.WriteByte(' ')
+		case r < 32:
+			continue
+		case r < 128:
+			builder.WriteRune(r)
+		case r >= 0xA0 && r < 256:
+			fmt.Fprintf(&builder, "\\%03o", r)
+		default:
+			if code, ok := winAnsiExtras[r]; ok {
+				fmt.Fprintf(&builder, "\\%03o", code)
+			} else {
+				builder.WriteByte('?')
+			}
+		}
+	}
+	return builder.String()
+}
+
+// pdfTextWidth approximates Helvetica advance widths closely enough to right
+// align numbers.
+func pdfTextWidth(text string, size float64) float64 {
+	width := 0.0
+	for _, r := range text {
+		switch {
+		case r >= '0' && r <= '9', r == '.', r == ',':
+			width += 0.556
+		case r == ' ':
+			width += 0.278
+		case r >= 'A' && r <= 'Z':
+			width += 0.667
+		default:
+			width += 0.5
+		}
+	}
+	return width * size
+}
+
+func (doc *pdfDocument) text(x, y, size float64, bold bool, text string) {
+	if doc.err == nil {
+		if r, bad := pdfUnprintable(text); bad {
+			doc.err = pdfTextError{text: text, r: r}
+		}
+	}
+
+	font := "F1"
+	if bold {
+		font = "F2"
+	}
+	fmt.Fprintf(doc.page(), "BT /%s %.2f Tf %.2f %.2f Td (%s) Tj ET\n", font, size, x, y, pdfEscape(text))
+}
+
+func (doc *pdfDocument) textRight(right, y, size float64, bold bool, text string) {
+	doc.text(right-pdfTextWidth(text, size), y, size, bold, text)
+}
+
+func (doc *pdfDocument) rect(x, y, width, height, gray float64) {
+	fmt.Fprintf(doc.page(), "%.3f g %.2f %.2f %.2f %.2f re f 0 g\n", gray, x, y, width, height)
+}
+
+func (doc *pdfDocument) line(x1, y1, x2, y2 float64) {
+	fmt.Fprintf(doc.page(), "0.5 w %.2f %.2f m %.2f %.2f l S\n", x1, y1, x2, y2)
+}
+
+func (doc *pdfDocument) writeTo(w io.Writer) error {
+	if doc.err != nil {
+		return doc.err
+	}
+
+	var out bytes.Buffer
+	var offsets []int
+
+	object := func(body string) {
+		offsets = append(offsets, out.Len())
+		fmt.Fprintf(&out, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+
+	out.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+
+	pageCount := len(doc.pages)
+	kids := make([]string, pageCount)
+	for i := range doc.pages {
+		kids[i] = fmt.Sprintf("%d 0 R", 5+i*2)
+	}
+
+	object("<< /Type /Catalog /Pages 2 0 R >>")
+	object(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), pageCount))
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>")
+
+	for i, content := range doc.pages {
+		object(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.2f %.2f] /Resources << /Font << /F1 3 0 R /F2 4 0 R >> >> /Contents %d 0 R >>", pdfPageWidth, pdfPageHeight, 6+i*2))
+		object(fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", content.Len(), content.String()))
+	}
+
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+
+	_, err := w.Write(out.Bytes())
+	return err
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestPDFText(t *testing.T) {
+	tests := []struct {
+		name    string
+		text    string
+		want    string
+		wantErr bool
+	}{
+		{name: "ASCII", text: "Food (home)", want: `(Food \(home\))`},
+		{name: "Latin-1", text: "Café", want: `(Caf\351)`},
+		{name: "WinAnsi beyond Latin-1", text: "€5 – rent", want: `(\2005 \226 rent)`},
+		{name: "Cyrillic fails", text: "Продукты", wantErr: true},
+		{name: "C1 control fails", text: "a\u0085b", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc := newPDFDocument()
+			doc.text(pdfMargin, doc.y, 10, false, tt.text)
+
+			var out bytes.Buffer
+			err := doc.writeTo(&out)
+			if tt.wantErr {
+				if !errors.As(err, new(pdfTextError)) {
+					t.Fatalf("writeTo = %v, want a pdfTextError", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("writeTo: %v", err)
+			}
+			if !strings.Contains(out.String(), tt.want+" Tj") {
+				t.Errorf("PDF does not contain %s", tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,266 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type pivotDimension struct {
+	key   string
+	label string
+}
+
+var pivotDimensions = map[string]pivotDimension{
+	"category":    {key: "COALESCE(c.id, 0)", label: "COALESCE(c.name, 'Uncategorized')"},
+	"subcategory": {key: "COALESCE(s.id, 0)", label: "COALESCE(s.name, 'Uncategorized')"},
+	"user":        {key: "COALESCE(e.user_id, 0)", label: "COALESCE(u.display_name, 'Unknown User')"},
+	"month":       {key: "DATE_FORMAT(e.created_at, '%Y-%m')", label: "DATE_FORMAT(e.created_at, '%Y-%m')"},
+	"weekday":     {key: "WEEKDAY(e.created_at)", label: "DAYNAME(e.created_at)"},
+	"tag":         {key: "COALESCE(t.id, 0)", label: "COALESCE(t.name, 'Untagged')"},
+	"payee":       {key: "COALESCE(p.id, 0)", label: "COALESCE(p.name, 'No Payee')"},
+	"account":     {key: "COALESCE(acc.id, 0)", label: "COALESCE(acc.name, 'No Account')"},
+}
+
+type PivotCell struct {
+	Total float64 `json:"total"`
+	Count int     `json:"count"`
+}
+
+type PivotHeader struct {
+	Key   string `json:"key"`
+	Label string `json:"label"`
+}
+
+type PivotRow struct {
+	PivotHeader
+	Cells []PivotCell `json:"cells"`
+	PivotCell
+}
+
+type PivotReport struct {
+	RowsDimension    string        `json:"rows_dimension"`
+	ColumnsDimension string        `json:"columns_dimension"`
+	Columns          []PivotHeader `json:"columns"`
+	Rows             []PivotRow    `json:"rows"`
+	ColumnTotals     []PivotCell   `json:"column_totals"`
+	PivotCell
+}
+
+func pivotDimensionNames() string {
+	names := make([]string, 0, len(pivotDimensions))
+	for name := range pivotDimensions {
+		names = append(names, "'"+name+"'")
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// sortPivotHeaders orders time dimensions chronologically and everything else
+// by label.
+func sortPivotHeaders(headers []PivotHeader, dimension string) {
+	sort.Slice(headers, func(a, b int) bool {
+		switch dimension {
+		case "month":
+			return headers[a].Key < headers[b].Key
+		case "weekday":
+			x, _ := strconv.Atoi(headers[a].Key)
+			y, _ := strconv.Atoi(headers[b].Key)
+			return x < y
+		default:
+			return strings.ToLower(headers[a].Label) < strings.ToLower(headers[b].Label)
+		}
+	})
+}
+
+func buildPivotReport(filter ExpenseFilter, rowsDimension, columnsDimension string) (PivotReport, error) {
+	report := PivotReport{
+		RowsDimension:    rowsDimension,
+		ColumnsDimension: columnsDimension,
+	}
+
+	rowDim := pivotDimensions[rowsDimension]
+	colDim := pivotDimensions[columnsDimension]
+
+	query := fmt.Sprintf(`
+		SELECT %s, %s, %s, %s, SUM(e.amount), COUNT(*)
+		FROM expenses e
+		LEFT JOIN subcategories s ON e.subcategory_id = s.id
+		LEFT JOIN categories c ON s.category_id = c.id
+		LEFT JOIN users u ON e.user_id = u.id
+		LEFT JOIN payees p ON e.payee_id = p.id
+		LEFT JOIN accounts acc ON e.account_id = acc.id
+	`, rowDim.key, rowDim.label, colDim.key, colDim.label)
+
+	// Tags are many-to-many, so only join them when asked for; an expense
+	// with two tags then counts once under each.
+	if rowsDimension == "tag" || columnsDimension == "tag" {
+		query += `
+		LEFT JOIN expense_tags et ON et.expense_id = e.id
+		LEFT JOIN tags t ON t.id = et.tag_id
+	`
+	}
+
+	conditions, args := filter.conditions()
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += " GROUP BY 1, 2, 3, 4"
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return report, fmt.Errorf("failed to query pivot: %v", err)
+	}
+	defer rows.Close()
+
+	type cellKey struct{ row, column string }
+	cells := make(map[cellKey]PivotCell)
+	rowHeaders := make(map[string]PivotHeader)
+	columnHeaders := make(map[string]PivotHeader)
+
+	for rows.Next() {
+		var row, column PivotHeader
+		var cell PivotCell
+
+		if err := rows.Scan(&row.Key, &row.Label, &column.Key, &column.Label, &cell.Total, &cell.Count); err != nil {
+			return report, fmt.Errorf("failed to scan pivot row: %v", err)
+		}
+
+		rowHeaders[row.Key] = row
+		columnHeaders[column.Key] = column
+		cells[cellKey{row.Key, column.Key}] = cell
+	}
+
+	if err = rows.Err(); err != nil {
+		return report, fmt.Errorf("error iterating over pivot rows: %v", err)
+	}
+
+	report.Columns = make([]PivotHeader, 0, len(columnHeaders))
+	for _, header := range columnHeaders {
+		report.Columns = append(report.Columns, header)
+	}
+	sortPivotHeaders(report.Columns, columnsDimension)
+
+	headers := make([]PivotHeader, 0, len(rowHeaders))
+	for _, header := range rowHeaders {
+		headers = append(headers, header)
+	}
+	sortPivotHeaders(headers, rowsDimension)
+
+	report.ColumnTotals = make([]PivotCell, len(report.Columns))
+	report.Rows = make([]PivotRow, 0, len(headers))
+
+	for _, header := range headers {
+		row := PivotRow{PivotHeader: header, Cells: make([]PivotCell, len(report.Columns))}
+		for j, column := range report.Columns {
+			cell := cells[cellKey{header.Key, column.Key}]
+			row.Cells[j] = cell
+			row.Total += cell.Total
+			row.Count += cell.Count
+			report.ColumnTotals[j].Total += cell.Total
+			report.ColumnTotals[j].Count += cell.Count
+		}
+		report.Total += row.Total
+		report.Count += row.Count
+		report.Rows = append(report.Rows, row)
+	}
+
+	return report, nil
+}
+
+func writePivotCSV(w http.ResponseWriter, report PivotReport, value string) {
+	format := func(cell PivotCell) string {
+		if value == "count" {
+			return strconv.Itoa(cell.Count)
+		}
+		return strconv.FormatFloat(cell.Total, 'f', 2, 64)
+	}
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"pivot-%s-%s.csv\"", report.RowsDimension, report.ColumnsDimension))
+
+	writer := csv.NewWriter(w)
+
+	header := []string{report.RowsDimension + " / " + report.ColumnsDimension}
+	for _, column := range report.Columns {
+		header = append(header, column.Label)
+	}
+	writer.Write(append(header, "Total"))
+
+	for _, row := range report.Rows {
+		record := []string{row.Label}
+		for _, cell := range row.Cells {
+			record = append(record, format(cell))
+		}
+		writer.Write(append(record, format(row.PivotCell)))
+	}
+
+	totals := []string{"Total"}
+	for _, cell := range report.ColumnTotals {
+		totals = append(totals, format(cell))
+	}
+	writer.Write(append(totals, format(report.PivotCell)))
+
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		logger.Error(fmt.Sprintf("Failed to write pivot CSV: %v", err))
+	}
+}
+
+func pivotReportHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	rowsDimension := r.URL.Query().Get("rows")
+	columnsDimension := r.URL.Query().Get("columns")
+	value := r.URL.Query().Get("value")
+
+	if _, ok := pivotDimensions[rowsDimension]; !ok {
+		http.Error(w, fmt.Sprintf("Invalid rows parameter. Must be one of %s", pivotDimensionNames()), http.StatusBadRequest)
+		return
+	}
+
+	if _, ok := pivotDimensions[columnsDimension]; !ok {
+		http.Error(w, fmt.Sprintf("Invalid columns parameter. Must be one of %s", pivotDimensionNames()), http.StatusBadRequest)
+		return
+	}
+
+	if rowsDimension == columnsDimension {
+		http.Error(w, "rows and columns must be different dimensions", http.StatusBadRequest)
+		return
+	}
+
+	switch value {
+	case "", "total", "count":
+	default:
+		http.Error(w, "Invalid value parameter. Must be 'total' or 'count'", http.StatusBadRequest)
+		return
+	}
+
+	filter, err := parseExpenseFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	report, err := buildPivotReport(filter, rowsDimension, columnsDimension)
+	if err != nil {
+		logger.Error(err.Error())
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	if wantsCSV(r) {
+		writePivotCSV(w, report, value)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
@@ -0,0 +1,606 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	receiptWorkerCount       = 2
+	receiptExtractionTimeout = 2 * time.Minute
+	receiptJobPollInterval   = 30 * time.Second
+)
+
+// Receipt job statuses. Extraction moves a job from queued through running
+// to succeeded or failed; confirming it turns the draft into an expense.
+const (
+	receiptJobQueued    = "queued"
+	receiptJobRunning   = "running"
+	receiptJobSucceeded = "succeeded"
+	receiptJobFailed    = "failed"
+	receiptJobConfirmed = "confirmed"
+)
+
+type ReceiptDraft struct {
+	Amount        *float64 `json:"amount"`
+	Date          *string  `json:"date"`
+	Merchant      *string  `json:"merchant"`
+	SubcategoryID *int     `json:"subcategory_id"`
+}
+
+type ReceiptJob struct {
+	ID          int          `json:"id"`
+	UserID      *int         `json:"user_id"`
+	Filename    string       `json:"filename"`
+	ContentType string       `json:"content_type"`
+	Size        int64        `json:"size"`
+	Status      string       `json:"status"`
+	Extractor   *string      `json:"extractor"`
+	Error       *string      `json:"error"`
+	Draft       ReceiptDraft `json:"draft"`
+	Text        *string      `json:"text,omitempty"`
+	ExpenseID   *int         `json:"expense_id"`
+	CreatedAt   string       `json:"created_at"`
+	UpdatedAt   string       `json:"updated_at"`
+	StorageKey  string       `json:"-"`
+	HouseholdID int          `json:"-"`
+}
+
+type ConfirmReceiptRequest struct {
+	Amount        *float64 `json:"amount"`
+	SubcategoryID *int     `json:"subcategory_id"`
+	UserID        *int     `json:"user_id"`
+	Note          *string  `json:"note"`
+	Date          *string  `json:"date"`
+	PayeeID       *int     `json:"payee_id"`
+	AccountID     *int     `json:"account_id"`
+	Tags          []string `json:"tags"`
+}
+
+var receiptJobWake = make(chan struct{}, 1)
+
+const receiptJobColumns = `
+	id, user_id, filename, content_type, size, status, extractor, error,
+	amount, receipt_date, merchant, subcategory_id, raw_text, expense_id,
+	created_at, updated_at, storage_key, household_id
+`
+
+func scanReceiptJob(scanner interface{ Scan(...interface{}) error }) (ReceiptJob, error) {
+	var job ReceiptJob
+	var userID, subcategoryID, expenseID sql.NullInt64
+	var extractor, jobError, merchant, text sql.NullString
+	var amount sql.NullFloat64
+	var receiptDate sql.NullTime
+
+	err := scanner.Scan(&job.ID, &userID, &job.Filename, &job.ContentType, &job.Size, &job.Status, &extractor, &jobError,
+		&amount, &receiptDate, &merchant, &subcategoryID, &text, &expenseID,
+		&job.CreatedAt, &job.UpdatedAt, &job.StorageKey, &job.HouseholdID)
+	if err != nil {
+		return job, err
+	}
+
+	job.UserID = nullableInt(userID)
+	job.Draft.SubcategoryID = nullableInt(subcategoryID)
+	job.ExpenseID = nullableInt(expenseID)
+	if extractor.Valid {
+		job.Extractor = &extractor.String
+	}
+	if jobError.Valid {
+		job.Error = &jobError.String
+	}
+	if merchant.Valid {
+		job.Draft.Merchant = &merchant.String
+	}
+	if text.Valid {
+		job.Text = &text.String
+	}
+	if amount.Valid {
+		job.Draft.Amount = &amount.Float64
+	}
+	if receiptDate.Valid {
+		date := receiptDate.Time.Format("2006-01-02")
+		job.Draft.Date = &date
+	}
+
+	return job, nil
+}
+
+func nullableInt(value sql.NullInt64) *int {
+	if !value.Valid {
+		return nil
+	}
+	result := int(value.Int64)
+	return &result
+}
+
+func getReceiptJob(jobID int) (*ReceiptJob, error) {
+	row := db.QueryRow("SELECT "+receiptJobColumns+" FROM receipt_jobs WHERE id = ?", jobID)
+
+	job, err := scanReceiptJob(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query receipt job: %v", err)
+	}
+
+	return &job, nil
+}
+
+func wakeReceiptWorkers() {
+	select {
+	case receiptJobWake <- struct{}{}:
+	default:
+	}
+}
+
+// startReceiptWorkers puts jobs interrupted by a restart back in the queue
+// and starts the workers that drain it.
+func startReceiptWorkers() error {
+	if _, err := db.Exec("UPDATE receipt_jobs SET status = ? WHERE status = ?", receiptJobQueued, receiptJobRunning); err != nil {
+		return fmt.Errorf("failed to requeue receipt jobs: %v", err)
+	}
+
+	for i := 0; i < receiptWorkerCount; i++ {
+		go receiptWorker()
+	}
+
+	return nil
+}
+
+func receiptWorker() {
+	for {
+		jobID, err := claimReceiptJob()
+		if err != nil {
+			logger.Error(err.Error())
+		}
+
+		if jobID == 0 {
+			select {
+			case <-receiptJobWake:
+			case <-time.After(receiptJobPollInterval):
+			}
+			continue
+		}
+
+		runReceiptJob(jobID)
+	}
+}
+
+// claimReceiptJob marks the oldest queued job as running and returns its ID,
+// or 0 when the queue is empty. LAST_INSERT_ID(id) hands the claimed row's ID
+// back through the UPDATE so two workers never claim the same job.
+func claimReceiptJob() (int, error) {
+	result, err := db.Exec(`
+		UPDATE receipt_jobs
+		SET status = ?, id = LAST_INSERT_ID(id)
+		WHERE status = ?
+		ORDER BY id
+		LIMIT 1
+	`, receiptJobRunning, receiptJobQueued)
+	if err != nil {
+		return 0, fmt.Errorf("failed to claim receipt job: %v", err)
+	}
+
+	claimed, err := result.RowsAffected()
+	if err != nil || claimed == 0 {
+		return 0, err
+	}
+
+	jobID, err := result.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get claimed receipt job ID: %v", err)
+	}
+
+	return int(jobID), nil
+}
+
+func runReceiptJob(jobID int) {
+	job, err := getReceiptJob(jobID)
+	if err != nil || job == nil {
+		logger.Error(fmt.Sprintf("Failed to load receipt job %d: %v", jobID, err))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), receiptExtractionTimeout)
+	defer cancel()
+
+	fields, extractErr := extractReceipt(ctx, job)
+	if extractErr != nil {
+		logger.Error(fmt.Sprintf("Receipt job %d failed: %v", jobID, extractErr))
+		_, err := db.Exec(`
+			UPDATE receipt_jobs SET status = ?, extractor = ?, error = ?
+			WHERE id = ? AND status = ?
+		`, receiptJobFailed, receiptExtractor.Name(), extractErr.Error(), jobID, receiptJobRunning)
+		if err != nil {
+			logger.Error(fmt.Sprintf("Failed to record receipt job %d failure: %v", jobID, err))
+		}
+		return
+	}
+
+	var subcategoryID *int
+	if fields.Amount != nil {
+		rules, err := loadCategorizationRules(job.HouseholdID)
+		if err != nil {
+			logger.Error(err.Error())
+		} else {
+			req := CreateExpenseRequest{Amount: *fields.Amount, UserID: job.UserID, Note: fields.Merchant}
+			categorizeExpenseRequest(rules, &req)
+			subcategoryID = req.SubcategoryID
+		}
+	}
+
+	_, err = db.Exec(`
+		UPDATE receipt_jobs
+		SET status = ?, extractor = ?, error = NULL, amount = ?, receipt_date = ?, merchant = ?, subcategory_id = ?, raw_text = ?
+		WHERE id = ? AND status = ?
+	`, receiptJobSucceeded, receiptExtractor.Name(), fields.Amount, fields.Date, fields.Merchant, subcategoryID, fields.Text, jobID, receiptJobRunning)
+	if err != nil {
+		logger.Error(fmt.Sprintf("Failed to save receipt job %d result: %v", jobID, err))
+		return
+	}
+
+	logger.Info(fmt.Sprintf("Receipt job %d finished", jobID))
+}
+
+func extractReceipt(ctx context.Context, job *ReceiptJob) (ReceiptFields, error) {
+	body, err := blobStorage.Get(ctx, job.StorageKey)
+	if err != nil {
+		return ReceiptFields{}, fmt.Errorf("failed to read receipt: %v", err)
+	}
+	defer body.Close()
+
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return ReceiptFields{}, fmt.Errorf("failed to read receipt: %v", err)
+	}
+
+	return receiptExtractor.Extract(ctx, data, job.ContentType)
+}
+
+func receiptsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet {
+		listReceiptJobsHandler(w, r)
+	} else if r.Method == http.MethodPost {
+		uploadReceiptHandler(w, r)
+	} else {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func singleReceiptHandler(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, "/api/v1/receipts/")
+	confirm := strings.HasSuffix(path, "/confirm")
+	path = strings.TrimSuffix(path, "/confirm")
+
+	jobID, err := strconv.Atoi(path)
+	if err != nil {
+		http.Error(w, "Invalid receipt ID", http.StatusBadRequest)
+		return
+	}
+
+	job, err := getReceiptJob(jobID)
+	if err != nil {
+		logger.Error(err.Error())
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if job == nil || job.HouseholdID != householdID(r) {
+		http.Error(w, "Receipt not found", http.StatusNotFound)
+		return
+	}
+
+	if confirm {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		confirmReceiptHandler(w, r, job)
+	} else if r.Method == http.MethodGet {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(job)
+	} else if r.Method == http.MethodDelete {
+		deleteReceiptHandler(w, r, job)
+	} else {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func uploadReceiptHandler(w http.ResponseWriter, r *http.Request) {
+	data, filename, contentType, ok := readAttachmentUpload(w, r)
+	if !ok {
+		return
+	}
+
+	userID, err := parseOptionalIntField(r.FormValue("user_id"), "user_id")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if writeRefError(w, checkHouseholdRefs(householdID(r), householdRef{"user_id", "users", userID})) {
+		return
+	}
+
+	key, err := newBlobKey("receipts")
+	if err != nil {
+		logger.Error(err.Error())
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	if err := blobStorage.Put(r.Context(), key, data, contentType); err != nil {
+		logger.Error(fmt.Sprintf("Failed to store receipt: %v", err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	result, err := db.Exec(`
+		INSERT INTO receipt_jobs (household_id, user_id, filename, content_type, size, storage_key, status)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`, householdID(r), userID, filename, contentType, len(data), key, receiptJobQueued)
+	if err != nil {
+		logger.Error(fmt.Sprintf("Failed to create receipt job: %v", err))
+		if err := blobStorage.Delete(r.Context(), key); err != nil {
+			logger.Error(fmt.Sprintf("Failed to remove orphaned receipt %s: %v", key, err))
+		}
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	jobID, err := result.LastInsertId()
+	if err != nil {
+		logger.Error(fmt.Sprintf("Failed to get receipt job ID: %v", err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	wakeReceiptWorkers()
+
+	job, err := getReceiptJob(int(jobID))
+	if err != nil || job == nil {
+		logger.Error(fmt.Sprintf("Failed to load created receipt job: %v", err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", fmt.Sprintf("/api/v1/receipts/%d", job.ID))
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(job)
+}
+
+func listReceiptJobsHandler(w http.ResponseWriter, r *http.Request) {
+	status := r.URL.Query().Get("status")
+	userIDStr := r.URL.Query().Get("user_id")
+
+	query := "SELECT " + receiptJobColumns + " FROM receipt_jobs"
+	conditions := []string{"household_id = ?"}
+	args := []interface{}{householdID(r)}
+
+	if status != "" {
+		switch status {
+		case receiptJobQueued, receiptJobRunning, receiptJobSucceeded, receiptJobFailed, receiptJobConfirmed:
+		default:
+			http.Error(w, "Invalid status parameter", http.StatusBadRequest)
+			return
+		}
+		conditions = append(conditions, "status = ?")
+		args = append(args, status)
+	}
+
+	if userIDStr != "" {
+		userID, err := strconv.Atoi(userIDStr)
+		if err != nil {
+			http.Error(w, "Invalid user_id parameter", http.StatusBadRequest)
+			return
+		}
+		if userID == 0 {
+			conditions = append(conditions, "user_id IS NULL")
+		} else {
+			conditions = append(conditions, "user_id = ?")
+			args = append(args, userID)
+		}
+	}
+
+	query += " WHERE " + strings.Join(conditions, " AND ")
+	query += " ORDER BY id DESC LIMIT 100"
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		logger.Error(fmt.Sprintf("Failed to query receipt jobs: %v", err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	jobs := []ReceiptJob{}
+	for rows.Next() {
+		job, err := scanReceiptJob(rows)
+		if err != nil {
+			logger.Error(fmt.Sprintf("Failed to scan receipt job: %v", err))
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		job.Text = nil
+		jobs = append(jobs, job)
+	}
+
+	if err := rows.Err(); err != nil {
+		logger.Error(fmt.Sprintf("Error iterating receipt jobs: %v", err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(jobs)
+}
+
+func confirmReceiptHandler(w http.ResponseWriter, r *http.Request, job *ReceiptJob) {
+	switch job.Status {
+	case receiptJobQueued, receiptJobRunning:
+		http.Error(w, "Receipt is still being processed", http.StatusConflict)
+		return
+	case receiptJobConfirmed:
+		http.Error(w, "Receipt has already been confirmed", http.StatusConflict)
+		return
+	}
+
+	var req ConfirmReceiptRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+			logger.Error(fmt.Sprintf("Failed to decode receipt confirmation: %v", err))
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Anything the user did not send falls back to the extracted draft.
+	expense := CreateExpenseRequest{SubcategoryID: req.SubcategoryID, UserID: req.UserID, Note: req.Note, PayeeID: req.PayeeID, AccountID: req.AccountID, Tags: req.Tags}
+	if req.Amount != nil {
+		expense.Amount = *req.Amount
+	} else if job.Draft.Amount != nil {
+		expense.Amount = *job.Draft.Amount
+	}
+	if expense.SubcategoryID == nil {
+		expense.SubcategoryID = job.Draft.SubcategoryID
+	}
+	if expense.UserID == nil {
+		expense.UserID = job.UserID
+	}
+	if expense.Note == nil {
+		expense.Note = job.Draft.Merchant
+	}
+
+	if err := validateCreateExpenseRequest(expense); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if writeRefError(w, checkHouseholdRefs(job.HouseholdID, expenseRefs(expense)...)) {
+		return
+	}
+
+	matchers, err := loadPayeeMatchers(job.HouseholdID)
+	if err != nil {
+		logger.Error(err.Error())
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	suggestPayeeForRequest(matchers, &expense)
+
+	dateStr := req.Date
+	if dateStr == nil {
+		dateStr = job.Draft.Date
+	}
+	var createdAt *time.Time
+	if dateStr != nil {
+		date, err := time.Parse("2006-01-02", *dateStr)
+		if err != nil {
+			http.Error(w, "date must be in YYYY-MM-DD format", http.StatusBadRequest)
+			return
+		}
+		createdAt = &date
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		logger.Error(fmt.Sprintf("Failed to begin receipt transaction: %v", err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	expenseID, err := insertExpense(tx, job.HouseholdID, expense, createdAt)
+	if err != nil {
+		logger.Error(err.Error())
+		http.Error(w, "Expense could not be saved; check subcategory_id, user_id, payee_id and account_id", http.StatusBadRequest)
+		return
+	}
+
+	result, err := tx.Exec(`
+		INSERT INTO expense_attachments (expense_id, filename, content_type, size, storage_key)
+		VALUES (?, ?, ?, ?, ?)
+	`, expenseID, job.Filename, job.ContentType, job.Size, job.StorageKey)
+	if err != nil {
+		logger.Error(fmt.Sprintf("Failed to attach receipt: %v", err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	attachmentID, err := result.LastInsertId()
+	if err != nil {
+		logger.Error(fmt.Sprintf("Failed to get attachment ID: %v", err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	result, err = tx.Exec(`
+		UPDATE receipt_jobs SET status = ?, expense_id = ?
+		WHERE id = ? AND status IN (?, ?)
+	`, receiptJobConfirmed, expenseID, job.ID, receiptJobSucceeded, receiptJobFailed)
+	if err != nil {
+		logger.Error(fmt.Sprintf("Failed to update receipt job: %v", err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if updated, _ := result.RowsAffected(); updated == 0 {
+		http.Error(w, "Receipt has already been confirmed", http.StatusConflict)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		logger.Error(fmt.Sprintf("Failed to commit receipt transaction: %v", err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	invalidateExpenseCaches()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message":        "Expense created from receipt",
+		"expense_id":     expenseID,
+		"attachment_id":  attachmentID,
+		"amount":         expense.Amount,
+		"subcategory_id": expense.SubcategoryID,
+		"user_id":        expense.UserID,
+		"note":           expense.Note,
+	})
+}
+
+func deleteReceiptHandler(w http.ResponseWriter, r *http.Request, job *ReceiptJob) {
+	if job.Status == receiptJobConfirmed {
+		http.Error(w, "Receipt has been confirmed; delete the expense attachment instead", http.StatusConflict)
+		return
+	}
+
+	result, err := db.Exec("DELETE FROM receipt_jobs WHERE id = ? AND status <> ?", job.ID, receiptJobConfirmed)
+	if err != nil {
+		logger.Error(fmt.Sprintf("Failed to delete receipt job: %v", err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if deleted, _ := result.RowsAffected(); deleted == 0 {
+		http.Error(w, "Receipt has been confirmed; delete the expense attachment instead", http.StatusConflict)
+		return
+	}
+
+	if err := blobStorage.Delete(r.Context(), job.StorageKey); err != nil {
+		logger.Error(fmt.Sprintf("Failed to remove receipt %s from storage: %v", job.StorageKey, err))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "Receipt deleted successfully",
+		"id":      job.ID,
+	})
+}
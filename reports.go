@@ -0,0 +1,507 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+	_ "time/tzdata"
+)
+
+const maxTimeseriesBuckets = 1000
+
+type TimeseriesBucket struct {
+	Label string  `json:"label"`
+	Start string  `json:"start"`
+	End   string  `json:"end"`
+	Total float64 `json:"total"`
+	Count int     `json:"count"`
+}
+
+type TimeseriesSeries struct {
+	ID     int       `json:"id"`
+	Name   string    `json:"name"`
+	Total  float64   `json:"total"`
+	Totals []float64 `json:"totals"`
+	Counts []int     `json:"counts"`
+}
+
+type TimeseriesReport struct {
+	Interval string             `json:"interval"`
+	SplitBy  string             `json:"split_by,omitempty"`
+	Timezone string             `json:"timezone"`
+	DateFrom string             `json:"date_from"`
+	DateTo   string             `json:"date_to"`
+	Total    float64            `json:"total"`
+	Buckets  []TimeseriesBucket `json:"buckets"`
+	Series   []TimeseriesSeries `json:"series,omitempty"`
+}
+
+func wantsCSV(r *http.Request) bool {
+	if r.URL.Query().Get("format") == "csv" {
+		return true
+	}
+	return strings.Contains(r.Header.Get("Accept"), "text/csv")
+}
+
+func truncateToInterval(t time.Time, interval string) time.Time {
+	year, month, day := t.Date()
+	loc := t.Location()
+
+	switch interval {
+	case "day":
+		return time.Date(year, month, day, 0, 0, 0, 0, loc)
+	case "week":
+		offset := (int(t.Weekday()) + 6) % 7
+		return time.Date(year, month, day-offset, 0, 0, 0, 0, loc)
+	case "month":
+		return time.Date(year, month, 1, 0, 0, 0, 0, loc)
+	case "quarter":
+		return time.Date(year, ((month-1)/3)*3+1, 1, 0, 0, 0, 0, loc)
+	default:
+		return time.Date(year, time.January, 1, 0, 0, 0, 0, loc)
+	}
+}
+
+func nextInterval(t time.Time, interval string) time.Time {
+	switch interval {
+	case "day":
+		return t.AddDate(0, 0, 1)
+	case "week":
+		return t.AddDate(0, 0, 7)
+	case "month":
+		return t.AddDate(0, 1, 0)
+	case "quarter":
+		return t.AddDate(0, 3, 0)
+	default:
+		return t.AddDate(1, 0, 0)
+	}
+}
+
+func intervalLabel(t time.Time, interval string) string {
+	switch interval {
+	case "day":
+		return t.Format("2006-01-02")
+	case "week":
+		year, week := t.ISOWeek()
+		return fmt.Sprintf("%d-W%02d", year, week)
+	case "month":
+		return t.Format("2006-01")
+	case "quarter":
+		return fmt.Sprintf("%d-Q%d", t.Year(), (int(t.Month())-1)/3+1)
+	default:
+		return t.Format("2006")
+	}
+}
+
+func defaultTimeseriesFrom(to time.Time, interval string) time.Time {
+	switch interval {
+	case "day":
+		return to.AddDate(0, 0, -29)
+	case "week":
+		return to.AddDate(0, 0, -7*11)
+	case "month":
+		return to.AddDate(0, -11, 0)
+	case "quarter":
+		return to.AddDate(0, -3*7, 0)
+	default:
+		return to.AddDate(-4, 0, 0)
+	}
+}
+
+// timeseriesRange is the bucketing shared by the reports that chart totals
+// over time: an interval and whole local days from From to To inclusive.
+type timeseriesRange struct {
+	Interval string
+	Location *time.Location
+	From     time.Time
+	To       time.Time
+}
+
+// parseTimeseriesRange reads interval and tz; the bounds come from the
+// filter's date_from and date_to, defaulting to a window ending today.
+func parseTimeseriesRange(query url.Values, filter ExpenseFilter) (timeseriesRange, error) {
+	tr := timeseriesRange{Interval: query.Get("interval")}
+
+	if tr.Interval == "" {
+		tr.Interval = "month"
+	}
+	switch tr.Interval {
+	case "day", "week", "month", "quarter", "year":
+	default:
+		return tr, fmt.Errorf("Invalid interval parameter. Must be 'day', 'week', 'month', 'quarter', or 'year'")
+	}
+
+	timezone := query.Get("tz")
+	if timezone == "" {
+		timezone = "UTC"
+	}
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		return tr, fmt.Errorf("Invalid tz parameter. Must be an IANA timezone name")
+	}
+	tr.Location = loc
+
+	now := time.Now().In(loc)
+	tr.To = time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+	if filter.DateTo != "" {
+		tr.To, _ = time.ParseInLocation("2006-01-02", filter.DateTo, loc)
+	}
+	tr.From = defaultTimeseriesFrom(tr.To, tr.Interval)
+	if filter.DateFrom != "" {
+		tr.From, _ = time.ParseInLocation("2006-01-02", filter.DateFrom, loc)
+	}
+	if tr.From.After(tr.To) {
+		return tr, fmt.Errorf("date_from must not be after date_to")
+	}
+
+	return tr, nil
+}
+
+// end is the exclusive upper bound of the range.
+func (tr timeseriesRange) end() time.Time {
+	return tr.To.AddDate(0, 0, 1)
+}
+
+// buckets lays out the empty buckets and indexes them by start time.
+func (tr timeseriesRange) buckets() ([]TimeseriesBucket, map[int64]int, error) {
+	var buckets []TimeseriesBucket
+	bucketIndex := make(map[int64]int)
+	for start := truncateToInterval(tr.From, tr.Interval); start.Before(tr.end()); start = nextInterval(start, tr.Interval) {
+		if len(buckets) >= maxTimeseriesBuckets {
+			return nil, nil, fmt.Errorf("Date range produces more than %d buckets. Use a larger interval", maxTimeseriesBuckets)
+		}
+		bucketIndex[start.Unix()] = len(buckets)
+		buckets = append(buckets, TimeseriesBucket{
+			Label: intervalLabel(start, tr.Interval),
+			Start: start.Format(time.RFC3339),
+			End:   nextInterval(start, tr.Interval).Format(time.RFC3339),
+		})
+	}
+	return buckets, bucketIndex, nil
+}
+
+func (tr timeseriesRange) bucketOf(t time.Time, bucketIndex map[int64]int) (int, bool) {
+	i, ok := bucketIndex[truncateToInterval(t.In(tr.Location), tr.Interval).Unix()]
+	return i, ok
+}
+
+func timeseriesReportHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	splitBy := r.URL.Query().Get("split_by")
+
+	switch splitBy {
+	case "", "category", "subcategory", "user":
+	default:
+		http.Error(w, "Invalid split_by parameter. Must be 'category', 'subcategory', or 'user'", http.StatusBadRequest)
+		return
+	}
+
+	filter, err := parseExpenseFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	tr, err := parseTimeseriesRange(r.URL.Query(), filter)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	buckets, bucketIndex, err := tr.buckets()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	splitColumns := "0, ''"
+	switch splitBy {
+	case "category":
+		splitColumns = "COALESCE(c.id, 0), COALESCE(c.name, 'Uncategorized')"
+	case "subcategory":
+		splitColumns = "COALESCE(s.id, 0), COALESCE(s.name, 'Uncategorized')"
+	case "user":
+		splitColumns = "COALESCE(e.user_id, 0), COALESCE(u.display_name, 'Unknown User')"
+	}
+
+	query := fmt.Sprintf(`
+		SELECT e.created_at, e.amount, %s
+		FROM expenses e
+		LEFT JOIN subcategories s ON e.subcategory_id = s.id
+		LEFT JOIN categories c ON s.category_id = c.id
+		LEFT JOIN users u ON e.user_id = u.id
+	`, splitColumns)
+
+	filter.DateFrom = ""
+	filter.DateTo = ""
+	conditions, args := filter.conditions()
+	// Bounds are whole local days; the query compares against absolute instants
+	// so bucket edges follow the requested timezone rather than the database's.
+	conditions = append([]string{"e.created_at >= ?", "e.created_at < ?"}, conditions...)
+	args = append([]interface{}{tr.From.UTC(), tr.end().UTC()}, args...)
+
+	query += " WHERE " + strings.Join(conditions, " AND ")
+	query += " ORDER BY e.created_at"
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		logger.Error(fmt.Sprintf("Failed to query timeseries expenses: %v", err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	report := TimeseriesReport{
+		Interval: tr.Interval,
+		SplitBy:  splitBy,
+		Timezone: tr.Location.String(),
+		DateFrom: tr.From.Format("2006-01-02"),
+		DateTo:   tr.To.Format("2006-01-02"),
+	}
+
+	seriesIndex := make(map[int]int)
+
+	for rows.Next() {
+		var createdAt time.Time
+		var amount float64
+		var groupID int
+		var groupName string
+
+		if err := rows.Scan(&createdAt, &amount, &groupID, &groupName); err != nil {
+			logger.Error(fmt.Sprintf("Failed to scan timeseries row: %v", err))
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		i, ok := tr.bucketOf(createdAt, bucketIndex)
+		if !ok {
+			continue
+		}
+
+		buckets[i].Total += amount
+		buckets[i].Count++
+		report.Total += amount
+
+		if splitBy == "" {
+			continue
+		}
+
+		j, ok := seriesIndex[groupID]
+		if !ok {
+			j = len(report.Series)
+			seriesIndex[groupID] = j
+			report.Series = append(report.Series, TimeseriesSeries{
+				ID:     groupID,
+				Name:   groupName,
+				Totals: make([]float64, len(buckets)),
+				Counts: make([]int, len(buckets)),
+			})
+		}
+		report.Series[j].Totals[i] += amount
+		report.Series[j].Counts[i]++
+		report.Series[j].Total += amount
+	}
+
+	if err = rows.Err(); err != nil {
+		logger.Error(fmt.Sprintf("Error iterating over timeseries rows: %v", err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	report.Buckets = buckets
+	if report.Buckets == nil {
+		report.Buckets = []TimeseriesBucket{}
+	}
+	sort.Slice(report.Series, func(a, b int) bool {
+		return report.Series[a].Total > report.Series[b].Total
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
+
+type ComparisonPeriod struct {
+	DateFrom string  `json:"date_from"`
+	DateTo   string  `json:"date_to"`
+	Total    float64 `json:"total"`
+}
+
+type ComparisonRow struct {
+	ID           int      `json:"id"`
+	Name         string   `json:"name"`
+	Current      float64  `json:"current"`
+	Previous     float64  `json:"previous"`
+	Delta        float64  `json:"delta"`
+	DeltaPercent *float64 `json:"delta_percent"`
+	Status       string   `json:"status"`
+}
+
+type ComparisonReport struct {
+	Current       ComparisonPeriod `json:"current"`
+	Previous      ComparisonPeriod `json:"previous"`
+	Delta         float64          `json:"delta"`
+	DeltaPercent  *float64         `json:"delta_percent"`
+	Categories    []ComparisonRow  `json:"categories"`
+	Subcategories []ComparisonRow  `json:"subcategories"`
+}
+
+func percentChange(current, previous float64) *float64 {
+	if previous == 0 {
+		return nil
+	}
+	change := (current - previous) / previous * 100
+	return &change
+}
+
+func compareExpenseGroups(current, previous []ExpenseGroup) []ComparisonRow {
+	rows := []ComparisonRow{}
+	index := make(map[int]int)
+
+	for _, group := range current {
+		index[group.ID] = len(rows)
+		rows = append(rows, ComparisonRow{ID: group.ID, Name: group.Name, Current: group.Total})
+	}
+
+	for _, group := range previous {
+		if i, ok := index[group.ID]; ok {
+			rows[i].Previous = group.Total
+			continue
+		}
+		index[group.ID] = len(rows)
+		rows = append(rows, ComparisonRow{ID: group.ID, Name: group.Name, Previous: group.Total})
+	}
+
+	for i := range rows {
+		rows[i].Delta = rows[i].Current - rows[i].Previous
+		rows[i].DeltaPercent = percentChange(rows[i].Current, rows[i].Previous)
+
+		switch {
+		case rows[i].Previous == 0:
+			rows[i].Status = "new"
+		case rows[i].Current == 0:
+			rows[i].Status = "disappeared"
+		default:
+			rows[i].Status = "continuing"
+		}
+	}
+
+	sort.SliceStable(rows, func(a, b int) bool {
+		return math.Abs(rows[a].Delta) > math.Abs(rows[b].Delta)
+	})
+
+	return rows
+}
+
+func parseComparisonRange(query url.Values, prefix string) (string, string, error) {
+	from := query.Get(prefix + "_from")
+	to := query.Get(prefix + "_to")
+
+	if from == "" || to == "" {
+		return "", "", fmt.Errorf("%s_from and %s_to parameters are required", prefix, prefix)
+	}
+
+	fromDate, err := time.Parse("2006-01-02", from)
+	if err != nil {
+		return "", "", fmt.Errorf("Invalid %s_from parameter. Must be in YYYY-MM-DD format", prefix)
+	}
+
+	toDate, err := time.Parse("2006-01-02", to)
+	if err != nil {
+		return "", "", fmt.Errorf("Invalid %s_to parameter. Must be in YYYY-MM-DD format", prefix)
+	}
+
+	if fromDate.After(toDate) {
+		return "", "", fmt.Errorf("%s_from must not be after %s_to", prefix, prefix)
+	}
+
+	return from, to, nil
+}
+
+func comparisonReportHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+
+	currentFrom, currentTo, err := parseComparisonRange(query, "current")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	previousFrom, previousTo, err := parseComparisonRange(query, "previous")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	filter, err := parseExpenseFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	currentFilter := filter
+	currentFilter.DateFrom = currentFrom
+	currentFilter.DateTo = currentTo
+
+	previousFilter := filter
+	previousFilter.DateFrom = previousFrom
+	previousFilter.DateTo = previousTo
+
+	report := ComparisonReport{
+		Current:  ComparisonPeriod{DateFrom: currentFrom, DateTo: currentTo},
+		Previous: ComparisonPeriod{DateFrom: previousFrom, DateTo: previousTo},
+	}
+
+	if report.Current.Total, err = queryExpenseTotal(currentFilter); err != nil {
+		logger.Error(err.Error())
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	if report.Previous.Total, err = queryExpenseTotal(previousFilter); err != nil {
+		logger.Error(err.Error())
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	report.Delta = report.Current.Total - report.Previous.Total
+	report.DeltaPercent = percentChange(report.Current.Total, report.Previous.Total)
+
+	for _, groupBy := range []string{"category", "subcategory"} {
+		current, err := queryExpenseGroups(currentFilter, groupBy, "DESC")
+		if err != nil {
+			logger.Error(err.Error())
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		previous, err := queryExpenseGroups(previousFilter, groupBy, "DESC")
+		if err != nil {
+			logger.Error(err.Error())
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		if groupBy == "category" {
+			report.Categories = compareExpenseGroups(current, previous)
+		} else {
+			report.Subcategories = compareExpenseGroups(current, previous)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
@@ -0,0 +1,519 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+type CategorizationRule struct {
+	ID            int      `json:"id"`
+	Name          string   `json:"name"`
+	SubcategoryID int      `json:"subcategory_id"`
+	NoteContains  *string  `json:"note_contains"`
+	NoteRegex     *string  `json:"note_regex"`
+	MinAmount     *float64 `json:"min_amount"`
+	MaxAmount     *float64 `json:"max_amount"`
+	UserID        *int     `json:"user_id"`
+	Priority      int      `json:"priority"`
+
+	pattern *regexp.Regexp
+}
+
+type RuleMatch struct {
+	ExpenseID     int     `json:"expense_id"`
+	Amount        float64 `json:"amount"`
+	Note          *string `json:"note"`
+	UserID        *int    `json:"user_id"`
+	CreatedAt     string  `json:"created_at"`
+	RuleID        int     `json:"rule_id"`
+	RuleName      string  `json:"rule_name"`
+	SubcategoryID int     `json:"subcategory_id"`
+}
+
+func (rule *CategorizationRule) compile() error {
+	rule.pattern = nil
+	if rule.NoteRegex == nil || *rule.NoteRegex == "" {
+		return nil
+	}
+	pattern, err := regexp.Compile("(?i)" + *rule.NoteRegex)
+	if err != nil {
+		return err
+	}
+	rule.pattern = pattern
+	return nil
+}
+
+// matches reports whether every condition set on the rule holds. A rule
+// without conditions never matches.
+func (rule *CategorizationRule) matches(amount float64, note string, userID *int) bool {
+	matched := false
+
+	if rule.NoteContains != nil && *rule.NoteContains != "" {
+		if !strings.Contains(strings.ToLower(note), strings.ToLower(*rule.NoteContains)) {
+			return false
+		}
+		matched = true
+	}
+
+	if rule.pattern != nil {
+		if !rule.pattern.MatchString(note) {
+			return false
+		}
+		matched = true
+	}
+
+	if rule.MinAmount != nil {
+		if amount < *rule.MinAmount {
+			return false
+		}
+		matched = true
+	}
+
+	if rule.MaxAmount != nil {
+		if amount > *rule.MaxAmount {
+			return false
+		}
+		matched = true
+	}
+
+	if rule.UserID != nil {
+		if userID == nil || *userID != *rule.UserID {
+			return false
+		}
+		matched = true
+	}
+
+	return matched
+}
+
+func loadCategorizationRules(householdID int) ([]CategorizationRule, error) {
+	rows, err := db.Query(`
+		SELECT id, name, subcategory_id, note_contains, note_regex, min_amount, max_amount, user_id, priority
+		FROM categorization_rules
+		WHERE household_id = ?
+		ORDER BY priority DESC, id
+	`, householdID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query categorization rules: %v", err)
+	}
+	defer rows.Close()
+
+	var rules []CategorizationRule
+
+	for rows.Next() {
+		var rule CategorizationRule
+		if err := rows.Scan(&rule.ID, &rule.Name, &rule.SubcategoryID, &rule.NoteContains, &rule.NoteRegex, &rule.MinAmount, &rule.MaxAmount, &rule.UserID, &rule.Priority); err != nil {
+			return nil, fmt.Errorf("failed to scan categorization rule row: %v", err)
+		}
+		if err := rule.compile(); err != nil {
+			logger.Warning(fmt.Sprintf("Skipping categorization rule %d with invalid regex: %v", rule.ID, err))
+			continue
+		}
+		rules = append(rules, rule)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over categorization rule rows: %v", err)
+	}
+
+	return rules, nil
+}
+
+func matchCategorizationRule(rules []CategorizationRule, amount float64, note *string, userID *int) *CategorizationRule {
+	for i := range rules {
+		if rules[i].matches(amount, optionalString(note), userID) {
+			return &rules[i]
+		}
+	}
+	return nil
+}
+
+// categorizeExpenseRequest fills in subcategory_id from the first matching
+// rule when the request leaves it empty.
+func categorizeExpenseRequest(rules []CategorizationRule, req *CreateExpenseRequest) {
+	if req.SubcategoryID != nil {
+		return
+	}
+	if rule := matchCategorizationRule(rules, req.Amount, req.Note, req.UserID); rule != nil {
+		subcategoryID := rule.SubcategoryID
+		req.SubcategoryID = &subcategoryID
+	}
+}
+
+func validateCategorizationRule(householdID int, rule *CategorizationRule) (int, error) {
+	if len(strings.TrimSpace(rule.Name)) < 3 {
+		return http.StatusBadRequest, fmt.Errorf("Rule name must be at least 3 characters long")
+	}
+
+	if rule.SubcategoryID <= 0 {
+		return http.StatusBadRequest, fmt.Errorf("Valid subcategory_id is required")
+	}
+
+	if err := rule.compile(); err != nil {
+		return http.StatusBadRequest, fmt.Errorf("Invalid note_regex: %v", err)
+	}
+
+	if rule.MinAmount != nil && rule.MaxAmount != nil && *rule.MinAmount > *rule.MaxAmount {
+		return http.StatusBadRequest, fmt.Errorf("min_amount must not be greater than max_amount")
+	}
+
+	hasCondition := (rule.NoteContains != nil && *rule.NoteContains != "") ||
+		rule.pattern != nil || rule.MinAmount != nil || rule.MaxAmount != nil || rule.UserID != nil
+	if !hasCondition {
+		return http.StatusBadRequest, fmt.Errorf("At least one condition (note_contains, note_regex, min_amount, max_amount, user_id) is required")
+	}
+
+	exists, err := inHousehold(householdID, "subcategories", rule.SubcategoryID)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if !exists {
+		return http.StatusNotFound, fmt.Errorf("Subcategory not found")
+	}
+
+	err = checkHouseholdRefs(householdID, householdRef{"user_id", "users", rule.UserID})
+	if _, ok := err.(unknownRefError); ok {
+		return http.StatusBadRequest, err
+	} else if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	return 0, nil
+}
+
+func getRulesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	rules, err := loadCategorizationRules(householdID(r))
+	if err != nil {
+		logger.Error(err.Error())
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if rules == nil {
+		rules = []CategorizationRule{}
+	}
+	json.NewEncoder(w).Encode(rules)
+}
+
+func createRuleHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var rule CategorizationRule
+	if err := json.NewDecoder(r.Body).Decode(&rule); err != nil {
+		http.Error(w, "Invalid JSON body", http.StatusBadRequest)
+		return
+	}
+
+	if status, err := validateCategorizationRule(householdID(r), &rule); err != nil {
+		if status == http.StatusInternalServerError {
+			logger.Error(err.Error())
+			http.Error(w, "Internal server error", status)
+			return
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	result, err := db.Exec(`
+		INSERT INTO categorization_rules (household_id, name, subcategory_id, note_contains, note_regex, min_amount, max_amount, user_id, priority)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, householdID(r), rule.Name, rule.SubcategoryID, rule.NoteContains, rule.NoteRegex, rule.MinAmount, rule.MaxAmount, rule.UserID, rule.Priority)
+	if err != nil {
+		logger.Error(fmt.Sprintf("Failed to create categorization rule: %v", err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	ruleID, err := result.LastInsertId()
+	if err != nil {
+		logger.Error(fmt.Sprintf("Failed to get last insert ID: %v", err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	rule.ID = int(ruleID)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(rule)
+}
+
+func updateRuleHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut && r.Method != http.MethodPatch {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	ruleID, err := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/api/v1/rules/"))
+	if err != nil {
+		http.Error(w, "Invalid rule ID", http.StatusBadRequest)
+		return
+	}
+
+	var rule CategorizationRule
+	if err := json.NewDecoder(r.Body).Decode(&rule); err != nil {
+		http.Error(w, "Invalid JSON body", http.StatusBadRequest)
+		return
+	}
+	rule.ID = ruleID
+
+	if status, err := validateCategorizationRule(householdID(r), &rule); err != nil {
+		if status == http.StatusInternalServerError {
+			logger.Error(err.Error())
+			http.Error(w, "Internal server error", status)
+			return
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	var existingID int
+	err = db.QueryRow("SELECT id FROM categorization_rules WHERE id = ? AND household_id = ?", ruleID, householdID(r)).Scan(&existingID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Rule not found", http.StatusNotFound)
+			return
+		}
+		logger.Error(fmt.Sprintf("Failed to check rule existence: %v", err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	_, err = db.Exec(`
+		UPDATE categorization_rules
+		SET name = ?, subcategory_id = ?, note_contains = ?, note_regex = ?, min_amount = ?, max_amount = ?, user_id = ?, priority = ?
+		WHERE id = ?
+	`, rule.Name, rule.SubcategoryID, rule.NoteContains, rule.NoteRegex, rule.MinAmount, rule.MaxAmount, rule.UserID, rule.Priority, ruleID)
+	if err != nil {
+		logger.Error(fmt.Sprintf("Failed to update categorization rule: %v", err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(rule)
+}
+
+func deleteRuleHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	ruleID, err := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/api/v1/rules/"))
+	if err != nil {
+		http.Error(w, "Invalid rule ID", http.StatusBadRequest)
+		return
+	}
+
+	result, err := db.Exec("DELETE FROM categorization_rules WHERE id = ? AND household_id = ?", ruleID, householdID(r))
+	if err != nil {
+		logger.Error(fmt.Sprintf("Failed to delete categorization rule: %v", err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		logger.Error(fmt.Sprintf("Failed to get rows affected: %v", err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	if rowsAffected == 0 {
+		http.Error(w, "Rule not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "Rule deleted successfully",
+		"id":      ruleID,
+	})
+}
+
+// matchUncategorizedExpenses runs the household's rules over its expenses
+// without a subcategory, optionally limited to one user (0 for NULL) or a set
+// of ids.
+func matchUncategorizedExpenses(householdID int, userID *int, expenseIDs []int) ([]RuleMatch, int, error) {
+	rules, err := loadCategorizationRules(householdID)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	query := "SELECT e.id, e.amount, e.note, e.user_id, e.created_at FROM expenses e WHERE e.subcategory_id IS NULL AND e.household_id = ?"
+	args := []interface{}{householdID}
+
+	if userID != nil {
+		if *userID == 0 {
+			query += " AND e.user_id IS NULL"
+		} else {
+			query += " AND e.user_id = ?"
+			args = append(args, *userID)
+		}
+	}
+
+	if len(expenseIDs) > 0 {
+		query += fmt.Sprintf(" AND e.id IN (%s)", placeholders(len(expenseIDs)))
+		for _, id := range expenseIDs {
+			args = append(args, id)
+		}
+	}
+
+	query += " ORDER BY e.created_at"
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to query uncategorized expenses: %v", err)
+	}
+	defer rows.Close()
+
+	matches := []RuleMatch{}
+	unmatched := 0
+
+	for rows.Next() {
+		var match RuleMatch
+		if err := rows.Scan(&match.ExpenseID, &match.Amount, &match.Note, &match.UserID, &match.CreatedAt); err != nil {
+			return nil, 0, fmt.Errorf("failed to scan uncategorized expense row: %v", err)
+		}
+
+		rule := matchCategorizationRule(rules, match.Amount, match.Note, match.UserID)
+		if rule == nil {
+			unmatched++
+			continue
+		}
+
+		match.RuleID = rule.ID
+		match.RuleName = rule.Name
+		match.SubcategoryID = rule.SubcategoryID
+		matches = append(matches, match)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("error iterating over uncategorized expense rows: %v", err)
+	}
+
+	return matches, unmatched, nil
+}
+
+func previewRulesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var userID *int
+	if userIDStr := r.URL.Query().Get("user_id"); userIDStr != "" {
+		value, err := strconv.Atoi(userIDStr)
+		if err != nil {
+			http.Error(w, "Invalid user_id parameter", http.StatusBadRequest)
+			return
+		}
+		userID = &value
+	}
+
+	matches, unmatched, err := matchUncategorizedExpenses(householdID(r), userID, nil)
+	if err != nil {
+		logger.Error(err.Error())
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"matches":   matches,
+		"matched":   len(matches),
+		"unmatched": unmatched,
+	})
+}
+
+func applyRulesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		UserID     *int  `json:"user_id"`
+		ExpenseIDs []int `json:"expense_ids"`
+	}
+
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid JSON body", http.StatusBadRequest)
+			return
+		}
+	}
+
+	matches, _, err := matchUncategorizedExpenses(householdID(r), req.UserID, req.ExpenseIDs)
+	if err != nil {
+		logger.Error(err.Error())
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		logger.Error(fmt.Sprintf("Failed to begin rules transaction: %v", err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	// Expenses on a submitted, approved or paid claim are left as they are
+	// and listed in the response.
+	updated := 0
+	skipped := []int{}
+	for _, match := range matches {
+		locked, err := expenseLocked(tx, match.ExpenseID)
+		if err != nil {
+			logger.Error(err.Error())
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		if locked {
+			skipped = append(skipped, match.ExpenseID)
+			continue
+		}
+
+		result, err := tx.Exec("UPDATE expenses SET subcategory_id = ? WHERE id = ? AND subcategory_id IS NULL", match.SubcategoryID, match.ExpenseID)
+		if err != nil {
+			logger.Error(fmt.Sprintf("Failed to categorize expense %d: %v", match.ExpenseID, err))
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		if rowsAffected, err := result.RowsAffected(); err == nil {
+			updated += int(rowsAffected)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		logger.Error(fmt.Sprintf("Failed to commit rules transaction: %v", err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	if updated > 0 {
+		invalidateExpenseCaches()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message":             "Rules applied successfully",
+		"updated":             updated,
+		"skipped_expense_ids": skipped,
+	})
+}
@@ -0,0 +1,796 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/bits"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type SplitShareRequest struct {
+	UserID  int      `json:"user_id"`
+	Amount  *float64 `json:"amount,omitempty"`
+	Percent *float64 `json:"percent,omitempty"`
+}
+
+type SplitRequest struct {
+	Method string              `json:"method"`
+	Shares []SplitShareRequest `json:"shares"`
+}
+
+type ExpenseShare struct {
+	UserID   int     `json:"user_id"`
+	UserName string  `json:"user_name"`
+	Amount   float64 `json:"amount"`
+}
+
+type ExpenseSplit struct {
+	ExpenseID   int            `json:"expense_id"`
+	PayerUserID *int           `json:"payer_user_id"`
+	Amount      float64        `json:"amount"`
+	Shares      []ExpenseShare `json:"shares"`
+}
+
+type splitShare struct {
+	UserID int
+	Cents  int64
+}
+
+type transfer struct {
+	FromUserID int
+	ToUserID   int
+	Cents      int64
+}
+
+type Settlement struct {
+	ID         int     `json:"id"`
+	FromUserID int     `json:"from_user_id"`
+	ToUserID   int     `json:"to_user_id"`
+	Amount     float64 `json:"amount"`
+	Note       *string `json:"note"`
+	CreatedAt  string  `json:"created_at"`
+}
+
+type UserBalance struct {
+	UserID   int     `json:"user_id"`
+	UserName string  `json:"user_name"`
+	Net      float64 `json:"net"`
+}
+
+type SettleUpTransfer struct {
+	FromUserID   int     `json:"from_user_id"`
+	FromUserName string  `json:"from_user_name"`
+	ToUserID     int     `json:"to_user_id"`
+	ToUserName   string  `json:"to_user_name"`
+	Amount       float64 `json:"amount"`
+}
+
+func fromCents(cents int64) float64 {
+	return float64(cents) / 100
+}
+
+// distributeCents splits total proportionally to weights using the largest
+// remainder method, so the parts always add up to total exactly.
+func distributeCents(total int64, weights []float64) []int64 {
+	parts := make([]int64, len(weights))
+
+	var weightSum float64
+	for _, weight := range weights {
+		weightSum += weight
+	}
+	if weightSum <= 0 {
+		return parts
+	}
+
+	remainders := make([]float64, len(weights))
+	var assigned int64
+	for i, weight := range weights {
+		exact := float64(total) * weight / weightSum
+		parts[i] = int64(math.Floor(exact))
+		remainders[i] = exact - float64(parts[i])
+		assigned += parts[i]
+	}
+
+	order := make([]int, len(weights))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return remainders[order[a]] > remainders[order[b]]
+	})
+
+	for i := 0; assigned < total; i++ {
+		parts[order[i%len(order)]]++
+		assigned++
+	}
+
+	return parts
+}
+
+// computeSplitShares turns a split request into per-user amounts in cents.
+func computeSplitShares(amount float64, req SplitRequest) ([]splitShare, error) {
+	if len(req.Shares) == 0 {
+		return nil, fmt.Errorf("A split needs at least one share")
+	}
+
+	seen := make(map[int]bool)
+	for _, share := range req.Shares {
+		if share.UserID <= 0 {
+			return nil, fmt.Errorf("Every share needs a user_id")
+		}
+		if seen[share.UserID] {
+			return nil, fmt.Errorf("User %d appears more than once in the split", share.UserID)
+		}
+		seen[share.UserID] = true
+	}
+
+	total := toCents(amount)
+	shares := make([]splitShare, len(req.Shares))
+	weights := make([]float64, len(req.Shares))
+
+	switch req.Method {
+	case "equal":
+		for i := range weights {
+			weights[i] = 1
+		}
+	case "percent":
+		var sum float64
+		for i, share := range req.Shares {
+			if share.Percent == nil || *share.Percent <= 0 {
+				return nil, fmt.Errorf("Every share needs a percent greater than 0")
+			}
+			weights[i] = *share.Percent
+			sum += *share.Percent
+		}
+		if math.Abs(sum-100) > 0.001 {
+			return nil, fmt.Errorf("Percentages must add up to 100, got %g", sum)
+		}
+	case "exact":
+		var sum int64
+		for i, share := range req.Shares {
+			if share.Amount == nil || *share.Amount <= 0 {
+				return nil, fmt.Errorf("Every share needs an amount greater than 0")
+			}
+			shares[i] = splitShare{UserID: share.UserID, Cents: toCents(*share.Amount)}
+			sum += shares[i].Cents
+		}
+		if sum != total {
+			return nil, fmt.Errorf("Share amounts must add up to the expense amount %.2f, got %.2f", amount, fromCents(sum))
+		}
+		return shares, nil
+	default:
+		return nil, fmt.Errorf("Invalid split method. Must be 'equal', 'exact', or 'percent'")
+	}
+
+	for i, cents := range distributeCents(total, weights) {
+		shares[i] = splitShare{UserID: req.Shares[i].UserID, Cents: cents}
+	}
+
+	return shares, nil
+}
+
+// saveExpenseSplit replaces the shares of an expense; no shares removes the
+// split.
+func saveExpenseSplit(exec sqlExecer, expenseID int64, shares []splitShare) error {
+	if _, err := exec.Exec("DELETE FROM expense_splits WHERE expense_id = ?", expenseID); err != nil {
+		return fmt.Errorf("failed to clear expense split: %v", err)
+	}
+
+	for _, share := range shares {
+		_, err := exec.Exec(
+			"INSERT INTO expense_splits (expense_id, user_id, amount) VALUES (?, ?, ?)",
+			expenseID, share.UserID, fromCents(share.Cents),
+		)
+		if err != nil {
+			return fmt.Errorf("failed to save expense share: %v", err)
+		}
+	}
+
+	return nil
+}
+
+const expenseSharesQuery = "SELECT user_id, amount FROM expense_splits WHERE expense_id = ? ORDER BY user_id"
+
+func queryExpenseShares(expenseID int) ([]splitShare, error) {
+	rows, err := db.Query(expenseSharesQuery, expenseID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query expense split: %v", err)
+	}
+	return scanExpenseShares(rows)
+}
+
+func scanExpenseShares(rows *sql.Rows) ([]splitShare, error) {
+	defer rows.Close()
+
+	var shares []splitShare
+	for rows.Next() {
+		var share splitShare
+		var amount float64
+		if err := rows.Scan(&share.UserID, &amount); err != nil {
+			return nil, fmt.Errorf("failed to scan expense share: %v", err)
+		}
+		share.Cents = toCents(amount)
+		shares = append(shares, share)
+	}
+
+	return shares, rows.Err()
+}
+
+// rescaleExpenseSplit keeps an existing split in proportion when the
+// expense amount changes. The shares are read and locked in tx, so a
+// concurrent split edit waits instead of being rescaled from stale amounts.
+func rescaleExpenseSplit(tx *sql.Tx, expenseID int, amount float64) error {
+	rows, err := tx.Query(expenseSharesQuery+" FOR UPDATE", expenseID)
+	if err != nil {
+		return fmt.Errorf("failed to lock expense split: %v", err)
+	}
+	shares, err := scanExpenseShares(rows)
+	if err != nil || len(shares) == 0 {
+		return err
+	}
+
+	weights := make([]float64, len(shares))
+	for i, share := range shares {
+		weights[i] = float64(share.Cents)
+	}
+	for i, cents := range distributeCents(toCents(amount), weights) {
+		shares[i].Cents = cents
+	}
+
+	return saveExpenseSplit(tx, int64(expenseID), shares)
+}
+
+// loadUserNames maps the household's users to their display name, falling
+// back to their email.
+func loadUserNames(householdID int) (map[int]string, error) {
+	rows, err := db.Query("SELECT id, email, display_name FROM users WHERE household_id = ?", householdID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query users: %v", err)
+	}
+	defer rows.Close()
+
+	names := make(map[int]string)
+	for rows.Next() {
+		var id int
+		var email string
+		var displayName sql.NullString
+		if err := rows.Scan(&id, &email, &displayName); err != nil {
+			return nil, fmt.Errorf("failed to scan user row: %v", err)
+		}
+		names[id] = email
+		if displayName.Valid && displayName.String != "" {
+			names[id] = displayName.String
+		}
+	}
+
+	return names, rows.Err()
+}
+
+func isSplitPath(path string) bool {
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(path, "/api/v1/expenses/"), "/"), "/")
+	return len(parts) == 2 && parts[1] == "split"
+}
+
+func expenseSplitHandler(w http.ResponseWriter, r *http.Request) {
+	idStr := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/v1/expenses/"), "/")[0]
+	expenseID, err := strconv.Atoi(idStr)
+	if err != nil {
+		http.Error(w, "Invalid expense ID", http.StatusBadRequest)
+		return
+	}
+
+	split := ExpenseSplit{ExpenseID: expenseID, Shares: []ExpenseShare{}}
+	var payer sql.NullInt64
+	err = db.QueryRow("SELECT amount, user_id FROM expenses WHERE id = ? AND household_id = ?", expenseID, householdID(r)).Scan(&split.Amount, &payer)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Expense not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		logger.Error(fmt.Sprintf("Failed to query expense: %v", err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	split.PayerUserID = nullableInt(payer)
+
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut:
+		if split.PayerUserID == nil {
+			http.Error(w, "Expense needs a user_id (the payer) before it can be split", http.StatusBadRequest)
+			return
+		}
+
+		var req SplitRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		shares, err := computeSplitShares(split.Amount, req)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		var refs []householdRef
+		for i := range req.Shares {
+			refs = append(refs, householdRef{"user_id", "users", &req.Shares[i].UserID})
+		}
+		if writeRefError(w, checkHouseholdRefs(householdID(r), refs...)) {
+			return
+		}
+
+		tx, err := db.Begin()
+		if err != nil {
+			logger.Error(fmt.Sprintf("Failed to begin transaction: %v", err))
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		defer tx.Rollback()
+
+		if !checkExpenseUnlocked(w, tx, expenseID) {
+			return
+		}
+
+		if err := saveExpenseSplit(tx, int64(expenseID), shares); err != nil {
+			logger.Error(err.Error())
+			http.Error(w, "Split could not be saved; check the user IDs", http.StatusBadRequest)
+			return
+		}
+
+		if err := tx.Commit(); err != nil {
+			logger.Error(fmt.Sprintf("Failed to commit expense split: %v", err))
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+	case http.MethodDelete:
+		tx, err := db.Begin()
+		if err != nil {
+			logger.Error(fmt.Sprintf("Failed to begin transaction: %v", err))
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		defer tx.Rollback()
+
+		if !checkExpenseUnlocked(w, tx, expenseID) {
+			return
+		}
+
+		if err := saveExpenseSplit(tx, int64(expenseID), nil); err != nil {
+			logger.Error(err.Error())
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		if err := tx.Commit(); err != nil {
+			logger.Error(fmt.Sprintf("Failed to commit expense split: %v", err))
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"message": "Expense split removed successfully",
+			"id":      expenseID,
+		})
+		return
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	shares, err := queryExpenseShares(expenseID)
+	if err != nil {
+		logger.Error(err.Error())
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	names, err := loadUserNames(householdID(r))
+	if err != nil {
+		logger.Error(err.Error())
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	for _, share := range shares {
+		split.Shares = append(split.Shares, ExpenseShare{
+			UserID:   share.UserID,
+			UserName: names[share.UserID],
+			Amount:   fromCents(share.Cents),
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(split)
+}
+
+// maxExactSettleParties bounds the subset search in settleUp, which takes
+// 2^n steps; larger groups fall back to the greedy pairing alone.
+const maxExactSettleParties = 16
+
+// settleUp returns the fewest transfers that bring every balance to zero.
+// n people with non-zero balances need n-k transfers, where k is the largest
+// number of groups they split into whose balances each sum to zero, so the
+// balances are partitioned into as many such groups as possible and each group
+// is settled on its own.
+func settleUp(net map[int]int64) []transfer {
+	var userIDs []int
+	for userID, cents := range net {
+		if cents != 0 {
+			userIDs = append(userIDs, userID)
+		}
+	}
+	sort.Ints(userIDs)
+
+	if len(userIDs) > maxExactSettleParties {
+		return settleGreedy(net)
+	}
+
+	n := len(userIDs)
+	full := 1<<n - 1
+	sums := make([]int64, full+1)
+	groups := make([]int, full+1)
+	for mask := 1; mask <= full; mask++ {
+		low := bits.TrailingZeros(uint(mask))
+		sums[mask] = sums[mask&(mask-1)] + net[userIDs[low]]
+		for i := 0; i < n; i++ {
+			if mask&(1<<i) != 0 && groups[mask^(1<<i)] > groups[mask] {
+				groups[mask] = groups[mask^(1<<i)]
+			}
+		}
+		if sums[mask] == 0 {
+			groups[mask]++
+		}
+	}
+
+	// Peel people off the full set along a path that keeps the group count;
+	// each time the rest balances out, the people peeled since form a group.
+	var transfers []transfer
+	group := make(map[int]int64)
+	for mask := full; mask != 0; {
+		want := groups[mask]
+		if sums[mask] == 0 {
+			want--
+		}
+		for i := 0; i < n; i++ {
+			if mask&(1<<i) != 0 && groups[mask^(1<<i)] == want {
+				group[userIDs[i]] = net[userIDs[i]]
+				mask ^= 1 << i
+				break
+			}
+		}
+		if sums[mask] == 0 {
+			transfers = append(transfers, settleGreedy(group)...)
+			group = make(map[int]int64)
+		}
+	}
+
+	return transfers
+}
+
+// settleGreedy pairs the largest debtor with the largest creditor until every
+// balance is zero. It needs at most one transfer fewer than there are
+// people with a non-zero balance.
+func settleGreedy(net map[int]int64) []transfer {
+	type party struct {
+		userID int
+		cents  int64
+	}
+
+	var debtors, creditors []party
+	for userID, cents := range net {
+		if cents < 0 {
+			debtors = append(debtors, party{userID, -cents})
+		} else if cents > 0 {
+			creditors = append(creditors, party{userID, cents})
+		}
+	}
+
+	var transfers []transfer
+	for len(debtors) > 0 && len(creditors) > 0 {
+		byAmount := func(parties []party) {
+			sort.Slice(parties, func(a, b int) bool {
+				if parties[a].cents != parties[b].cents {
+					return parties[a].cents > parties[b].cents
+				}
+				return parties[a].userID < parties[b].userID
+			})
+		}
+		byAmount(debtors)
+		byAmount(creditors)
+
+		amount := debtors[0].cents
+		if creditors[0].cents < amount {
+			amount = creditors[0].cents
+		}
+
+		transfers = append(transfers, transfer{FromUserID: debtors[0].userID, ToUserID: creditors[0].userID, Cents: amount})
+
+		debtors[0].cents -= amount
+		creditors[0].cents -= amount
+		if debtors[0].cents == 0 {
+			debtors = debtors[1:]
+		}
+		if creditors[0].cents == 0 {
+			creditors = creditors[1:]
+		}
+	}
+
+	return transfers
+}
+
+func computeNetBalances(householdID int) (map[int]int64, error) {
+	net := make(map[int]int64)
+
+	// A share owed by someone other than the payer moves money from that
+	// person to the payer; the payer's own share cancels out.
+	rows, err := db.Query(`
+		SELECT e.user_id, es.user_id, es.amount
+		FROM expense_splits es
+		JOIN expenses e ON e.id = es.expense_id
+		WHERE e.household_id = ? AND e.user_id IS NOT NULL AND es.user_id != e.user_id
+	`, householdID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query expense splits: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var payerID, userID int
+		var amount float64
+		if err := rows.Scan(&payerID, &userID, &amount); err != nil {
+			return nil, fmt.Errorf("failed to scan expense share: %v", err)
+		}
+		net[payerID] += toCents(amount)
+		net[userID] -= toCents(amount)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over expense splits: %v", err)
+	}
+
+	rows, err = db.Query("SELECT from_user_id, to_user_id, SUM(amount) FROM settlements WHERE household_id = ? GROUP BY from_user_id, to_user_id", householdID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query settlements: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var fromID, toID int
+		var amount float64
+		if err := rows.Scan(&fromID, &toID, &amount); err != nil {
+			return nil, fmt.Errorf("failed to scan settlement total: %v", err)
+		}
+		net[fromID] += toCents(amount)
+		net[toID] -= toCents(amount)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over settlements: %v", err)
+	}
+
+	return net, nil
+}
+
+func balancesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	net, err := computeNetBalances(householdID(r))
+	if err != nil {
+		logger.Error(err.Error())
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	names, err := loadUserNames(householdID(r))
+	if err != nil {
+		logger.Error(err.Error())
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	balances := []UserBalance{}
+	for userID, cents := range net {
+		if cents != 0 {
+			balances = append(balances, UserBalance{UserID: userID, UserName: names[userID], Net: fromCents(cents)})
+		}
+	}
+	sort.Slice(balances, func(a, b int) bool {
+		if balances[a].Net != balances[b].Net {
+			return balances[a].Net > balances[b].Net
+		}
+		return balances[a].UserID < balances[b].UserID
+	})
+
+	transfers := []SettleUpTransfer{}
+	for _, t := range settleUp(net) {
+		transfers = append(transfers, SettleUpTransfer{
+			FromUserID:   t.FromUserID,
+			FromUserName: names[t.FromUserID],
+			ToUserID:     t.ToUserID,
+			ToUserName:   names[t.ToUserID],
+			Amount:       fromCents(t.Cents),
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"balances":  balances,
+		"transfers": transfers,
+	})
+}
+
+func getSettlementsHandler(w http.ResponseWriter, r *http.Request) {
+	query := "SELECT id, from_user_id, to_user_id, amount, note, created_at FROM settlements WHERE household_id = ?"
+	args := []interface{}{householdID(r)}
+
+	if userIDStr := r.URL.Query().Get("user_id"); userIDStr != "" {
+		userID, err := strconv.Atoi(userIDStr)
+		if err != nil {
+			http.Error(w, "Invalid user_id parameter", http.StatusBadRequest)
+			return
+		}
+		query += " AND (from_user_id = ? OR to_user_id = ?)"
+		args = append(args, userID, userID)
+	}
+	query += " ORDER BY created_at DESC, id DESC"
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		logger.Error(fmt.Sprintf("Failed to query settlements: %v", err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	settlements := []Settlement{}
+	for rows.Next() {
+		var settlement Settlement
+		var note sql.NullString
+		if err := rows.Scan(&settlement.ID, &settlement.FromUserID, &settlement.ToUserID, &settlement.Amount, &note, &settlement.CreatedAt); err != nil {
+			logger.Error(fmt.Sprintf("Failed to scan settlement: %v", err))
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		if note.Valid {
+			settlement.Note = &note.String
+		}
+		settlements = append(settlements, settlement)
+	}
+
+	if err := rows.Err(); err != nil {
+		logger.Error(fmt.Sprintf("Error iterating settlements: %v", err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(settlements)
+}
+
+func createSettlementHandler(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		FromUserID int     `json:"from_user_id"`
+		ToUserID   int     `json:"to_user_id"`
+		Amount     float64 `json:"amount"`
+		Note       *string `json:"note"`
+		Date       *string `json:"date"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if req.FromUserID <= 0 || req.ToUserID <= 0 {
+		http.Error(w, "from_user_id and to_user_id are required", http.StatusBadRequest)
+		return
+	}
+	if req.FromUserID == req.ToUserID {
+		http.Error(w, "A settlement needs two different users", http.StatusBadRequest)
+		return
+	}
+	if toCents(req.Amount) <= 0 {
+		http.Error(w, "Amount must be greater than 0", http.StatusBadRequest)
+		return
+	}
+
+	err := checkHouseholdRefs(householdID(r),
+		householdRef{"from_user_id", "users", &req.FromUserID},
+		householdRef{"to_user_id", "users", &req.ToUserID},
+	)
+	if writeRefError(w, err) {
+		return
+	}
+
+	var created sql.NullTime
+	if req.Date != nil {
+		date, err := time.Parse("2006-01-02", *req.Date)
+		if err != nil {
+			http.Error(w, "date must be in YYYY-MM-DD format", http.StatusBadRequest)
+			return
+		}
+		created = sql.NullTime{Time: date, Valid: true}
+	}
+
+	result, err := db.Exec(`
+		INSERT INTO settlements (household_id, from_user_id, to_user_id, amount, note, created_at)
+		VALUES (?, ?, ?, ?, ?, COALESCE(?, NOW()))
+	`, householdID(r), req.FromUserID, req.ToUserID, fromCents(toCents(req.Amount)), req.Note, created)
+	if err != nil {
+		logger.Error(fmt.Sprintf("Failed to create settlement: %v", err))
+		http.Error(w, "Settlement could not be saved; check the user IDs", http.StatusBadRequest)
+		return
+	}
+
+	settlementID, err := result.LastInsertId()
+	if err != nil {
+		logger.Error(fmt.Sprintf("Failed to get last insert ID: %v", err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"id":      settlementID,
+		"message": "Settlement recorded successfully",
+	})
+}
+
+func deleteSettlementHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	settlementID, err := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/api/v1/settlements/"))
+	if err != nil {
+		http.Error(w, "Invalid settlement ID", http.StatusBadRequest)
+		return
+	}
+
+	result, err := db.Exec("DELETE FROM settlements WHERE id = ? AND household_id = ?", settlementID, householdID(r))
+	if err != nil {
+		logger.Error(fmt.Sprintf("Failed to delete settlement: %v", err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		logger.Error(fmt.Sprintf("Failed to get rows affected: %v", err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	if rowsAffected == 0 {
+		http.Error(w, "Settlement not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "Settlement deleted successfully",
+		"id":      settlementID,
+	})
+}
+
+func settlementsHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		getSettlementsHandler(w, r)
+	case http.MethodPost:
+		createSettlementHandler(w, r)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
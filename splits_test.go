@@ -0,0 +1,66 @@
+package main
+
+import "testing"
+
+func TestSettleUp(t *testing.T) {
+	tests := []struct {
+		name          string
+		net           map[int]int64
+		wantTransfers int
+	}{
+		{
+			name:          "nobody owes anything",
+			net:           map[int]int64{1: 0, 2: 0},
+			wantTransfers: 0,
+		},
+		{
+			name:          "one debtor, two creditors",
+			net:           map[int]int64{1: -300, 2: 100, 3: 200},
+			wantTransfers: 2,
+		},
+		{
+			name:          "independent zero-sum pairs",
+			net:           map[int]int64{1: 500, 2: -500, 3: 400, 4: -400},
+			wantTransfers: 2,
+		},
+		{
+			// Pairing the largest balances first (-5 with +7) takes four
+			// transfers; {-5, +5} and {-4, -3, +7} settle in three.
+			name:          "zero-sum subgroups the greedy pairing misses",
+			net:           map[int]int64{1: -500, 2: 500, 3: -400, 4: -300, 5: 700},
+			wantTransfers: 3,
+		},
+		{
+			name:          "three equal debts to one creditor",
+			net:           map[int]int64{1: 900, 2: -300, 3: -300, 4: -300},
+			wantTransfers: 3,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transfers := settleUp(tt.net)
+
+			if len(transfers) != tt.wantTransfers {
+				t.Errorf("%d transfers, want %d: %+v", len(transfers), tt.wantTransfers, transfers)
+			}
+
+			balance := make(map[int]int64)
+			for userID, cents := range tt.net {
+				balance[userID] = cents
+			}
+			for _, transfer := range transfers {
+				if transfer.Cents <= 0 {
+					t.Errorf("transfer of %d cents", transfer.Cents)
+				}
+				balance[transfer.FromUserID] += transfer.Cents
+				balance[transfer.ToUserID] -= transfer.Cents
+			}
+			for userID, cents := range balance {
+				if cents != 0 {
+					t.Errorf("user %d left at %d cents", userID, cents)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,349 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const maxStatementChartBars = 10
+
+type StatementCategory struct {
+	ExpenseGroup
+	Subcategories []SubcategoryTotal
+	Expenses      []Expense
+}
+
+type Statement struct {
+	Month      time.Time
+	UserLabel  string
+	Total      float64
+	Categories []StatementCategory
+	Other      []Expense
+}
+
+func buildStatement(householdID int, month time.Time, userID *int) (Statement, error) {
+	statement := Statement{Month: month, UserLabel: "All users"}
+
+	dateFrom := month.Format("2006-01-02")
+	dateTo := month.AddDate(0, 1, -1).Format("2006-01-02")
+	filter := ExpenseFilter{HouseholdID: householdID, UserID: userID, DateFrom: dateFrom, DateTo: dateTo}
+
+	if userID != nil {
+		statement.UserLabel = "Unassigned expenses"
+		if *userID != 0 {
+			var email string
+			var displayName *string
+			err := db.QueryRow("SELECT email, display_name FROM users WHERE id = ? AND household_id = ?", *userID, householdID).Scan(&email, &displayName)
+			if err != nil {
+				return statement, fmt.Errorf("failed to query statement user: %v", err)
+			}
+			statement.UserLabel = email
+			if displayName != nil && *displayName != "" {
+				statement.UserLabel = *displayName
+			}
+		}
+	}
+
+	var err error
+	if statement.Total, err = queryExpenseTotal(filter); err != nil {
+		return statement, err
+	}
+
+	groups, err := queryExpenseGroups(filter, "category", "DESC")
+	if err != nil {
+		return statement, err
+	}
+
+	subcategoryTotals, err := querySubcategoryTotals(householdID, dateFrom, dateTo, userID)
+	if err != nil {
+		return statement, err
+	}
+
+	expenses, err := queryExpenses(filter, "e.created_at", "ASC")
+	if err != nil {
+		return statement, err
+	}
+
+	index := make(map[string]int)
+	for _, group := range groups {
+		index[group.Name] = len(statement.Categories)
+		statement.Categories = append(statement.Categories, StatementCategory{ExpenseGroup: group})
+	}
+
+	for _, total := range subcategoryTotals {
+		if i, ok := index[total.CategoryName]; ok {
+			statement.Categories[i].Subcategories = append(statement.Categories[i].Subcategories, total)
+		}
+	}
+
+	for _, expense := range expenses {
+		if expense.CategoryName == nil {
+			statement.Other = append(statement.Other, expense)
+			continue
+		}
+		if i, ok := index[*expense.CategoryName]; ok {
+			statement.Categories[i].Expenses = append(statement.Categories[i].Expenses, expense)
+		}
+	}
+
+	return statement, nil
+}
+
+func statementExpenseRow(expense Expense) []xlsxCell {
+	date := expense.CreatedAt
+	if createdAt, err := time.Parse(time.RFC3339Nano, expense.CreatedAt); err == nil {
+		date = createdAt.Format("2006-01-02")
+	}
+
+	return []xlsxCell{
+		xlsxText(date),
+		xlsxText(optionalString(expense.SubcategoryName)),
+		xlsxText(optionalString(expense.Note)),
+		xlsxText(optionalString(expense.UserEmail)),
+		xlsxMoney(expense.Amount),
+	}
+}
+
+func statementExpenseSheet(name string, expenses []Expense) xlsxSheet {
+	sheet := xlsxSheet{
+		Name:   name,
+		Widths: []float64{12, 24, 40, 28, 14},
+		Rows: [][]xlsxCell{{
+			{Text: "Date", Bold: true},
+			{Text: "Subcategory", Bold: true},
+			{Text: "Note", Bold: true},
+			{Text: "User", Bold: true},
+			{Text: "Amount", Bold: true},
+		}},
+	}
+
+	total := 0.0
+	for _, expense := range expenses {
+		sheet.Rows = append(sheet.Rows, statementExpenseRow(expense))
+		total += expense.Amount
+	}
+
+	sheet.Rows = append(sheet.Rows, []xlsxCell{
+		{Text: "Total", Bold: true}, {}, {}, {},
+		{Number: &total, Bold: true, Money: true},
+	})
+
+	return sheet
+}
+
+func statementXLSXSheets(statement Statement) []xlsxSheet {
+	summary := xlsxSheet{
+		Name:   "Summary",
+		Widths: []float64{28, 28, 14},
+		Rows: [][]xlsxCell{
+			{{Text: "Monthly statement " + statement.Month.Format("January 2006"), Bold: true}},
+			{xlsxText(statement.UserLabel)},
+			{},
+			{{Text: "Category", Bold: true}, {Text: "Subcategory", Bold: true}, {Text: "Total", Bold: true}},
+		},
+	}
+
+	for _, category := range statement.Categories {
+		total := category.Total
+		summary.Rows = append(summary.Rows, []xlsxCell{
+			{Text: category.Name, Bold: true}, {},
+			{Number: &total, Bold: true, Money: true},
+		})
+		for _, subcategory := range category.Subcategories {
+			summary.Rows = append(summary.Rows, []xlsxCell{{}, xlsxText(subcategory.SubcategoryName), xlsxMoney(subcategory.Total)})
+		}
+	}
+
+	if len(statement.Other) > 0 {
+		otherTotal := 0.0
+		for _, expense := range statement.Other {
+			otherTotal += expense.Amount
+		}
+		summary.Rows = append(summary.Rows, []xlsxCell{
+			{Text: "Uncategorized", Bold: true}, {},
+			{Number: &otherTotal, Bold: true, Money: true},
+		})
+	}
+
+	total := statement.Total
+	summary.Rows = append(summary.Rows, []xlsxCell{}, []xlsxCell{
+		{Text: "Total", Bold: true}, {},
+		{Number: &total, Bold: true, Money: true},
+	})
+
+	sheets := []xlsxSheet{summary}
+	for _, category := range statement.Categories {
+		sheets = append(sheets, statementExpenseSheet(category.Name, category.Expenses))
+	}
+	if len(statement.Other) > 0 {
+		sheets = append(sheets, statementExpenseSheet("Uncategorized", statement.Other))
+	}
+
+	return sheets
+}
+
+func formatAmount(amount float64) string {
+	return strconv.FormatFloat(amount, 'f', 2, 64)
+}
+
+func renderStatementPDF(statement Statement) *pdfDocument {
+	doc := newPDFDocument()
+	right := pdfPageWidth - pdfMargin
+
+	doc.text(pdfMargin, doc.y, 18, true, "Monthly statement "+statement.Month.Format("January 2006"))
+	doc.y -= 22
+	doc.text(pdfMargin, doc.y, 11, false, statement.UserLabel)
+	doc.y -= 18
+	doc.text(pdfMargin, doc.y, 12, true, "Total spent")
+	doc.textRight(right, doc.y, 12, true, formatAmount(statement.Total))
+	doc.y -= 30
+
+	if len(statement.Categories) > 0 {
+		doc.text(pdfMargin, doc.y, 13, true, "Spending by category")
+		doc.y -= 20
+
+		bars := statement.Categories
+		if len(bars) > maxStatementChartBars {
+			bars = bars[:maxStatementChartBars]
+		}
+
+		maxTotal := bars[0].Total
+		labelWidth := 140.0
+		chartWidth := right - pdfMargin - labelWidth - 70
+
+		for i, category := range bars {
+			width := 0.0
+			if maxTotal > 0 {
+				width = chartWidth * category.Total / maxTotal
+			}
+			gray := 0.35 + 0.4*float64(i)/float64(maxStatementChartBars)
+
+			doc.text(pdfMargin, doc.y, 9, false, category.Name)
+			doc.rect(pdfMargin+labelWidth, doc.y-2, width, 11, gray)
+			doc.textRight(right, doc.y, 9, false, formatAmount(category.Total))
+			doc.y -= 16
+		}
+		doc.y -= 14
+	}
+
+	doc.ensureSpace(40)
+	doc.text(pdfMargin, doc.y, 13, true, "Breakdown")
+	doc.y -= 8
+	doc.line(pdfMargin, doc.y, right, doc.y)
+	doc.y -= 16
+
+	for _, category := range statement.Categories {
+		// Keep a category on one page when it fits on one; a longer one
+		// breaks between subcategory rows, but never right after its heading.
+		block := 16 * float64(2+len(category.Subcategories))
+		if block > pdfPageHeight-2*pdfMargin {
+			block = 15 + 14
+		}
+		doc.ensureSpace(block)
+		doc.text(pdfMargin, doc.y, 11, true, category.Name)
+		doc.textRight(right-90, doc.y, 9, false, fmt.Sprintf("%d expenses", category.Count))
+		doc.textRight(right, doc.y, 11, true, formatAmount(category.Total))
+		doc.y -= 15
+
+		for _, subcategory := range category.Subcategories {
+			doc.ensureSpace(14)
+			doc.text(pdfMargin+16, doc.y, 10, false, subcategory.SubcategoryName)
+			doc.textRight(right, doc.y, 10, false, formatAmount(subcategory.Total))
+			doc.y -= 14
+		}
+		doc.y -= 6
+	}
+
+	if len(statement.Other) > 0 {
+		otherTotal := 0.0
+		for _, expense := range statement.Other {
+			otherTotal += expense.Amount
+		}
+		doc.ensureSpace(20)
+		doc.text(pdfMargin, doc.y, 11, true, "Uncategorized")
+		doc.textRight(right-90, doc.y, 9, false, fmt.Sprintf("%d expenses", len(statement.Other)))
+		doc.textRight(right, doc.y, 11, true, formatAmount(otherTotal))
+		doc.y -= 21
+	}
+
+	doc.ensureSpace(24)
+	doc.line(pdfMargin, doc.y+8, right, doc.y+8)
+	doc.y -= 6
+	doc.text(pdfMargin, doc.y, 12, true, "Total")
+	doc.textRight(right, doc.y, 12, true, formatAmount(statement.Total))
+
+	for i, page := range doc.pages {
+		fmt.Fprintf(page, "BT /F1 8 Tf %.2f %.2f Td (%s) Tj ET\n", pdfMargin, pdfMargin/2, pdfEscape(fmt.Sprintf("Page %d of %d", i+1, len(doc.pages))))
+	}
+
+	return doc
+}
+
+func statementReportHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	monthStr := r.URL.Query().Get("month")
+	format := r.URL.Query().Get("format")
+	userIDStr := r.URL.Query().Get("user_id")
+
+	month, err := time.Parse("2006-01", monthStr)
+	if err != nil {
+		http.Error(w, "Invalid month parameter. Must be in YYYY-MM format", http.StatusBadRequest)
+		return
+	}
+
+	if format != "xlsx" && format != "pdf" {
+		http.Error(w, "Invalid format parameter. Must be 'xlsx' or 'pdf'", http.StatusBadRequest)
+		return
+	}
+
+	var userID *int
+	if userIDStr != "" {
+		value, err := strconv.Atoi(userIDStr)
+		if err != nil {
+			http.Error(w, "Invalid user_id parameter", http.StatusBadRequest)
+			return
+		}
+		userID = &value
+	}
+
+	if writeRefError(w, checkHouseholdRefs(householdID(r), householdRef{"user_id", "users", userID})) {
+		return
+	}
+
+	statement, err := buildStatement(householdID(r), month, userID)
+	if err != nil {
+		logger.Error(err.Error())
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	var body bytes.Buffer
+	contentType := "application/pdf"
+	if format == "xlsx" {
+		contentType = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+		err = writeXLSX(&body, statementXLSXSheets(statement))
+	} else {
+		err = renderStatementPDF(statement).writeTo(&body)
+	}
+	if textErr, ok := err.(pdfTextError); ok {
+		http.Error(w, fmt.Sprintf("%v. Use format=xlsx instead", textErr), http.StatusUnprocessableEntity)
+		return
+	}
+	if err != nil {
+		logger.Error(fmt.Sprintf("Failed to render %s statement: %v", format, err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"statement-%s.%s\"", month.Format("2006-01"), format))
+	w.Header().Set("Content-Length", strconv.Itoa(body.Len()))
+	w.Write(body.Bytes())
+}
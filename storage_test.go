@@ -0,0 +1,236 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestValidBlobKey(t *testing.T) {
+	tests := []struct {
+		key  string
+		want bool
+	}{
+		{"expenses/12/abc", true},
+		{"abc", true},
+		{"", false},
+		{"/etc/passwd", false},
+		{"../secret", false},
+		{"expenses/../../secret", false},
+		{"expenses/./abc", false},
+		{"expenses//abc", false},
+		{"expenses/abc/", false},
+	}
+
+	for _, tt := range tests {
+		if got := validBlobKey(tt.key); got != tt.want {
+			t.Errorf("validBlobKey(%q) = %v, want %v", tt.key, got, tt.want)
+		}
+	}
+}
+
+// testBlobStorage runs the same round trip against any backend.
+func testBlobStorage(t *testing.T, storage BlobStorage) {
+	ctx := context.Background()
+	key := "expenses/7/0123abcd"
+	data := []byte("%PDF-1.4 receipt")
+
+	if err := storage.Put(ctx, key, data, "application/pdf"); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	body, err := storage.Get(ctx, key)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	got, err := io.ReadAll(body)
+	body.Close()
+	if err != nil {
+		t.Fatalf("reading blob: %v", err)
+	}
+	if string(got) != string(data) {
+		t.Errorf("Get = %q, want %q", got, data)
+	}
+
+	if err := storage.Delete(ctx, key); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := storage.Get(ctx, key); !errors.Is(err, errBlobNotFound) {
+		t.Errorf("Get after Delete = %v, want errBlobNotFound", err)
+	}
+	if err := storage.Delete(ctx, key); err != nil {
+		t.Errorf("deleting a missing blob = %v, want nil", err)
+	}
+
+	for _, bad := range []string{"../escape", "/absolute", "a//b"} {
+		if err := storage.Put(ctx, bad, data, ""); err == nil {
+			t.Errorf("Put(%q) succeeded, want an invalid key error", bad)
+		}
+		if _, err := storage.Get(ctx, bad); err == nil || errors.Is(err, errBlobNotFound) {
+			t.Errorf("Get(%q) = %v, want an invalid key error", bad, err)
+		}
+		if err := storage.Delete(ctx, bad); err == nil {
+			t.Errorf("Delete(%q) succeeded, want an invalid key error", bad)
+		}
+	}
+}
+
+func TestFilesystemStorage(t *testing.T) {
+	dir := t.TempDir()
+	root := filepath.Join(dir, "attachments")
+	testBlobStorage(t, &FilesystemStorage{Root: root})
+
+	// Nothing may be written outside the root, and no temp files are left.
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Name() != "attachments" {
+		t.Errorf("files next to the root: %v", entries)
+	}
+	leftovers, err := filepath.Glob(filepath.Join(root, "expenses", "7", ".upload-*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(leftovers) != 0 {
+		t.Errorf("temporary upload files left behind: %v", leftovers)
+	}
+}
+
+// s3StandIn is a minimal S3-compatible server: it checks each request's
+// Signature Version 4 and keeps objects in memory.
+type s3StandIn struct {
+	t       *testing.T
+	bucket  string
+	secret  string
+	mu      sync.Mutex
+	objects map[string][]byte
+	types   map[string]string
+}
+
+func (s *s3StandIn) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+
+	if err := s.verifySignature(r, body); err != nil {
+		s.t.Errorf("%s %s: %v", r.Method, r.URL.Path, err)
+		http.Error(w, "SignatureDoesNotMatch", http.StatusForbidden)
+		return
+	}
+
+	prefix := "/" + s.bucket + "/"
+	if !strings.HasPrefix(r.URL.Path, prefix) {
+		http.Error(w, "NoSuchBucket", http.StatusNotFound)
+		return
+	}
+	key := strings.TrimPrefix(r.URL.Path, prefix)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch r.Method {
+	case http.MethodPut:
+		s.objects[key] = body
+		s.types[key] = r.Header.Get("Content-Type")
+	case http.MethodGet:
+		data, ok := s.objects[key]
+		if !ok {
+			http.Error(w, "NoSuchKey", http.StatusNotFound)
+			return
+		}
+		w.Write(data)
+	case http.MethodDelete:
+		delete(s.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "MethodNotAllowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *s3StandIn) verifySignature(r *http.Request, body []byte) error {
+	sum := sha256.Sum256(body)
+	payloadHash := hex.EncodeToString(sum[:])
+	if got := r.Header.Get("X-Amz-Content-Sha256"); got != payloadHash {
+		return fmt.Errorf("payload hash %q, want %q", got, payloadHash)
+	}
+
+	amzDate := r.Header.Get("X-Amz-Date")
+	if len(amzDate) != len("20060102T150405Z") {
+		return fmt.Errorf("bad X-Amz-Date %q", amzDate)
+	}
+	scope := amzDate[:8] + "/us-east-1/s3/aws4_request"
+
+	canonicalRequest := strings.Join([]string{
+		r.Method,
+		r.URL.EscapedPath(),
+		"",
+		fmt.Sprintf("host:%s\nx-amz-content-sha256:%s\nx-amz-date:%s\n", r.Host, payloadHash, amzDate),
+		"host;x-amz-content-sha256;x-amz-date",
+		payloadHash,
+	}, "\n")
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{"AWS4-HMAC-SHA256", amzDate, scope, hex.EncodeToString(requestHash[:])}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+s.secret), amzDate[:8])
+	key = hmacSHA256(key, "us-east-1")
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	want := fmt.Sprintf("AWS4-HMAC-SHA256 Credential=test-key/%s, SignedHeaders=host;x-amz-content-sha256;x-amz-date, Signature=%s",
+		scope, hex.EncodeToString(hmacSHA256(key, stringToSign)))
+
+	if got := r.Header.Get("Authorization"); got != want {
+		return fmt.Errorf("authorization %q, want %q", got, want)
+	}
+	return nil
+}
+
+func TestS3Storage(t *testing.T) {
+	standIn := &s3StandIn{t: t, bucket: "receipts", secret: "test-secret", objects: map[string][]byte{}, types: map[string]string{}}
+	server := httptest.NewServer(standIn)
+	defer server.Close()
+
+	storage := &S3Storage{
+		Endpoint:        server.URL,
+		Bucket:          "receipts",
+		Region:          "us-east-1",
+		AccessKeyID:     "test-key",
+		SecretAccessKey: "test-secret",
+		Client:          server.Client(),
+	}
+	testBlobStorage(t, storage)
+
+	if err := storage.Put(context.Background(), "expenses/1/typed", []byte("x"), "image/png"); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if got := standIn.types["expenses/1/typed"]; got != "image/png" {
+		t.Errorf("stored content type = %q, want image/png", got)
+	}
+}
+
+func TestS3StorageReportsErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "AccessDenied", http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	storage := &S3Storage{Endpoint: server.URL, Bucket: "receipts", Region: "us-east-1", AccessKeyID: "k", SecretAccessKey: "s", Client: server.Client()}
+
+	if err := storage.Put(context.Background(), "a/b", []byte("x"), ""); err == nil || !strings.Contains(err.Error(), "AccessDenied") {
+		t.Errorf("Put = %v, want the storage error", err)
+	}
+	if _, err := storage.Get(context.Background(), "a/b"); err == nil || errors.Is(err, errBlobNotFound) {
+		t.Errorf("Get = %v, want the storage error", err)
+	}
+	if err := storage.Delete(context.Background(), "a/b"); err == nil {
+		t.Error("Delete succeeded, want the storage error")
+	}
+}
@@ -0,0 +1,366 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+type sqlQueryer interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+type SubcategoryInfo struct {
+	ID           int    `json:"id"`
+	Name         string `json:"name"`
+	CategoryID   int    `json:"category_id"`
+	CategoryName string `json:"category_name"`
+}
+
+// SubcategoryChange describes a merge or move before it is applied, and what
+// it changed afterwards. A move into a category that already has a
+// subcategory of the same name becomes a merge into it when requested.
+type SubcategoryChange struct {
+	Action       string           `json:"action"`
+	Subcategory  SubcategoryInfo  `json:"subcategory"`
+	Target       *SubcategoryInfo `json:"target"`
+	CategoryID   int              `json:"category_id"`
+	Name         string           `json:"name"`
+	ExpenseCount int              `json:"expense_count"`
+	RuleCount    int              `json:"rule_count"`
+	ReceiptCount int              `json:"receipt_count"`
+}
+
+// subcategoryChangeError is a merge or move that cannot be made as asked.
+type subcategoryChangeError struct {
+	status  int
+	message string
+}
+
+func (e subcategoryChangeError) Error() string {
+	return e.message
+}
+
+func writeSubcategoryChangeError(w http.ResponseWriter, err error) {
+	if changeErr, ok := err.(subcategoryChangeError); ok {
+		http.Error(w, changeErr.message, changeErr.status)
+		return
+	}
+	logger.Error(err.Error())
+	http.Error(w, "Internal server error", http.StatusInternalServerError)
+}
+
+// loadSubcategoryInfo finds a subcategory of the household; lock takes a row
+// lock for the rest of the transaction.
+func loadSubcategoryInfo(q sqlQueryer, householdID, subcategoryID int, lock bool) (*SubcategoryInfo, error) {
+	query := `
+		SELECT s.id, s.name, s.category_id, c.name
+		FROM subcategories s
+		JOIN categories c ON c.id = s.category_id
+		WHERE s.id = ? AND c.household_id = ?
+	`
+	if lock {
+		query += " FOR UPDATE"
+	}
+
+	var info SubcategoryInfo
+	err := q.QueryRow(query, subcategoryID, householdID).Scan(&info.ID, &info.Name, &info.CategoryID, &info.CategoryName)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query subcategory %d: %v", subcategoryID, err)
+	}
+	return &info, nil
+}
+
+func countSubcategoryUsage(q sqlQueryer, change *SubcategoryChange) error {
+	err := q.QueryRow(`
+		SELECT
+			(SELECT COUNT(*) FROM expenses WHERE subcategory_id = ?),
+			(SELECT COUNT(*) FROM categorization_rules WHERE subcategory_id = ?),
+			(SELECT COUNT(*) FROM receipt_jobs WHERE subcategory_id = ?)
+	`, change.Subcategory.ID, change.Subcategory.ID, change.Subcategory.ID).Scan(&change.ExpenseCount, &change.RuleCount, &change.ReceiptCount)
+	if err != nil {
+		return fmt.Errorf("failed to count subcategory usage: %v", err)
+	}
+	return nil
+}
+
+func planSubcategoryMerge(q sqlQueryer, householdID, subcategoryID, targetID int, lock bool) (SubcategoryChange, error) {
+	var change SubcategoryChange
+
+	source, err := loadSubcategoryInfo(q, householdID, subcategoryID, lock)
+	if err != nil {
+		return change, err
+	}
+	if source == nil {
+		return change, subcategoryChangeError{http.StatusNotFound, "Subcategory not found"}
+	}
+	if targetID == subcategoryID {
+		return change, subcategoryChangeError{http.StatusBadRequest, "Cannot merge a subcategory into itself"}
+	}
+
+	target, err := loadSubcategoryInfo(q, householdID, targetID, lock)
+	if err != nil {
+		return change, err
+	}
+	if target == nil {
+		return change, subcategoryChangeError{http.StatusBadRequest, fmt.Sprintf("Unknown into: %d", targetID)}
+	}
+
+	// Merging deletes the source, so expenses on a submitted, approved or
+	// paid claim cannot be skipped: they would lose their subcategory.
+	lockedQuery := `
+		SELECT COUNT(*)
+		FROM expenses e
+		JOIN reimbursement_claims c ON c.id = e.claim_id
+		WHERE e.subcategory_id = ? AND c.status != ?
+	`
+	if lock {
+		lockedQuery += " FOR UPDATE"
+	}
+	var locked int
+	if err := q.QueryRow(lockedQuery, subcategoryID, claimDraft).Scan(&locked); err != nil {
+		return change, fmt.Errorf("failed to count claimed expenses of subcategory %d: %v", subcategoryID, err)
+	}
+	if locked > 0 {
+		return change, subcategoryChangeError{http.StatusConflict, fmt.Sprintf("%d expenses of this subcategory are on submitted, approved or paid claims and cannot be moved", locked)}
+	}
+
+	change = SubcategoryChange{
+		Action:      "merge",
+		Subcategory: *source,
+		Target:      target,
+		CategoryID:  target.CategoryID,
+		Name:        target.Name,
+	}
+	return change, countSubcategoryUsage(q, &change)
+}
+
+// planSubcategoryMove checks the destination category for a subcategory with
+// the same name. Unless mergeOnConflict is set that is a 409, since
+// UNIQUE(category_id, name) would reject the move.
+func planSubcategoryMove(q sqlQueryer, householdID, subcategoryID, categoryID int, name string, mergeOnConflict, lock bool) (SubcategoryChange, error) {
+	var change SubcategoryChange
+
+	source, err := loadSubcategoryInfo(q, householdID, subcategoryID, lock)
+	if err != nil {
+		return change, err
+	}
+	if source == nil {
+		return change, subcategoryChangeError{http.StatusNotFound, "Subcategory not found"}
+	}
+
+	// The target is read through q, and locked when applying, so it cannot be
+	// deleted between this check and the move.
+	categoryQuery := "SELECT household_id FROM categories WHERE id = ?"
+	if lock {
+		categoryQuery += " FOR UPDATE"
+	}
+	var categoryHouseholdID int
+	err = q.QueryRow(categoryQuery, categoryID).Scan(&categoryHouseholdID)
+	if err != nil && err != sql.ErrNoRows {
+		return change, fmt.Errorf("failed to query category %d: %v", categoryID, err)
+	}
+	if err == sql.ErrNoRows || categoryHouseholdID != householdID {
+		return change, subcategoryChangeError{http.StatusBadRequest, fmt.Sprintf("Unknown category_id: %d", categoryID)}
+	}
+
+	if name == "" {
+		name = source.Name
+	}
+	if categoryID == source.CategoryID && name == source.Name {
+		return change, subcategoryChangeError{http.StatusBadRequest, "Subcategory is already in this category"}
+	}
+
+	var conflictID int
+	err = q.QueryRow("SELECT id FROM subcategories WHERE category_id = ? AND name = ? AND id != ?", categoryID, name, subcategoryID).Scan(&conflictID)
+	if err != nil && err != sql.ErrNoRows {
+		return change, fmt.Errorf("failed to check subcategory name in category %d: %v", categoryID, err)
+	}
+	if err == nil {
+		if !mergeOnConflict {
+			return change, subcategoryChangeError{http.StatusConflict, fmt.Sprintf("Category %d already has a subcategory named %s (id %d); merge into it or move under another name", categoryID, name, conflictID)}
+		}
+		return planSubcategoryMerge(q, householdID, subcategoryID, conflictID, lock)
+	}
+
+	change = SubcategoryChange{
+		Action:      "move",
+		Subcategory: *source,
+		CategoryID:  categoryID,
+		Name:        name,
+	}
+	return change, countSubcategoryUsage(q, &change)
+}
+
+// applySubcategoryChange carries out a planned change. A merge repoints
+// expenses, rules and receipt jobs before deleting the source, since rules
+// would otherwise be deleted with it.
+func applySubcategoryChange(tx *sql.Tx, change *SubcategoryChange) error {
+	if change.Action == "move" {
+		if _, err := tx.Exec("UPDATE subcategories SET category_id = ?, name = ? WHERE id = ?", change.CategoryID, change.Name, change.Subcategory.ID); err != nil {
+			return fmt.Errorf("failed to move subcategory: %v", err)
+		}
+		return nil
+	}
+
+	counts := []*int{&change.ExpenseCount, &change.RuleCount, &change.ReceiptCount}
+	for i, table := range []string{"expenses", "categorization_rules", "receipt_jobs"} {
+		result, err := tx.Exec("UPDATE "+table+" SET subcategory_id = ? WHERE subcategory_id = ?", change.Target.ID, change.Subcategory.ID)
+		if err != nil {
+			return fmt.Errorf("failed to reassign %s: %v", table, err)
+		}
+		if affected, err := result.RowsAffected(); err == nil {
+			*counts[i] = int(affected)
+		}
+	}
+
+	if _, err := tx.Exec("DELETE FROM subcategories WHERE id = ?", change.Subcategory.ID); err != nil {
+		return fmt.Errorf("failed to delete merged subcategory: %v", err)
+	}
+	return nil
+}
+
+// parseSubcategoryActionPath splits /api/v1/subcategories/{id}/{merge|move}.
+func parseSubcategoryActionPath(path string) (int, string, error) {
+	parts := strings.Split(strings.TrimPrefix(path, "/api/v1/subcategories/"), "/")
+	if len(parts) != 2 || (parts[1] != "merge" && parts[1] != "move") {
+		return 0, "", fmt.Errorf("unknown subcategory action")
+	}
+	id, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, "", fmt.Errorf("Invalid subcategory ID")
+	}
+	return id, parts[1], nil
+}
+
+func isSubcategoryActionPath(path string) bool {
+	return strings.HasSuffix(path, "/merge") || strings.HasSuffix(path, "/move")
+}
+
+type subcategoryActionRequest struct {
+	Into       int    `json:"into"`
+	CategoryID int    `json:"category_id"`
+	Name       string `json:"name"`
+	OnConflict string `json:"on_conflict"`
+}
+
+// subcategoryActionHandler previews a merge or move on GET (parameters in
+// the query string) and applies it on POST (parameters in the body).
+func subcategoryActionHandler(w http.ResponseWriter, r *http.Request) {
+	subcategoryID, action, err := parseSubcategoryActionPath(r.URL.Path)
+	if err != nil {
+		if err.Error() == "unknown subcategory action" {
+			http.NotFound(w, r)
+		} else {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		}
+		return
+	}
+
+	var req subcategoryActionRequest
+	switch r.Method {
+	case http.MethodGet:
+		query := r.URL.Query()
+		if value := query.Get("into"); value != "" {
+			if req.Into, err = strconv.Atoi(value); err != nil {
+				http.Error(w, "Invalid into parameter", http.StatusBadRequest)
+				return
+			}
+		}
+		if value := query.Get("category_id"); value != "" {
+			if req.CategoryID, err = strconv.Atoi(value); err != nil {
+				http.Error(w, "Invalid category_id parameter", http.StatusBadRequest)
+				return
+			}
+		}
+		req.Name = query.Get("name")
+		req.OnConflict = query.Get("on_conflict")
+	case http.MethodPost:
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid JSON body", http.StatusBadRequest)
+			return
+		}
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	req.Name = strings.TrimSpace(req.Name)
+	if action == "merge" && req.Into <= 0 {
+		http.Error(w, "into is required", http.StatusBadRequest)
+		return
+	}
+	if action == "move" {
+		if req.CategoryID <= 0 {
+			http.Error(w, "category_id is required", http.StatusBadRequest)
+			return
+		}
+		if req.Name != "" && len(req.Name) < 3 {
+			http.Error(w, "Subcategory name must be at least 3 characters long", http.StatusBadRequest)
+			return
+		}
+		if req.OnConflict != "" && req.OnConflict != "fail" && req.OnConflict != "merge" {
+			http.Error(w, "Invalid on_conflict. Must be 'fail' or 'merge'", http.StatusBadRequest)
+			return
+		}
+	}
+
+	plan := func(q sqlQueryer, lock bool) (SubcategoryChange, error) {
+		if action == "merge" {
+			return planSubcategoryMerge(q, householdID(r), subcategoryID, req.Into, lock)
+		}
+		return planSubcategoryMove(q, householdID(r), subcategoryID, req.CategoryID, req.Name, req.OnConflict == "merge", lock)
+	}
+
+	if r.Method == http.MethodGet {
+		change, err := plan(db, false)
+		if err != nil {
+			writeSubcategoryChangeError(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(change)
+		return
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		logger.Error(fmt.Sprintf("Failed to begin subcategory %s transaction: %v", action, err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	change, err := plan(tx, true)
+	if err != nil {
+		writeSubcategoryChangeError(w, err)
+		return
+	}
+
+	if err := applySubcategoryChange(tx, &change); err != nil {
+		logger.Error(err.Error())
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		logger.Error(fmt.Sprintf("Failed to commit subcategory %s: %v", action, err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	invalidateExpenseCaches()
+	logger.Info(fmt.Sprintf("Subcategory %d %sd: %d expenses, %d rules, %d receipts", subcategoryID, change.Action, change.ExpenseCount, change.RuleCount, change.ReceiptCount))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": fmt.Sprintf("Subcategory %sd successfully", change.Action),
+		"change":  change,
+	})
+}
@@ -0,0 +1,352 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const maxTagsPerExpense = 20
+
+var tagNamePattern = regexp.MustCompile(`^[\p{Ll}\p{N}][\p{Ll}\p{N}_.:-]{0,49}$`)
+
+type Tag struct {
+	ID           int     `json:"id"`
+	Name         string  `json:"name"`
+	ExpenseCount int     `json:"expense_count"`
+	Total        float64 `json:"total"`
+	CreatedAt    string  `json:"created_at"`
+}
+
+func normalizeTagName(name string) (string, error) {
+	normalized := strings.ToLower(strings.TrimSpace(name))
+	if !tagNamePattern.MatchString(normalized) {
+		return "", fmt.Errorf("Invalid tag %q: tags are 1-50 letters, digits, '-', '_', '.' or ':' with no spaces", name)
+	}
+	return normalized, nil
+}
+
+// normalizeTags lowercases, validates and de-duplicates tag names, keeping
+// the order they were given in.
+func normalizeTags(names []string) ([]string, error) {
+	seen := make(map[string]bool)
+	var tags []string
+
+	for _, name := range names {
+		tag, err := normalizeTagName(name)
+		if err != nil {
+			return nil, err
+		}
+		if !seen[tag] {
+			seen[tag] = true
+			tags = append(tags, tag)
+		}
+	}
+
+	if len(tags) > maxTagsPerExpense {
+		return nil, fmt.Errorf("An expense can have at most %d tags", maxTagsPerExpense)
+	}
+
+	return tags, nil
+}
+
+// setExpenseTags replaces the tags of an expense, creating tags that do not
+// exist yet in the household. It only needs Exec so it runs inside the
+// caller's transaction; for an existing expense that transaction has to pass
+// checkExpenseUnlocked first.
+func setExpenseTags(exec sqlExecer, householdID int, expenseID int64, names []string) error {
+	tags, err := normalizeTags(names)
+	if err != nil {
+		return err
+	}
+
+	if _, err := exec.Exec("DELETE FROM expense_tags WHERE expense_id = ?", expenseID); err != nil {
+		return fmt.Errorf("failed to clear expense tags: %v", err)
+	}
+
+	if len(tags) == 0 {
+		return nil
+	}
+
+	args := make([]interface{}, len(tags))
+	values := make([]string, len(tags))
+	var insertArgs []interface{}
+	for i, tag := range tags {
+		args[i] = tag
+		values[i] = "(?, ?)"
+		insertArgs = append(insertArgs, householdID, tag)
+	}
+
+	if _, err := exec.Exec("INSERT IGNORE INTO tags (household_id, name) VALUES "+strings.Join(values, ", "), insertArgs...); err != nil {
+		return fmt.Errorf("failed to create tags: %v", err)
+	}
+
+	_, err = exec.Exec(
+		fmt.Sprintf("INSERT INTO expense_tags (expense_id, tag_id) SELECT ?, id FROM tags WHERE household_id = ? AND name IN (%s)", placeholders(len(tags))),
+		append([]interface{}{expenseID, householdID}, args...)...,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to tag expense: %v", err)
+	}
+
+	return nil
+}
+
+// splitTags parses the comma-separated tag list the expense queries select.
+func splitTags(value sql.NullString) []string {
+	if !value.Valid || value.String == "" {
+		return []string{}
+	}
+	return strings.Split(value.String, ",")
+}
+
+func getTagsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	rows, err := db.Query(`
+		SELECT t.id, t.name, COUNT(e.id), COALESCE(SUM(e.amount), 0), t.created_at
+		FROM tags t
+		LEFT JOIN expense_tags et ON et.tag_id = t.id
+		LEFT JOIN expenses e ON e.id = et.expense_id
+		WHERE t.household_id = ?
+		GROUP BY t.id, t.name, t.created_at
+		ORDER BY t.name
+	`, householdID(r))
+	if err != nil {
+		logger.Error(fmt.Sprintf("Failed to query tags: %v", err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	tags := []Tag{}
+	for rows.Next() {
+		var tag Tag
+		if err := rows.Scan(&tag.ID, &tag.Name, &tag.ExpenseCount, &tag.Total, &tag.CreatedAt); err != nil {
+			logger.Error(fmt.Sprintf("Failed to scan tag: %v", err))
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		tags = append(tags, tag)
+	}
+
+	if err := rows.Err(); err != nil {
+		logger.Error(fmt.Sprintf("Error iterating tags: %v", err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(tags)
+}
+
+func createTagHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var requestBody struct {
+		Name string `json:"name"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
+		http.Error(w, "Invalid JSON body", http.StatusBadRequest)
+		return
+	}
+
+	name, err := normalizeTagName(requestBody.Name)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var existingID int
+	err = db.QueryRow("SELECT id FROM tags WHERE name = ? AND household_id = ?", name, householdID(r)).Scan(&existingID)
+	if err == nil {
+		http.Error(w, "Tag already exists", http.StatusConflict)
+		return
+	} else if err != sql.ErrNoRows {
+		logger.Error(fmt.Sprintf("Failed to check tag uniqueness: %v", err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	result, err := db.Exec("INSERT INTO tags (household_id, name) VALUES (?, ?)", householdID(r), name)
+	if err != nil {
+		logger.Error(fmt.Sprintf("Failed to create tag: %v", err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	tagID, err := result.LastInsertId()
+	if err != nil {
+		logger.Error(fmt.Sprintf("Failed to get last insert ID: %v", err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "Tag created successfully",
+		"id":      tagID,
+		"name":    name,
+	})
+}
+
+func updateTagHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut && r.Method != http.MethodPatch {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	tagID, err := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/api/v1/tags/"))
+	if err != nil {
+		http.Error(w, "Invalid tag ID", http.StatusBadRequest)
+		return
+	}
+
+	var requestBody struct {
+		Name string `json:"name"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
+		http.Error(w, "Invalid JSON body", http.StatusBadRequest)
+		return
+	}
+
+	name, err := normalizeTagName(requestBody.Name)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var existingID int
+	err = db.QueryRow("SELECT id FROM tags WHERE name = ? AND id != ? AND household_id = ?", name, tagID, householdID(r)).Scan(&existingID)
+	if err == nil {
+		http.Error(w, "Tag name already exists", http.StatusConflict)
+		return
+	} else if err != sql.ErrNoRows {
+		logger.Error(fmt.Sprintf("Failed to check tag uniqueness: %v", err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	var currentName string
+	err = db.QueryRow("SELECT name FROM tags WHERE id = ? AND household_id = ?", tagID, householdID(r)).Scan(&currentName)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Tag not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		logger.Error(fmt.Sprintf("Failed to query tag: %v", err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	if _, err := db.Exec("UPDATE tags SET name = ? WHERE id = ?", name, tagID); err != nil {
+		logger.Error(fmt.Sprintf("Failed to update tag: %v", err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	invalidateExpenseCaches()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "Tag updated successfully",
+		"id":      tagID,
+		"name":    name,
+	})
+}
+
+func deleteTagHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	tagID, err := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/api/v1/tags/"))
+	if err != nil {
+		http.Error(w, "Invalid tag ID", http.StatusBadRequest)
+		return
+	}
+
+	result, err := db.Exec("DELETE FROM tags WHERE id = ? AND household_id = ?", tagID, householdID(r))
+	if err != nil {
+		logger.Error(fmt.Sprintf("Failed to delete tag: %v", err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		logger.Error(fmt.Sprintf("Failed to get rows affected: %v", err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	if rowsAffected == 0 {
+		http.Error(w, "Tag not found", http.StatusNotFound)
+		return
+	}
+
+	invalidateExpenseCaches()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "Tag deleted successfully",
+		"id":      tagID,
+	})
+}
+
+// parseTagFilter reads tag=a,b (repeatable) and tag_match=any|all.
+func parseTagFilter(values []string, match string) ([]string, string, error) {
+	var names []string
+	for _, value := range values {
+		for _, name := range strings.Split(value, ",") {
+			if strings.TrimSpace(name) != "" {
+				names = append(names, name)
+			}
+		}
+	}
+
+	if len(names) == 0 {
+		if match != "" {
+			return nil, "", fmt.Errorf("tag_match requires tag")
+		}
+		return nil, "", nil
+	}
+
+	seen := make(map[string]bool)
+	var tags []string
+	for _, name := range names {
+		tag, err := normalizeTagName(name)
+		if err != nil {
+			return nil, "", fmt.Errorf("Invalid tag parameter")
+		}
+		if !seen[tag] {
+			seen[tag] = true
+			tags = append(tags, tag)
+		}
+	}
+	sort.Strings(tags)
+
+	switch match {
+	case "":
+		match = "any"
+	case "any", "all":
+	default:
+		return nil, "", fmt.Errorf("Invalid tag_match parameter. Must be 'any' or 'all'")
+	}
+
+	return tags, match, nil
+}